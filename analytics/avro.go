@@ -0,0 +1,476 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/internal/ddl"
+)
+
+const (
+	avroNamespace  string = "com.snowplowanalytics.snowplow"
+	avroRecordName string = "enriched_event"
+)
+
+type avroShredded struct {
+	uri      string
+	schema   []byte
+	repeated bool
+}
+
+type avroConfig struct {
+	layout      *Layout
+	shredded    []avroShredded
+	fieldErrors FieldErrorMode
+}
+
+// AvroOption configures an AvroCodec.
+type AvroOption func(*avroConfig)
+
+// WithAvroLayout sets the layout whose atomic fields are encoded, LayoutCurrent by default.
+// Events of layouts lacking some of these fields are encoded with null values for them.
+func WithAvroLayout(layout *Layout) AvroOption {
+	return func(c *avroConfig) {
+		c.layout = layout
+	}
+}
+
+// WithAvroContextSchema adds a field for the contexts and derived contexts of the schema's model, named as by
+// ContextsKey. The field is an array of records with the properties of the JSON Schema, which should be the latest
+// schema of the model. As in ToMap, a context found in both contexts and derived_contexts takes its value from derived_contexts.
+func WithAvroContextSchema(uri string, schema []byte) AvroOption {
+	return func(c *avroConfig) {
+		c.shredded = append(c.shredded, avroShredded{uri: uri, schema: schema, repeated: true})
+	}
+}
+
+// WithAvroUnstructEventSchema adds a field for the unstruct events of the schema's model, named as by UnstructEventKey.
+// The field is a record with the properties of the JSON Schema, which should be the latest schema of the model.
+func WithAvroUnstructEventSchema(uri string, schema []byte) AvroOption {
+	return func(c *avroConfig) {
+		c.shredded = append(c.shredded, avroShredded{uri: uri, schema: schema})
+	}
+}
+
+// WithAvroFieldErrorMode sets how fields which fail to parse or to match their schema are handled, FieldErrorsFail by
+// default. With a lenient mode, such fields are encoded as nulls, as typed Avro fields cannot hold raw strings, and
+// the event is returned along with FieldErrors.
+func WithAvroFieldErrorMode(mode FieldErrorMode) AvroOption {
+	return func(c *avroConfig) {
+		c.fieldErrors = mode
+	}
+}
+
+// AvroCodec encodes events to Avro with a schema generated from the atomic fields of a layout, extended with records
+// for the contexts and unstruct events of selected schemas. Every field is a union with null.
+// Atomic timestamps are encoded as timestamp-micros, and values without a single JSON Schema type as JSON strings.
+// An AvroCodec is safe for concurrent use.
+type AvroCodec struct {
+	schema  string
+	layout  *Layout
+	columns []avroColumn
+	// shredded is true if any column holds contexts or unstruct events
+	shredded    bool
+	fieldErrors FieldErrorMode
+}
+
+type avroColumn struct {
+	name string
+	// index is the index of atomic columns in the layout of the codec
+	index     int
+	fieldType FieldType
+	// entity holds the type of the data of shredded columns, and is nil for atomic columns
+	entity   *ddl.Type
+	repeated bool
+}
+
+// avroField, avroRecord, avroArray and avroLogical are marshaled to build Avro schemas, keeping their keys in order.
+type avroField struct {
+	Name    string         `json:"name"`
+	Type    any            `json:"type"`
+	Default *avroNullValue `json:"default,omitempty"`
+}
+
+type avroNullValue struct{}
+
+func (avroNullValue) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+type avroRecord struct {
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Fields    []avroField `json:"fields"`
+}
+
+type avroArray struct {
+	Type  string `json:"type"`
+	Items any    `json:"items"`
+}
+
+type avroLogical struct {
+	Type        string `json:"type"`
+	LogicalType string `json:"logicalType"`
+}
+
+// nullableAvroField returns a field of a union of null and the type, defaulting to null.
+func nullableAvroField(name string, avroType any) avroField {
+	return avroField{Name: name, Type: []any{"null", avroType}, Default: &avroNullValue{}}
+}
+
+// NewAvroCodec returns a codec for the provided options. Shredded keys and property names are made valid Avro names
+// by replacing their other characters with underscores, and it fails if the resulting schema is not valid Avro.
+func NewAvroCodec(opts ...AvroOption) (*AvroCodec, error) {
+	c := avroConfig{layout: LayoutCurrent}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if err := c.fieldErrors.valid(); err != nil {
+		return nil, fmt.Errorf("cannot create avro codec: %w", err)
+	}
+	codec := &AvroCodec{layout: c.layout, fieldErrors: c.fieldErrors}
+	record := avroRecord{Type: "record", Name: avroRecordName, Namespace: avroNamespace}
+	names := make(map[string]bool)
+	for index, column := range c.layout.Columns() {
+		var avroType any
+		switch column.Type {
+		case FieldContexts, FieldUnstruct:
+			continue
+		case FieldInt:
+			avroType = "long"
+		case FieldDouble:
+			avroType = "double"
+		case FieldBool:
+			avroType = "boolean"
+		case FieldTime:
+			avroType = avroLogical{Type: "long", LogicalType: "timestamp-micros"}
+		default:
+			avroType = "string"
+		}
+		name := avroName(column.Name)
+		if names[name] {
+			return nil, fmt.Errorf("cannot create avro codec: duplicate field %s", name)
+		}
+		names[name] = true
+		record.Fields = append(record.Fields, nullableAvroField(name, avroType))
+		codec.columns = append(codec.columns, avroColumn{name: column.Name, index: index, fieldType: column.Type})
+	}
+	for _, shredded := range c.shredded {
+		keyOf := UnstructEventKey
+		if shredded.repeated {
+			keyOf = ContextsKey
+		}
+		key, err := keyOf(shredded.uri)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create avro codec: %w", err)
+		}
+		// vendors and names of schemas may contain characters which are not allowed in Avro names, such as '-'
+		name := avroName(key)
+		if names[name] {
+			return nil, fmt.Errorf("cannot create avro codec: duplicate field %s for schema %s", name, shredded.uri)
+		}
+		names[name] = true
+		entity, err := ddl.FromJSONSchema(shredded.schema)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create avro codec: cannot convert schema %s: %w", shredded.uri, err)
+		}
+		avroType, err := avroTypeOf(entity, name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create avro codec: cannot convert schema %s: %w", shredded.uri, err)
+		}
+		if shredded.repeated {
+			avroType = avroArray{Type: "array", Items: []any{"null", avroType}}
+		}
+		record.Fields = append(record.Fields, nullableAvroField(name, avroType))
+		codec.columns = append(codec.columns, avroColumn{name: key, index: -1, entity: entity, repeated: shredded.repeated})
+		codec.shredded = true
+	}
+
+	schema, err := json.MarshalToString(record)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create avro codec: %w", err)
+	}
+	// named types must also have unique names across the schema, which goavro checks along with the rest of it
+	if _, err := goavro.NewCodec(schema); err != nil {
+		return nil, fmt.Errorf("cannot create avro codec: invalid schema: %w", err)
+	}
+	codec.schema = schema
+	return codec, nil
+}
+
+// Schema returns the Avro schema of the codec, as JSON.
+func (c *AvroCodec) Schema() string {
+	return c.schema
+}
+
+// avroTypeOf returns the Avro type of a ddl type. Records are named after their path from the shredded field, made of
+// Avro names, as Avro requires named types to have unique names.
+func avroTypeOf(t *ddl.Type, path string) (any, error) {
+	switch t.Kind {
+	case ddl.Integer:
+		return "long", nil
+	case ddl.Number:
+		return "double", nil
+	case ddl.Boolean:
+		return "boolean", nil
+	case ddl.Array:
+		items, err := avroTypeOf(t.Items, path+"_items")
+		if err != nil {
+			return nil, err
+		}
+		return avroArray{Type: "array", Items: []any{"null", items}}, nil
+	case ddl.Object:
+		record := avroRecord{Type: "record", Name: path}
+		names := make(map[string]bool, len(t.Fields))
+		for _, field := range t.Fields {
+			name := avroName(field.Name)
+			if names[name] {
+				return nil, fmt.Errorf("properties of %s have the same avro name %s", path, name)
+			}
+			names[name] = true
+			fieldType, err := avroTypeOf(field.Type, path+"_"+name)
+			if err != nil {
+				return nil, err
+			}
+			if field.Nullable {
+				record.Fields = append(record.Fields, nullableAvroField(name, fieldType))
+			} else {
+				record.Fields = append(record.Fields, avroField{Name: name, Type: fieldType})
+			}
+		}
+		return record, nil
+	default:
+		return "string", nil
+	}
+}
+
+// avroName replaces the characters of a property name or shredded key which are not allowed in Avro names with underscores.
+func avroName(name string) string {
+	out := []byte(name)
+	for i, c := range out {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')) {
+			out[i] = '_'
+		}
+	}
+	if len(out) == 0 {
+		return "_"
+	}
+	return string(out)
+}
+
+// ToAvro encodes a valid Snowplow ParsedEvent to the Avro binary encoding of the codec's schema. With a lenient
+// FieldErrorMode, the encoded event is returned along with FieldErrors if fields fail to parse.
+func (event ParsedEvent) ToAvro(codec *AvroCodec) ([]byte, error) {
+	return codec.appendEvent(nil, event)
+}
+
+// appendEvent appends the encoding of an event to dst. On error, nil is returned, unless the event is returned along
+// with FieldErrors.
+func (c *AvroCodec) appendEvent(dst []byte, event ParsedEvent) ([]byte, error) {
+	layout, err := event.layoutOrErr("encode event")
+	if err != nil {
+		return nil, err
+	}
+	var fieldErrs FieldErrors
+	var shredded map[string]any
+	if c.shredded {
+		if shredded, err = c.shredEvent(event, layout, &fieldErrs); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, column := range c.columns {
+		if column.entity != nil {
+			appended, err := column.appendShredded(dst, shredded[column.name])
+			if err != nil {
+				if err := c.fieldErrors.handle(&fieldErrs, err, column.name, -1, ""); err != nil {
+					return nil, err
+				}
+				appended = appendAvroLong(dst, 0)
+			}
+			dst = appended
+			continue
+		}
+		index := column.index
+		if layout != c.layout {
+			var ok bool
+			if index, ok = layout.index[column.name]; !ok {
+				dst = appendAvroLong(dst, 0)
+				continue
+			}
+		}
+		appended, err := column.appendAtomic(dst, layout.fields[index], event[index])
+		if err != nil {
+			if err := c.fieldErrors.handle(&fieldErrs, err, column.name, index, event[index]); err != nil {
+				return nil, err
+			}
+			appended = appendAvroLong(dst, 0)
+		}
+		dst = appended
+	}
+	return dst, fieldErrs.orNil()
+}
+
+// shredEvent returns the shredded contexts and unstruct event of an event, as ToMap does, leaving out columns which
+// fail to parse with a lenient FieldErrorMode.
+func (c *AvroCodec) shredEvent(event ParsedEvent, layout *Layout, fieldErrs *FieldErrors) (map[string]any, error) {
+	shredded := make(map[string]any)
+	for index, value := range event {
+		if value == "" || (layout.types[index] != FieldContexts && layout.types[index] != FieldUnstruct) {
+			continue
+		}
+		kvPairs, err := layout.fields[index].ParseFunction(layout.fields[index].Key, value)
+		if err != nil {
+			if err := c.fieldErrors.handle(fieldErrs, err, layout.fields[index].Key, index, value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, pair := range kvPairs {
+			shredded[pair.Key] = pair.Value
+		}
+	}
+	return shredded, nil
+}
+
+// appendAtomic appends the union of null and the value of an atomic column.
+func (column avroColumn) appendAtomic(dst []byte, field KeyFunctionPair, value string) ([]byte, error) {
+	if value == "" {
+		return appendAvroLong(dst, 0), nil
+	}
+	dst = appendAvroLong(dst, 1)
+	switch column.fieldType {
+	case FieldString:
+		return appendAvroString(dst, value), nil
+	case FieldInt:
+		intValue, err := decodeInt(field.Key, value)
+		if err != nil {
+			return nil, err
+		}
+		return appendAvroLong(dst, int64(intValue)), nil
+	case FieldDouble:
+		doubleValue, err := decodeDouble(field.Key, value)
+		if err != nil {
+			return nil, err
+		}
+		return appendAvroDouble(dst, doubleValue), nil
+	case FieldBool:
+		boolValue, err := decodeBool(field.Key, value)
+		if err != nil {
+			return nil, err
+		}
+		return appendAvroBool(dst, boolValue), nil
+	case FieldTime:
+		timeValue, err := decodeTime(field.Key, value)
+		if err != nil {
+			return nil, err
+		}
+		return appendAvroLong(dst, timeValue.UnixMicro()), nil
+	default:
+		kvPairs, err := field.ParseFunction(field.Key, value)
+		if err != nil {
+			return nil, err
+		}
+		var parsed any
+		if len(kvPairs) > 0 {
+			parsed = kvPairs[0].Value
+		}
+		encoded, err := json.MarshalToString(parsed)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode field %s to avro: %w", field.Key, err)
+		}
+		return appendAvroString(dst, encoded), nil
+	}
+}
+
+// appendShredded appends the union of null and the value of a shredded column.
+func (column avroColumn) appendShredded(dst []byte, value any) ([]byte, error) {
+	entityType := column.entity
+	if column.repeated {
+		entityType = &ddl.Type{Kind: ddl.Array, Items: column.entity}
+	}
+	normalized, err := ddl.Normalize(entityType, value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode field %s to avro: %w", column.name, err)
+	}
+	return appendAvroNullable(dst, entityType, normalized), nil
+}
+
+// appendAvroNullable appends the union of null and a value normalized by ddl.Normalize.
+func appendAvroNullable(dst []byte, t *ddl.Type, value any) []byte {
+	if value == nil {
+		return appendAvroLong(dst, 0)
+	}
+	return appendAvroValue(appendAvroLong(dst, 1), t, value)
+}
+
+// appendAvroValue appends a value normalized by ddl.Normalize.
+func appendAvroValue(dst []byte, t *ddl.Type, value any) []byte {
+	switch t.Kind {
+	case ddl.Integer:
+		return appendAvroLong(dst, value.(int64))
+	case ddl.Number:
+		return appendAvroDouble(dst, value.(float64))
+	case ddl.Boolean:
+		return appendAvroBool(dst, value.(bool))
+	case ddl.Array:
+		items := value.([]any)
+		if len(items) > 0 {
+			dst = appendAvroLong(dst, int64(len(items)))
+			for _, item := range items {
+				dst = appendAvroNullable(dst, t.Items, item)
+			}
+		}
+		return appendAvroLong(dst, 0)
+	case ddl.Object:
+		fields := value.(map[string]any)
+		for _, field := range t.Fields {
+			if field.Nullable {
+				dst = appendAvroNullable(dst, field.Type, fields[field.Name])
+			} else {
+				dst = appendAvroValue(dst, field.Type, fields[field.Name])
+			}
+		}
+		return dst
+	default:
+		return appendAvroString(dst, value.(string))
+	}
+}
+
+// appendAvroLong appends a long as a zig-zag encoded variable length integer.
+func appendAvroLong(dst []byte, value int64) []byte {
+	return binary.AppendUvarint(dst, uint64((value<<1)^(value>>63)))
+}
+
+func appendAvroDouble(dst []byte, value float64) []byte {
+	return binary.LittleEndian.AppendUint64(dst, math.Float64bits(value))
+}
+
+func appendAvroBool(dst []byte, value bool) []byte {
+	if value {
+		return append(dst, 1)
+	}
+	return append(dst, 0)
+}
+
+func appendAvroString(dst []byte, value string) []byte {
+	dst = appendAvroLong(dst, int64(len(value)))
+	return append(dst, value...)
+}
@@ -0,0 +1,220 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+var avroWebPageSchema = []byte(`{"type":"object","properties":{"author":{"type":"string"},"breadcrumb":{"type":["array","null"],"items":{"type":"string"}},"genre":{"type":"string"}},"required":["author"]}`)
+
+var avroLinkClickSchema = []byte(`{"type":"object","properties":{"targetUrl":{"type":"string"},"elementClasses":{"type":["array","null"],"items":{"type":"string"}}},"required":["targetUrl"]}`)
+
+func newTestAvroCodec(t testing.TB) *AvroCodec {
+	codec, err := NewAvroCodec(
+		WithAvroContextSchema("iglu:org.schema/WebPage/jsonschema/1-0-0", avroWebPageSchema),
+		WithAvroUnstructEventSchema("iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1", avroLinkClickSchema),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return codec
+}
+
+func TestNewAvroCodec(t *testing.T) {
+	assert := assert.New(t)
+
+	codec := newTestAvroCodec(t)
+	_, err := goavro.NewCodec(codec.Schema())
+	assert.Nil(err)
+	assert.Contains(codec.Schema(), `"name":"contexts_org_schema_web_page_1"`)
+	assert.Contains(codec.Schema(), `{"name":"collector_tstamp","type":["null",{"type":"long","logicalType":"timestamp-micros"}],"default":null}`)
+	assert.NotContains(codec.Schema(), `"name":"contexts"`)
+
+	_, err = NewAvroCodec(
+		WithAvroContextSchema("iglu:org.schema/WebPage/jsonschema/1-0-0", avroWebPageSchema),
+		WithAvroContextSchema("iglu:org.schema/WebPage/jsonschema/1-1-0", avroWebPageSchema),
+	)
+	assert.EqualError(err, "cannot create avro codec: duplicate field contexts_org_schema_web_page_1 for schema iglu:org.schema/WebPage/jsonschema/1-1-0")
+
+	_, err = NewAvroCodec(WithAvroUnstructEventSchema("fail", avroLinkClickSchema))
+	assert.NotNil(err)
+
+	_, err = NewAvroCodec(WithAvroUnstructEventSchema("iglu:com.acme/event/jsonschema/1-0-0", []byte(`{"type":"object","properties":{"a-b":{"type":"string"},"a_b":{"type":"string"}}}`)))
+	assert.EqualError(err, "cannot create avro codec: cannot convert schema iglu:com.acme/event/jsonschema/1-0-0: properties of unstruct_event_com_acme_event_1 have the same avro name a_b")
+
+	// hyphens of vendors and names are replaced in field and record names
+	hyphenated, err := NewAvroCodec(WithAvroContextSchema("iglu:com.my-company/my-context/jsonschema/1-0-0", avroWebPageSchema))
+	assert.Nil(err)
+	assert.Contains(hyphenated.Schema(), `"name":"contexts_com_my_company_my_context_1"`)
+	assert.NotContains(hyphenated.Schema(), "my-co")
+	_, err = goavro.NewCodec(hyphenated.Schema())
+	assert.Nil(err)
+	_, err = NewAvroCodec(
+		WithAvroContextSchema("iglu:com.my-company/my-context/jsonschema/1-0-0", avroWebPageSchema),
+		WithAvroContextSchema("iglu:com.my_company/my_context/jsonschema/1-0-0", avroWebPageSchema),
+	)
+	assert.EqualError(err, "cannot create avro codec: duplicate field contexts_com_my_company_my_context_1 for schema iglu:com.my_company/my_context/jsonschema/1-0-0")
+}
+
+func TestToAvro(t *testing.T) {
+	assert := assert.New(t)
+
+	codec := newTestAvroCodec(t)
+	decoder, err := goavro.NewCodec(codec.Schema())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := fullEvent.ToAvro(codec)
+	assert.Nil(err)
+	native, rest, err := decoder.NativeFromBinary(encoded)
+	assert.Nil(err)
+	assert.Empty(rest)
+	record := native.(map[string]any)
+
+	assert.Equal(map[string]any{"string": "<>angry-birds"}, record["app_id"])
+	assert.Equal(map[string]any{"long": int64(41828)}, record["txn_id"])
+	assert.Equal(map[string]any{"double": 37.443604}, record["geo_latitude"])
+	assert.Equal(map[string]any{"boolean": true}, record["br_features_pdf"])
+	assert.Equal(map[string]any{"long.timestamp-micros": tstampValue.UTC()}, record["collector_tstamp"])
+	assert.Nil(record["se_category"])
+	assert.Equal(map[string]any{
+		"array": []any{map[string]any{
+			"com.snowplowanalytics.snowplow.contexts_org_schema_web_page_1": map[string]any{
+				"author":     "Fred Blundun",
+				"breadcrumb": map[string]any{"array": []any{map[string]any{"string": "blog"}, map[string]any{"string": "releases"}}},
+				"genre":      map[string]any{"string": "blog"},
+			},
+		}},
+	}, record["contexts_org_schema_web_page_1"])
+	assert.Equal(map[string]any{
+		"com.snowplowanalytics.snowplow.unstruct_event_com_snowplowanalytics_snowplow_link_click_1": map[string]any{
+			"elementClasses": map[string]any{"array": []any{map[string]any{"string": "foreground"}}},
+			"targetUrl":      "http://www.example.com",
+		},
+	}, record["unstruct_event_com_snowplowanalytics_snowplow_link_click_1"])
+
+	// events of other layouts encode their missing fields as null
+	short, err := ParseEvent(strings.Join(fullEvent[:130], "\t"))
+	assert.Nil(err)
+	encoded, err = short.ToAvro(codec)
+	assert.Nil(err)
+	native, _, err = decoder.NativeFromBinary(encoded)
+	assert.Nil(err)
+	assert.Nil(native.(map[string]any)["true_tstamp"])
+	assert.Equal(map[string]any{"string": "<>angry-birds"}, native.(map[string]any)["app_id"])
+
+	invalid := append(ParsedEvent{}, fullEvent...)
+	invalid[7] = "not a number"
+	_, err = invalid.ToAvro(codec)
+	assert.NotNil(err)
+
+	_, err = ParsedEvent{"app_id"}.ToAvro(codec)
+	assert.NotNil(err)
+}
+
+func TestAvroWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	codec := newTestAvroCodec(t)
+	for _, deflate := range []bool{false, true} {
+		opts := []AvroWriterOption{WithAvroBlockSize(2)}
+		if deflate {
+			opts = append(opts, WithAvroDeflate())
+		}
+		var buffer bytes.Buffer
+		writer, err := NewAvroWriter(&buffer, codec, opts...)
+		assert.Nil(err)
+		for i := 0; i < 3; i++ {
+			assert.Nil(writer.Write(fullEvent))
+		}
+		assert.Nil(writer.Close())
+
+		reader, err := goavro.NewOCFReader(&buffer)
+		assert.Nil(err)
+		count := 0
+		for reader.Scan() {
+			datum, err := reader.Read()
+			assert.Nil(err)
+			assert.Equal(map[string]any{"string": "c6ef3124-b53a-4b13-a233-0088f79dcbcb"}, datum.(map[string]any)["event_id"])
+			count++
+		}
+		assert.Nil(reader.Err())
+		assert.Equal(3, count)
+	}
+
+	// an empty file still has a header
+	var buffer bytes.Buffer
+	writer, err := NewAvroWriter(&buffer, codec)
+	assert.Nil(err)
+	assert.Nil(writer.Close())
+	reader, err := goavro.NewOCFReader(&buffer)
+	assert.Nil(err)
+	assert.False(reader.Scan())
+	assert.Equal(codec.Schema(), reader.Codec().Schema())
+}
+
+func TestToAvroFieldErrorMode(t *testing.T) {
+	assert := assert.New(t)
+
+	codec, err := NewAvroCodec(
+		WithAvroContextSchema("iglu:org.schema/WebPage/jsonschema/1-0-0", avroWebPageSchema),
+		WithAvroFieldErrorMode(FieldErrorsOmit),
+	)
+	assert.Nil(err)
+	decoder, err := goavro.NewCodec(codec.Schema())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := withField(withField(fullEvent, "txn_id", "not a number"), "contexts", invalidCtxt)
+	encoded, err := invalid.ToAvro(codec)
+	var fieldErrs FieldErrors
+	assert.True(errors.As(err, &fieldErrs))
+	assert.Len(fieldErrs, 2)
+	native, rest, err := decoder.NativeFromBinary(encoded)
+	assert.Nil(err)
+	assert.Empty(rest)
+	record := native.(map[string]any)
+	assert.Nil(record["txn_id"])
+	assert.Nil(record["contexts_org_schema_web_page_1"])
+	assert.Equal(map[string]any{"string": "<>angry-birds"}, record["app_id"])
+
+	// lenient writers write the event
+	var buffer bytes.Buffer
+	writer, err := NewAvroWriter(&buffer, codec)
+	assert.Nil(err)
+	assert.True(errors.As(writer.Write(invalid), &fieldErrs))
+	assert.Nil(writer.Close())
+	reader, err := goavro.NewOCFReader(&buffer)
+	assert.Nil(err)
+	assert.True(reader.Scan())
+
+	_, err = NewAvroCodec(WithAvroFieldErrorMode(FieldErrorMode(7)))
+	assert.NotNil(err)
+}
+
+func BenchmarkToAvro(b *testing.B) {
+	codec := newTestAvroCodec(b)
+	for n := 0; n < b.N; n++ {
+		fullEvent.ToAvro(codec)
+	}
+}
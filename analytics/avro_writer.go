@@ -0,0 +1,165 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// avroMagic starts every Avro Object Container File.
+var avroMagic = []byte{'O', 'b', 'j', 1}
+
+// AvroWriterOption configures an AvroWriter.
+type AvroWriterOption func(*AvroWriter)
+
+// WithAvroBlockSize sets the number of events written in each block of the file, 1000 by default.
+func WithAvroBlockSize(size int) AvroWriterOption {
+	return func(w *AvroWriter) {
+		if size > 0 {
+			w.blockSize = size
+		}
+	}
+}
+
+// WithAvroDeflate compresses the blocks of the file with the deflate codec.
+func WithAvroDeflate() AvroWriterOption {
+	return func(w *AvroWriter) {
+		w.deflate = true
+	}
+}
+
+// AvroWriter writes events to an Avro Object Container File, with the schema of its codec.
+// Events are buffered and written in blocks; Close must be called to write the last block.
+// An AvroWriter is not safe for concurrent use.
+type AvroWriter struct {
+	output    io.Writer
+	codec     *AvroCodec
+	blockSize int
+	deflate   bool
+	sync      [16]byte
+	header    bool
+	block     []byte
+	count     int
+	compress  bytes.Buffer
+	err       error
+}
+
+// NewAvroWriter returns an AvroWriter writing to the provided io.Writer. The header of the file is written with the first block.
+func NewAvroWriter(output io.Writer, codec *AvroCodec, opts ...AvroWriterOption) (*AvroWriter, error) {
+	w := &AvroWriter{output: output, codec: codec, blockSize: 1000}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if _, err := rand.Read(w.sync[:]); err != nil {
+		return nil, fmt.Errorf("cannot create avro writer: %w", err)
+	}
+	return w, nil
+}
+
+// Write encodes an event and adds it to the current block, writing the block once it is full.
+// If the event cannot be encoded, the error is returned and the event is not written, unless the codec has a lenient
+// FieldErrorMode, in which case the event is written and FieldErrors is returned.
+func (w *AvroWriter) Write(event ParsedEvent) error {
+	if w.err != nil {
+		return w.err
+	}
+	block, err := w.codec.appendEvent(w.block, event)
+	if block == nil {
+		return err
+	}
+	w.block = block
+	w.count++
+	if w.count >= w.blockSize {
+		if flushErr := w.Flush(); flushErr != nil {
+			return flushErr
+		}
+	}
+	return err
+}
+
+// Flush writes the buffered events as a block, along with the header of the file if it has not been written yet.
+func (w *AvroWriter) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.writeHeader(); err != nil {
+		w.err = err
+		return err
+	}
+	if w.count == 0 {
+		return nil
+	}
+
+	data := w.block
+	if w.deflate {
+		w.compress.Reset()
+		compressor, err := flate.NewWriter(&w.compress, flate.DefaultCompression)
+		if err == nil {
+			_, err = compressor.Write(data)
+		}
+		if err == nil {
+			err = compressor.Close()
+		}
+		if err != nil {
+			w.err = fmt.Errorf("error compressing avro block: %w", err)
+			return w.err
+		}
+		data = w.compress.Bytes()
+	}
+
+	out := appendAvroLong(nil, int64(w.count))
+	out = appendAvroLong(out, int64(len(data)))
+	out = append(out, data...)
+	out = append(out, w.sync[:]...)
+	if _, err := w.output.Write(out); err != nil {
+		w.err = fmt.Errorf("error writing avro block: %w", err)
+		return w.err
+	}
+	w.block = w.block[:0]
+	w.count = 0
+	return nil
+}
+
+// Close flushes the buffered events. It does not close the underlying io.Writer.
+func (w *AvroWriter) Close() error {
+	return w.Flush()
+}
+
+func (w *AvroWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	codec := "null"
+	if w.deflate {
+		codec = "deflate"
+	}
+	header := append([]byte{}, avroMagic...)
+	// the metadata is a map of bytes, written as a single block
+	header = appendAvroLong(header, 2)
+	header = appendAvroString(header, "avro.schema")
+	header = appendAvroString(header, w.codec.Schema())
+	header = appendAvroString(header, "avro.codec")
+	header = appendAvroString(header, codec)
+	header = appendAvroLong(header, 0)
+	header = append(header, w.sync[:]...)
+	if _, err := w.output.Write(header); err != nil {
+		return fmt.Errorf("error writing avro header: %w", err)
+	}
+	w.header = true
+	return nil
+}
@@ -0,0 +1,235 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// OutputFormat selects the representation TransformBatch and Pipeline produce for each event.
+type OutputFormat int
+
+const (
+	// OutputJson transforms events as ToJson does.
+	OutputJson OutputFormat = iota
+	// OutputMap transforms events as ToMap does.
+	OutputMap
+)
+
+// BatchOptions configures TransformBatch and Pipeline.
+type BatchOptions struct {
+	// Workers is the number of goroutines transforming events. It defaults to runtime.GOMAXPROCS(0).
+	Workers int
+	// Format selects the representation produced for each event.
+	Format OutputFormat
+	// Transformer transforms events with its options, such as WithGeoLocation or WithNamingStrategy. Events are
+	// transformed as ToJson and ToMap do when it is nil.
+	Transformer *Transformer
+	// PreserveOrder makes a Pipeline emit results in the order their lines were received.
+	// TransformBatch always returns results in input order.
+	PreserveOrder bool
+}
+
+// BatchResult holds the outcome of transforming a single line.
+// Only the field matching the requested OutputFormat is set, unless Err is not nil. With a lenient FieldErrorMode, it is
+// also set along with Err when the error is a FieldErrors.
+type BatchResult struct {
+	// Index is the position of the line in the input.
+	Index int
+	Json  []byte
+	Map   map[string]any
+	Err   error
+}
+
+func (opts BatchOptions) workers() int {
+	if opts.Workers > 0 {
+		return opts.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+func (opts BatchOptions) transform(index int, line string) BatchResult {
+	result := BatchResult{Index: index}
+	event, err := ParseEvent(line)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	transformer := opts.Transformer
+	if transformer == nil {
+		transformer = defaultTransformer
+	}
+	if opts.Format == OutputMap {
+		result.Map, result.Err = transformer.ToMap(event)
+	} else {
+		result.Json, result.Err = transformer.ToJson(event)
+	}
+	return result
+}
+
+// TransformBatch parses and transforms a batch of enriched event tsv lines across a pool of workers.
+// A line which fails to transform has its error reported in its BatchResult, without affecting the rest of the batch.
+// If ctx is cancelled before the batch completes, the lines not yet transformed report the context's error,
+// which is also returned.
+func TransformBatch(ctx context.Context, lines []string, opts BatchOptions) ([]BatchResult, error) {
+	results := make([]BatchResult, len(lines))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < opts.workers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				results[index] = opts.transform(index, lines[index])
+			}
+		}()
+	}
+
+	next := 0
+dispatch:
+	for ; next < len(lines); next++ {
+		select {
+		case indexes <- next:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if next < len(lines) {
+		for index := next; index < len(lines); index++ {
+			results[index] = BatchResult{Index: index, Err: ctx.Err()}
+		}
+		return results, ctx.Err()
+	}
+	return results, nil
+}
+
+// Pipeline transforms a stream of enriched event tsv lines across a pool of workers.
+type Pipeline struct {
+	opts BatchOptions
+}
+
+// NewPipeline returns a Pipeline configured with the provided options.
+func NewPipeline(opts BatchOptions) *Pipeline {
+	return &Pipeline{opts: opts}
+}
+
+// reorderWindow is the number of lines per worker a Pipeline preserving order transforms ahead of the oldest line
+// whose result has not been emitted, which bounds the results buffered to reorder them.
+const reorderWindow = 4
+
+type pipelineJob struct {
+	index int
+	line  string
+}
+
+// Run transforms the lines received from input until it is closed or ctx is cancelled, and returns a channel of results.
+// A line which fails to transform has its error reported in its BatchResult. The results channel is closed once every
+// received line has been emitted, or as soon as ctx is cancelled, in which case remaining results are dropped.
+// With PreserveOrder, lines are only read while fewer than reorderWindow lines per worker wait for their results to
+// be emitted, so a slow line holds back the input rather than buffering all the results after it.
+func (p *Pipeline) Run(ctx context.Context, input <-chan string) <-chan BatchResult {
+	jobs := make(chan pipelineJob)
+	transformed := make(chan BatchResult)
+	var window chan struct{}
+	if p.opts.PreserveOrder {
+		window = make(chan struct{}, p.opts.workers()*reorderWindow)
+	}
+
+	go func() {
+		defer close(jobs)
+		index := 0
+		for {
+			select {
+			case line, ok := <-input:
+				if !ok {
+					return
+				}
+				if window != nil {
+					select {
+					case window <- struct{}{}:
+					case <-ctx.Done():
+						return
+					}
+				}
+				select {
+				case jobs <- pipelineJob{index, line}:
+					index++
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < p.opts.workers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				select {
+				case transformed <- p.opts.transform(job.index, job.line):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(transformed)
+	}()
+
+	if !p.opts.PreserveOrder {
+		return transformed
+	}
+	return reorder(ctx, transformed, window)
+}
+
+// reorder emits results in index order, buffering those which arrive early, and releases a slot of window for each
+// emitted result.
+func reorder(ctx context.Context, transformed <-chan BatchResult, window <-chan struct{}) <-chan BatchResult {
+	ordered := make(chan BatchResult)
+	go func() {
+		defer close(ordered)
+		pending := make(map[int]BatchResult)
+		next := 0
+		for result := range transformed {
+			pending[result.Index] = result
+			for {
+				result, ok := pending[next]
+				if !ok {
+					break
+				}
+				select {
+				case ordered <- result:
+				case <-ctx.Done():
+					return
+				}
+				delete(pending, next)
+				next++
+				<-window
+			}
+		}
+	}()
+	return ordered
+}
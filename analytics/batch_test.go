@@ -0,0 +1,183 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransformBatch(t *testing.T) {
+	assert := assert.New(t)
+
+	lines := []string{tsvEvent, "not an event", tsvEvent}
+
+	// json output
+	results, err := TransformBatch(context.Background(), lines, BatchOptions{Workers: 2})
+	assert.Nil(err)
+	assert.Len(results, 3)
+	for index, result := range results {
+		assert.Equal(index, result.Index)
+		assert.Nil(result.Map)
+	}
+	jsonOrdered, _ := orderJson(results[0].Json)
+	assert.Equal(eventMapWithoutGeoJSON, jsonOrdered)
+	assert.Nil(results[0].Err)
+	assert.NotNil(results[1].Err)
+	assert.Nil(results[1].Json)
+	assert.Nil(results[2].Err)
+
+	// map output with geo
+	results, err = TransformBatch(context.Background(), lines, BatchOptions{Format: OutputMap, Transformer: mustTransformer(WithGeoLocation())})
+	assert.Nil(err)
+	assert.Equal(eventMapWithGeo, results[0].Map)
+	assert.NotNil(results[1].Err)
+	assert.Equal(eventMapWithGeo, results[2].Map)
+
+	// null fields
+	results, err = TransformBatch(context.Background(), lines, BatchOptions{Transformer: mustTransformer(WithNullFields())})
+	assert.Nil(err)
+	assert.Contains(string(results[0].Json), `"se_category":null`)
+
+	// lenient field errors
+	invalid := strings.Join(withField(fullEvent, "page_urlport", "eighty"), "\t")
+	results, err = TransformBatch(context.Background(), []string{invalid}, BatchOptions{Format: OutputMap, Transformer: mustTransformer(WithFieldErrorMode(FieldErrorsRaw))})
+	assert.Nil(err)
+	assert.NotNil(results[0].Err)
+	assert.Equal("eighty", results[0].Map["page_urlport"])
+
+	// cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = TransformBatch(ctx, lines, BatchOptions{})
+	assert.Equal(context.Canceled, err)
+	assert.Len(results, 3)
+	assert.Equal(context.Canceled, results[2].Err)
+
+	// empty batch
+	results, err = TransformBatch(context.Background(), nil, BatchOptions{})
+	assert.Nil(err)
+	assert.Empty(results)
+}
+
+func BenchmarkTransformBatch(b *testing.B) {
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = tsvEvent
+	}
+	for i := 0; i < b.N; i++ {
+		TransformBatch(context.Background(), lines, BatchOptions{})
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	assert := assert.New(t)
+
+	input := make(chan string)
+	go func() {
+		defer close(input)
+		for i := 0; i < 50; i++ {
+			if i%10 == 0 {
+				input <- "not an event"
+				continue
+			}
+			input <- tsvEvent
+		}
+	}()
+
+	// ordered output
+	pipeline := NewPipeline(BatchOptions{Workers: 4, Format: OutputMap, PreserveOrder: true})
+	next := 0
+	for result := range pipeline.Run(context.Background(), input) {
+		assert.Equal(next, result.Index)
+		if next%10 == 0 {
+			assert.NotNil(result.Err)
+		} else {
+			assert.Nil(result.Err)
+			assert.Equal(eventMapWithoutGeo, result.Map)
+		}
+		next++
+	}
+	assert.Equal(50, next)
+}
+
+func TestReorderWindow(t *testing.T) {
+	assert := assert.New(t)
+
+	transformed := make(chan BatchResult)
+	window := make(chan struct{}, 2)
+	ordered := reorder(context.Background(), transformed, window)
+	acquire := func() bool {
+		select {
+		case window <- struct{}{}:
+			return true
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}
+
+	// lines are held back while the first result is pending
+	assert.True(acquire())
+	assert.True(acquire())
+	assert.False(acquire())
+	transformed <- BatchResult{Index: 1}
+	assert.False(acquire())
+	transformed <- BatchResult{Index: 0}
+	assert.Equal(0, (<-ordered).Index)
+	assert.Equal(1, (<-ordered).Index)
+	assert.True(acquire())
+	assert.True(acquire())
+	close(transformed)
+	_, ok := <-ordered
+	assert.False(ok)
+}
+
+func TestPipelineUnordered(t *testing.T) {
+	assert := assert.New(t)
+
+	input := make(chan string, 20)
+	for i := 0; i < 20; i++ {
+		input <- tsvEvent
+	}
+	close(input)
+
+	seen := make(map[int]bool)
+	for result := range NewPipeline(BatchOptions{Workers: 3}).Run(context.Background(), input) {
+		assert.Nil(result.Err)
+		assert.NotNil(result.Json)
+		seen[result.Index] = true
+	}
+	assert.Len(seen, 20)
+}
+
+func TestPipelineCancel(t *testing.T) {
+	assert := assert.New(t)
+
+	// input is never closed, so the pipeline only stops through cancellation
+	input := make(chan string)
+	ctx, cancel := context.WithCancel(context.Background())
+	results := NewPipeline(BatchOptions{PreserveOrder: true}).Run(ctx, input)
+
+	input <- tsvEvent
+	result := <-results
+	assert.Nil(result.Err)
+
+	cancel()
+	for range results {
+	}
+}
@@ -0,0 +1,58 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// ParseEventBytes takes a Snowplow Enriched event tsv line as bytes, such as the payload of a Kinesis or Kafka record,
+// and returns a 'ParsedEvent' typed slice of strings, as ParseEvent does, without copying the line.
+//
+// The columns of the event share the memory of line, as do the string values its methods return, such as those of
+// GetValue and ToMap; contexts and unstruct events are decoded into new memory. The line must therefore not be modified,
+// nor its buffer reused, while the event or such values are in use. Callers reusing their buffers should transform the
+// event, for example with ToJson, or clone the values they keep with strings.Clone, before reading the next record.
+func ParseEventBytes(line []byte) (ParsedEvent, error) {
+	record := strings.Split(bytesString(line), "\t")
+	if _, ok := LayoutFor(len(record)); !ok {
+		return nil, fmt.Errorf("cannot parse tsv event - %w", &LengthError{Got: len(record)})
+	}
+	return record, nil
+}
+
+// ParseLazyEventBytes takes a Snowplow Enriched event tsv line as bytes, and returns a LazyEvent holding the offsets of
+// its columns, as ParseLazyEvent does, without copying the line. ColumnBytes returns sub-slices of the line.
+// As for ParseEventBytes, the line must not be modified, nor its buffer reused, while the event or values returned by
+// its methods are in use.
+func ParseLazyEventBytes(line []byte) (*LazyEvent, error) {
+	event, err := ParseLazyEvent(bytesString(line))
+	if err != nil {
+		return nil, err
+	}
+	event.raw = line
+	return event, nil
+}
+
+// bytesString returns a string sharing the memory of b, which must not be modified while the string is in use.
+func bytesString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// stringBytes returns a byte slice sharing the memory of s, which must only be read.
+func stringBytes(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
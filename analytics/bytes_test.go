@@ -0,0 +1,146 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEventBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	line := []byte(tsvEvent)
+	event, err := ParseEventBytes(line)
+	assert.Nil(err)
+	assert.Equal(fullEvent, event)
+	mapped, err := event.ToMap()
+	assert.Nil(err)
+	assert.Equal(eventMapWithoutGeo, mapped)
+
+	// the line is not copied
+	allocs := testing.AllocsPerRun(10, func() {
+		ParseEventBytes(line)
+	})
+	assert.Equal(float64(1), allocs)
+
+	// columns share the memory of the line, while transformed output does not
+	jsonified, err := event.ToJson()
+	assert.Nil(err)
+	contexts, err := event.GetValue("contexts")
+	assert.Nil(err)
+	copy(line, bytes.Repeat([]byte("x"), len(line)))
+	assert.NotEqual(fullEvent[0], event[0])
+	expected, _ := fullEvent.ToJson()
+	assert.Equal(expected, jsonified)
+	expectedContexts, _ := fullEvent.GetValue("contexts")
+	assert.Equal(expectedContexts, contexts)
+
+	// incorrect input
+	_, err = ParseEventBytes([]byte("\t\t\t"))
+	assert.True(errors.As(err, new(*LengthError)))
+	_, err = ParseEventBytes(nil)
+	assert.NotNil(err)
+}
+
+func BenchmarkParseEventBytes(b *testing.B) {
+	line := []byte(tsvEvent)
+	for i := 0; i < b.N; i++ {
+		ParseEventBytes(line)
+	}
+}
+
+func BenchmarkParseEventFromBytes(b *testing.B) {
+	line := []byte(tsvEvent)
+	for i := 0; i < b.N; i++ {
+		ParseEvent(string(line))
+	}
+}
+
+func TestParseLazyEventBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	line := []byte(tsvEvent)
+	event, err := ParseLazyEventBytes(line)
+	assert.Nil(err)
+	assert.Equal(fullEvent, event.ParsedEvent())
+	value, err := event.GetContextValue("contexts_org_schema_web_page_1", "author")
+	assert.Nil(err)
+	assert.Equal([]any{"Fred Blundun"}, value)
+
+	// raw columns are sub-slices of the line, which cannot be appended to
+	column, ok := event.ColumnBytes("app_id")
+	assert.True(ok)
+	assert.Equal([]byte(fullEvent[0]), column)
+	assert.Same(&line[0], &column[0])
+	assert.Equal(len(column), cap(column))
+	_, ok = event.ColumnBytes("not_a_field")
+	assert.False(ok)
+
+	// events parsed from strings return copies
+	fromString, err := ParseLazyEvent(tsvEvent)
+	assert.Nil(err)
+	column, ok = fromString.ColumnBytes("app_id")
+	assert.True(ok)
+	assert.Equal([]byte(fullEvent[0]), column)
+
+	_, err = ParseLazyEventBytes([]byte("\t\t\t"))
+	assert.NotNil(err)
+}
+
+func BenchmarkParseLazyEventBytes(b *testing.B) {
+	line := []byte(tsvEvent)
+	for i := 0; i < b.N; i++ {
+		event, _ := ParseLazyEventBytes(line)
+		event.ColumnBytes("event_id")
+	}
+}
+
+func TestShredBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	// shredding sub-slices of a line
+	line := []byte("prefix\t" + contextsString + "\t" + unstructString + "\tsuffix")
+	start := len("prefix\t")
+	contexts := line[start : start+len(contextsString)]
+	start += len(contextsString) + 1
+	unstruct := line[start : start+len(unstructString)]
+
+	expected, err := shredContexts(contextsString, nil)
+	assert.Nil(err)
+	shredded, err := shredContextsBytes("contexts", contexts, nil)
+	assert.Nil(err)
+	assert.ElementsMatch(expected, shredded)
+
+	expected, err = shredUnstruct(unstructString, nil)
+	assert.Nil(err)
+	shredded, err = shredUnstructBytes(unstruct, nil)
+	assert.Nil(err)
+	assert.Equal(expected, shredded)
+
+	_, err = shredContextsBytes("contexts", []byte(invalidCtxt), nil)
+	assert.True(errors.As(err, new(*SchemaError)))
+	_, err = shredUnstructBytes(nil, nil)
+	assert.NotNil(err)
+}
+
+func BenchmarkShredContextsBytes(b *testing.B) {
+	contexts := []byte(contextsString)
+	for i := 0; i < b.N; i++ {
+		shredContextsBytes("contexts", contexts, nil)
+	}
+}
@@ -0,0 +1,756 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// shreddedEntry is a context or unstruct event, with its shredded key stored in the encoder's keys buffer.
+type shreddedEntry struct {
+	column   int
+	keyStart int
+	keyEnd   int
+	data     string
+}
+
+// jsonEncoder writes an event as JSON directly from its tsv columns.
+// Self-describing data is scanned without being decoded, and copied to the output as-is.
+type jsonEncoder struct {
+	layout *Layout
+	// plan is nil when every column is output
+	plan        *transformPlan
+	geolocation bool
+	// config holds the options of a Transformer, and is empty for AppendJson and AppendJsonWithGeo
+	config  transformConfig
+	keys    []byte
+	entries []shreddedEntry
+	// unescaped caches the decoded form of schema strings containing escape sequences, such as `\/`
+	unescaped map[string]string
+}
+
+// maxUnescapedSchemas bounds the number of escaped schema strings cached by an encoder.
+const maxUnescapedSchemas = 1024
+
+var jsonEncoderPool = sync.Pool{New: func() any { return &jsonEncoder{} }}
+
+// AppendJson transforms a valid Snowplow ParsedEvent to a JSON object, appending it to dst and returning the extended buffer.
+// Reusing the buffer across calls avoids allocating for every event. On error, dst is returned unchanged.
+func (event ParsedEvent) AppendJson(dst []byte) ([]byte, error) {
+	return event.appendJson(dst, false)
+}
+
+// AppendJsonWithGeo adds the geo_location field, and transforms a valid Snowplow ParsedEvent to a JSON object appended to dst.
+func (event ParsedEvent) AppendJsonWithGeo(dst []byte) ([]byte, error) {
+	return event.appendJson(dst, true)
+}
+
+func (event ParsedEvent) appendJson(dst []byte, addGeolocationData bool) ([]byte, error) {
+	layout, err := event.layoutOrErr("transform event")
+	if err != nil {
+		return dst, err
+	}
+	enc := jsonEncoderPool.Get().(*jsonEncoder)
+	defer jsonEncoderPool.Put(enc)
+	enc.reset(layout, nil)
+	enc.geolocation = addGeolocationData && layout.latitude >= 0 && layout.longitude >= 0
+
+	out, err := enc.encode(dst, event)
+	if err != nil {
+		return dst, err
+	}
+	return out, nil
+}
+
+// reset prepares the encoder for an event of the layout, with the options of the transformer if it is not nil.
+func (enc *jsonEncoder) reset(layout *Layout, t *Transformer) {
+	enc.layout = layout
+	enc.plan = nil
+	enc.geolocation = false
+	enc.config = transformConfig{}
+	if t != nil {
+		enc.plan = t.plan(layout)
+		enc.geolocation = enc.plan.geolocation
+		enc.config = t.config
+	}
+}
+
+func (enc *jsonEncoder) encode(dst []byte, event ParsedEvent) ([]byte, error) {
+	enc.keys = enc.keys[:0]
+	enc.entries = enc.entries[:0]
+
+	dst = append(dst, '{')
+	first := true
+	layout := enc.layout
+	if enc.geolocation && event[layout.latitude] != "" && event[layout.longitude] != "" {
+		dst = append(dst, `"geo_location":"`...)
+		dst = appendEscaped(dst, event[layout.latitude])
+		dst = append(dst, ',')
+		dst = appendEscaped(dst, event[layout.longitude])
+		dst = append(dst, '"')
+		first = false
+	} else if enc.geolocation && enc.config.nullFields {
+		dst = append(dst, `"geo_location":null`...)
+		first = false
+	}
+
+	var err error
+	var fieldErrs FieldErrors
+	for index, value := range event {
+		if enc.plan != nil && !enc.plan.included(index) {
+			continue
+		}
+		kind := layout.types[index]
+		if value == "" {
+			if enc.config.nullFields && kind != FieldContexts && kind != FieldUnstruct {
+				if !first {
+					dst = append(dst, ',')
+				}
+				first = false
+				dst = appendKey(dst, layout.fields[index].Key)
+				dst = append(dst, "null"...)
+			}
+			continue
+		}
+		field := layout.fields[index]
+		// the state to restore if the field fails to parse in a lenient mode
+		start, wasFirst, keys, entries := dst, first, len(enc.keys), len(enc.entries)
+		switch kind {
+		case FieldContexts:
+			err = enc.collectContexts(index, value)
+		case FieldUnstruct:
+			err = enc.collectUnstruct(index, value)
+		default:
+			if !first {
+				dst = append(dst, ',')
+			}
+			first = false
+			if kind == FieldTime && enc.config.timeFormat != TimestampTime {
+				dst, err = enc.config.appendTimeField(dst, field, value)
+			} else {
+				dst, err = appendField(dst, kind, field, value)
+			}
+		}
+		if err != nil {
+			if enc.config.fieldErrors == FieldErrorsFail {
+				return nil, columnError(err, field.Key, index, value)
+			}
+			fieldErrs = append(fieldErrs, columnError(err, field.Key, index, value))
+			dst, first = start, wasFirst
+			enc.keys, enc.entries = enc.keys[:keys], enc.entries[:entries]
+			if enc.config.fieldErrors == FieldErrorsRaw {
+				if !first {
+					dst = append(dst, ',')
+				}
+				first = false
+				dst = appendKey(dst, field.Key)
+				dst = appendString(dst, value)
+			}
+			err = nil
+		}
+	}
+	if enc.plan != nil {
+		for _, field := range enc.plan.missing {
+			if !first {
+				dst = append(dst, ',')
+			}
+			first = false
+			dst = appendKey(dst, field)
+			dst = append(dst, "null"...)
+		}
+	}
+	dst = enc.appendShredded(dst, first)
+	if len(fieldErrs) > 0 {
+		return append(dst, '}'), fieldErrs
+	}
+	return append(dst, '}'), nil
+}
+
+// appendTimeField appends a timestamp field as a JSON key and a value in the timestamp format of the config.
+func (c transformConfig) appendTimeField(dst []byte, field KeyFunctionPair, value string) ([]byte, error) {
+	timeValue, err := decodeTime(field.Key, value)
+	if err != nil {
+		return nil, err
+	}
+	dst = appendKey(dst, field.Key)
+	return c.appendTimestamp(dst, timeValue), nil
+}
+
+// appendField appends a non self-describing field as a JSON key and value.
+func appendField(dst []byte, kind FieldType, field KeyFunctionPair, value string) ([]byte, error) {
+	switch kind {
+	case FieldString:
+		dst = appendKey(dst, field.Key)
+		return appendString(dst, value), nil
+	case FieldInt:
+		intValue, err := decodeInt(field.Key, value)
+		if err != nil {
+			return nil, err
+		}
+		dst = appendKey(dst, field.Key)
+		return strconv.AppendInt(dst, int64(intValue), 10), nil
+	case FieldDouble:
+		doubleValue, err := decodeDouble(field.Key, value)
+		if err != nil {
+			return nil, err
+		}
+		if math.IsInf(doubleValue, 0) || math.IsNaN(doubleValue) {
+			return nil, fmt.Errorf("error marshaling to JSON: unsupported value: %f", doubleValue)
+		}
+		dst = appendKey(dst, field.Key)
+		return appendFloat(dst, doubleValue), nil
+	case FieldBool:
+		boolValue, err := decodeBool(field.Key, value)
+		if err != nil {
+			return nil, err
+		}
+		dst = appendKey(dst, field.Key)
+		return strconv.AppendBool(dst, boolValue), nil
+	case FieldTime:
+		timeValue, err := decodeTime(field.Key, value)
+		if err != nil {
+			return nil, err
+		}
+		dst = appendKey(dst, field.Key)
+		dst = append(dst, '"')
+		dst = timeValue.AppendFormat(dst, time.RFC3339Nano)
+		return append(dst, '"'), nil
+	default:
+		kvPairs, err := field.ParseFunction(field.Key, value)
+		if err != nil {
+			return nil, err
+		}
+		for i, pair := range kvPairs {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			marshaled, err := json.Marshal(pair.Value)
+			if err != nil {
+				return nil, fmt.Errorf("error marshaling to JSON: %w", err)
+			}
+			dst = appendKey(dst, pair.Key)
+			dst = append(dst, marshaled...)
+		}
+		return dst, nil
+	}
+}
+
+// appendShredded appends the collected contexts and unstruct event. Contexts are grouped by key in order of first
+// appearance, and a key present in several columns takes its value from the last one, as it does in ToMap, unless
+// contexts are merged with ContextsAppend.
+func (enc *jsonEncoder) appendShredded(dst []byte, first bool) []byte {
+	for i, entry := range enc.entries {
+		key := enc.keys[entry.keyStart:entry.keyEnd]
+		emitted := false
+		for _, other := range enc.entries[:i] {
+			if string(enc.keys[other.keyStart:other.keyEnd]) == string(key) {
+				emitted = true
+				break
+			}
+		}
+		lastColumn := entry.column
+		for _, other := range enc.entries[i+1:] {
+			if string(enc.keys[other.keyStart:other.keyEnd]) == string(key) {
+				lastColumn = other.column
+			}
+		}
+		if emitted {
+			continue
+		}
+
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+		dst = append(dst, '"')
+		dst = append(dst, key...)
+		dst = append(dst, '"', ':')
+
+		if enc.layout.types[lastColumn] == FieldUnstruct {
+			dst = append(dst, entry.data...)
+			continue
+		}
+		dst = append(dst, '[')
+		separator := false
+		for _, other := range enc.entries[i:] {
+			if (other.column == lastColumn || enc.config.merge == ContextsAppend) && string(enc.keys[other.keyStart:other.keyEnd]) == string(key) {
+				if separator {
+					dst = append(dst, ',')
+				}
+				separator = true
+				dst = append(dst, other.data...)
+			}
+		}
+		dst = append(dst, ']')
+	}
+	return dst
+}
+
+// collectContexts scans a contexts column, recording the shredded key and raw data of each of its entries.
+func (enc *jsonEncoder) collectContexts(column int, value string) error {
+	i := skipSpace(value, 0)
+	if strings.HasPrefix(value[i:], "null") && skipSpace(value, i+4) == len(value) {
+		return nil
+	}
+	err := scanDocument(value, func(name string, start int, end int) error {
+		if !strings.EqualFold(name, "data") || value[start] == 'n' {
+			return nil
+		}
+		if value[start] != '[' {
+			return errors.New("data is not an array")
+		}
+		_, err := scanArray(value, start, 2, func(start int, end int) error {
+			schema, data, err := enc.readSelfDescribing(value[start:end])
+			if err != nil {
+				return err
+			}
+			if err := enc.appendEntryKey(column, enc.contextsPrefix(column), schema, data); err != nil {
+				return schemaKeyError{fmt.Errorf("error parsing contexts: %w", err)}
+			}
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		var schemaErr schemaKeyError
+		if errors.As(err, &schemaErr) {
+			return schemaErr.err
+		}
+		return fmt.Errorf("error unmarshaling context JSON: %w", err)
+	}
+	return nil
+}
+
+// contextsPrefix returns the prefix of the keys of a contexts column.
+func (enc *jsonEncoder) contextsPrefix(column int) string {
+	if enc.config.merge == ContextsSeparate {
+		return enc.layout.fields[column].Key
+	}
+	return "contexts"
+}
+
+// collectUnstruct scans an unstruct_event column, recording the shredded key and raw data of its event.
+func (enc *jsonEncoder) collectUnstruct(column int, value string) error {
+	_, inner, err := enc.readSelfDescribing(value)
+	if err != nil {
+		return fmt.Errorf("error unmarshaling unstruct event JSON: %w", err)
+	}
+	schema, data := "", "null"
+	if inner != "null" {
+		schema, data, err = enc.readSelfDescribing(inner)
+		if err != nil {
+			return fmt.Errorf("error unmarshaling unstruct event JSON: %w", err)
+		}
+	}
+	if err := enc.appendEntryKey(column, "unstruct_event", schema, data); err != nil {
+		return fmt.Errorf("error parsing unstruct event: %w", err)
+	}
+	return nil
+}
+
+// schemaKeyError marks an invalid schema URI found while scanning, so that it is not reported as malformed JSON.
+type schemaKeyError struct {
+	err error
+}
+
+func (e schemaKeyError) Error() string {
+	return e.err.Error()
+}
+
+func (enc *jsonEncoder) appendEntryKey(column int, prefix string, schema string, data string) error {
+	parts, ok := splitSchemaURI(schema)
+	if !ok {
+		_, err := fixSchema(prefix, schema)
+		return err
+	}
+	start := len(enc.keys)
+	if enc.config.naming != nil {
+		enc.keys = append(enc.keys, enc.config.naming(prefix, parts)...)
+	} else {
+		enc.keys = appendShreddedKey(enc.keys, prefix, parts)
+	}
+	enc.entries = append(enc.entries, shreddedEntry{column: column, keyStart: start, keyEnd: len(enc.keys), data: data})
+	return nil
+}
+
+// appendShreddedKey appends the key fixSchema produces for the given schema parts.
+func appendShreddedKey(dst []byte, prefix string, parts SchemaParts) []byte {
+	dst = append(dst, prefix...)
+	dst = append(dst, '_')
+	for i := 0; i < len(parts.Vendor); i++ {
+		c := parts.Vendor[i]
+		if c == '.' {
+			c = '_'
+		}
+		dst = append(dst, toLowerASCII(c))
+	}
+	dst = append(dst, '_')
+	var prev byte
+	for i := 0; i < len(parts.Name); i++ {
+		c := parts.Name[i]
+		if 'A' <= c && c <= 'Z' && i > 0 && prev != '_' {
+			dst = append(dst, '_')
+		}
+		dst = append(dst, toLowerASCII(c))
+		prev = c
+	}
+	dst = append(dst, '_')
+	return append(dst, parts.Model...)
+}
+
+func toLowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// readSelfDescribing scans a self-describing JSON object, returning its unescaped schema and the raw JSON of its data.
+// Data must be an object, and defaults to null if absent.
+func (enc *jsonEncoder) readSelfDescribing(value string) (string, string, error) {
+	schema, data := "", "null"
+	err := scanDocument(value, func(name string, start int, end int) error {
+		switch {
+		case strings.EqualFold(name, "schema"):
+			switch value[start] {
+			case '"':
+				schema = enc.unquote(value[start:end])
+			case 'n':
+				schema = ""
+			default:
+				return errors.New("schema is not a string")
+			}
+		case strings.EqualFold(name, "data"):
+			if value[start] != '{' && value[start] != 'n' {
+				return errors.New("data is not an object")
+			}
+			data = value[start:end]
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return schema, data, nil
+}
+
+// unquote returns the content of a JSON string literal, decoding any escape sequences.
+func (enc *jsonEncoder) unquote(literal string) string {
+	content := literal[1 : len(literal)-1]
+	if strings.IndexByte(content, '\\') < 0 {
+		return content
+	}
+	if unescaped, ok := enc.unescaped[content]; ok {
+		return unescaped
+	}
+	if enc.unescaped == nil || len(enc.unescaped) >= maxUnescapedSchemas {
+		enc.unescaped = make(map[string]string)
+	}
+	unescaped := string(appendUnescaped(nil, content))
+	enc.unescaped[strings.Clone(content)] = unescaped
+	return unescaped
+}
+
+func appendUnescaped(dst []byte, content string) []byte {
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if c != '\\' {
+			dst = append(dst, c)
+			continue
+		}
+		i++
+		switch content[i] {
+		case 'b':
+			dst = append(dst, '\b')
+		case 'f':
+			dst = append(dst, '\f')
+		case 'n':
+			dst = append(dst, '\n')
+		case 'r':
+			dst = append(dst, '\r')
+		case 't':
+			dst = append(dst, '\t')
+		case 'u':
+			r, _ := strconv.ParseUint(content[i+1:i+5], 16, 32)
+			i += 4
+			if utf16IsHighSurrogate(rune(r)) && i+6 < len(content) && content[i+1] == '\\' && content[i+2] == 'u' {
+				low, _ := strconv.ParseUint(content[i+3:i+7], 16, 32)
+				r = uint64((rune(r)-0xd800)<<10|(rune(low)-0xdc00)) + 0x10000
+				i += 6
+			}
+			dst = utf8.AppendRune(dst, rune(r))
+		default:
+			dst = append(dst, content[i])
+		}
+	}
+	return dst
+}
+
+func utf16IsHighSurrogate(r rune) bool {
+	return 0xd800 <= r && r < 0xdc00
+}
+
+// appendKey appends a quoted object key and its colon.
+func appendKey(dst []byte, key string) []byte {
+	dst = appendString(dst, key)
+	return append(dst, ':')
+}
+
+// appendString appends a quoted JSON string, escaped as the package's JSON configuration does.
+func appendString(dst []byte, value string) []byte {
+	dst = append(dst, '"')
+	dst = appendEscaped(dst, value)
+	return append(dst, '"')
+}
+
+const hexDigits = "0123456789abcdef"
+
+func appendEscaped(dst []byte, value string) []byte {
+	start := 0
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c >= 0x20 && c != '"' && c != '\\' {
+			continue
+		}
+		dst = append(dst, value[start:i]...)
+		switch c {
+		case '"', '\\':
+			dst = append(dst, '\\', c)
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		default:
+			dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+		}
+		start = i + 1
+	}
+	return append(dst, value[start:]...)
+}
+
+// appendFloat formats a double as the package's JSON configuration does.
+func appendFloat(dst []byte, value float64) []byte {
+	format := byte('f')
+	if abs := math.Abs(value); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	return strconv.AppendFloat(dst, value, format, -1, 64)
+}
+
+// maxScanDepth bounds the nesting of scanned JSON values, as the JSON library does when decoding.
+const maxScanDepth = 10000
+
+// scanDocument scans a string holding a single JSON object, calling fn for each of its members.
+func scanDocument(s string, fn func(name string, start int, end int) error) error {
+	end, err := scanObject(s, skipSpace(s, 0), 1, fn)
+	if err != nil {
+		return err
+	}
+	if skipSpace(s, end) != len(s) {
+		return fmt.Errorf("unexpected content after object at offset %d", end)
+	}
+	return nil
+}
+
+// scanObject scans the JSON object starting at offset i and nested at the given depth, calling fn with the unescaped name and the bounds of the value
+// of each of its members. It returns the offset following the object.
+func scanObject(s string, i int, depth int, fn func(name string, start int, end int) error) (int, error) {
+	if i >= len(s) || s[i] != '{' {
+		return 0, fmt.Errorf("expected object at offset %d", i)
+	}
+	i = skipSpace(s, i+1)
+	if i < len(s) && s[i] == '}' {
+		return i + 1, nil
+	}
+	for {
+		nameEnd, err := skipString(s, i)
+		if err != nil {
+			return 0, err
+		}
+		name := s[i+1 : nameEnd-1]
+		if strings.IndexByte(name, '\\') >= 0 {
+			name = string(appendUnescaped(nil, name))
+		}
+		i = skipSpace(s, nameEnd)
+		if i >= len(s) || s[i] != ':' {
+			return 0, fmt.Errorf("expected colon at offset %d", i)
+		}
+		start := skipSpace(s, i+1)
+		end, err := skipValue(s, start, depth+1)
+		if err != nil {
+			return 0, err
+		}
+		if err := fn(name, start, end); err != nil {
+			return 0, err
+		}
+		i = skipSpace(s, end)
+		if i >= len(s) {
+			return 0, errors.New("unexpected end of object")
+		}
+		switch s[i] {
+		case ',':
+			i = skipSpace(s, i+1)
+		case '}':
+			return i + 1, nil
+		default:
+			return 0, fmt.Errorf("expected comma or closing brace at offset %d", i)
+		}
+	}
+}
+
+// scanArray scans the JSON array starting at offset i and nested at the given depth, calling fn with the bounds of each of its elements.
+// It returns the offset following the array.
+func scanArray(s string, i int, depth int, fn func(start int, end int) error) (int, error) {
+	if i >= len(s) || s[i] != '[' {
+		return 0, fmt.Errorf("expected array at offset %d", i)
+	}
+	i = skipSpace(s, i+1)
+	if i < len(s) && s[i] == ']' {
+		return i + 1, nil
+	}
+	for {
+		end, err := skipValue(s, i, depth+1)
+		if err != nil {
+			return 0, err
+		}
+		if err := fn(i, end); err != nil {
+			return 0, err
+		}
+		i = skipSpace(s, end)
+		if i >= len(s) {
+			return 0, errors.New("unexpected end of array")
+		}
+		switch s[i] {
+		case ',':
+			i = skipSpace(s, i+1)
+		case ']':
+			return i + 1, nil
+		default:
+			return 0, fmt.Errorf("expected comma or closing bracket at offset %d", i)
+		}
+	}
+}
+
+// skipValue validates the JSON value starting at offset i, and returns the offset following it.
+func skipValue(s string, i int, depth int) (int, error) {
+	if depth > maxScanDepth {
+		return 0, errors.New("exceeded max depth")
+	}
+	if i >= len(s) {
+		return 0, errors.New("unexpected end of value")
+	}
+	switch c := s[i]; {
+	case c == '"':
+		return skipString(s, i)
+	case c == '{':
+		return scanObject(s, i, depth, func(string, int, int) error { return nil })
+	case c == '[':
+		return scanArray(s, i, depth, func(int, int) error { return nil })
+	case c == '-' || ('0' <= c && c <= '9'):
+		return skipNumber(s, i)
+	case strings.HasPrefix(s[i:], "true"):
+		return i + 4, nil
+	case strings.HasPrefix(s[i:], "false"):
+		return i + 5, nil
+	case strings.HasPrefix(s[i:], "null"):
+		return i + 4, nil
+	default:
+		return 0, fmt.Errorf("invalid character %q at offset %d", c, i)
+	}
+}
+
+func skipString(s string, i int) (int, error) {
+	if i >= len(s) || s[i] != '"' {
+		return 0, fmt.Errorf("expected string at offset %d", i)
+	}
+	for i++; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			return i + 1, nil
+		case c == '\\':
+			i++
+			if i >= len(s) {
+				break
+			}
+			switch s[i] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			case 'u':
+				if i+4 >= len(s) {
+					return 0, errors.New("unexpected end of string")
+				}
+				if _, err := strconv.ParseUint(s[i+1:i+5], 16, 16); err != nil {
+					return 0, fmt.Errorf("invalid unicode escape at offset %d", i)
+				}
+				i += 4
+			default:
+				return 0, fmt.Errorf("invalid escape at offset %d", i)
+			}
+		case c < 0x20:
+			return 0, fmt.Errorf("invalid control character in string at offset %d", i)
+		}
+	}
+	return 0, errors.New("unexpected end of string")
+}
+
+func skipNumber(s string, i int) (int, error) {
+	start := i
+	if s[i] == '-' {
+		i++
+	}
+	digits := func() int {
+		n := 0
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+			n++
+		}
+		return n
+	}
+	if i < len(s) && s[i] == '0' {
+		i++
+	} else if digits() == 0 {
+		return 0, fmt.Errorf("invalid number at offset %d", start)
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		if digits() == 0 {
+			return 0, fmt.Errorf("invalid number at offset %d", start)
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return 0, fmt.Errorf("invalid number at offset %d", start)
+		}
+	}
+	return i, nil
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\r') {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,192 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// marshalViaMap produces the JSON ToJson produced before it wrote JSON directly from the tsv columns.
+func marshalViaMap(event ParsedEvent, addGeolocationData bool) ([]byte, error) {
+	mapified, err := event.mapifyGoodEvent(addGeolocationData)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mapified)
+}
+
+func withField(event ParsedEvent, field string, value string) ParsedEvent {
+	modified := append(ParsedEvent{}, event...)
+	modified[indexMap[field]] = value
+	return modified
+}
+
+var encoderTestEvents = map[string]ParsedEvent{
+	"full event":            fullEvent,
+	"escaped strings":       withField(fullEvent, "page_title", "quote \" backslash \\ newline \n tab \t control \x01 unicode ü <html>"),
+	"exponent double":       withField(withField(fullEvent, "geo_latitude", "1e-7"), "tr_total", "12e21"),
+	"leading zero int":      withField(fullEvent, "page_urlport", "080"),
+	"boolean literal":       withField(fullEvent, "br_cookies", "true"),
+	"same context in both":  withField(fullEvent, "derived_contexts", contextsString),
+	"repeated context":      withField(fullEvent, "contexts", ctxt),
+	"empty contexts":        withField(fullEvent, "contexts", `{"schema":"iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-0","data":[]}`),
+	"null contexts":         withField(fullEvent, "contexts", `null`),
+	"null context data":     withField(fullEvent, "contexts", `{"data":[{"schema":"iglu:com.acme/test_context/jsonschema/1-0-0","data":null}]}`),
+	"uppercase envelope":    withField(fullEvent, "contexts", `{"SCHEMA":"iglu:x/y/jsonschema/1-0-0","Data":[{"Schema":"iglu:com.acme/test_context/jsonschema/1-0-0","DATA":{"a":1}}]}`),
+	"whitespace envelope":   withField(fullEvent, "unstruct_event", " { \"data\" : { \"data\" : { \"key\" : [ 1 , 2.5 , -3e2 , true , null ] } , \"schema\" : \"iglu:com.acme/event/jsonschema/1-0-0\" } } "),
+	"unicode escape schema": withField(fullEvent, "unstruct_event", `{"data":{"data":{"key":"value"},"schema":"iglu:com.acme\/event\/jsonschema\/1-0-0"}}`),
+	"camel case schema":     withField(fullEvent, "unstruct_event", `{"data":{"data":{},"schema":"iglu:Com.Acme/SomeEvent_Name/jsonschema/10-0-1"}}`),
+	"null unstruct data":    withField(fullEvent, "unstruct_event", `{"data":{"schema":"iglu:com.acme/event/jsonschema/1-0-0","data":null}}`),
+}
+
+var encoderInvalidEvents = map[string]ParsedEvent{
+	"invalid int":           withField(fullEvent, "page_urlport", "eighty"),
+	"invalid double":        withField(fullEvent, "geo_latitude", "north"),
+	"nan double":            withField(fullEvent, "geo_latitude", "NaN"),
+	"invalid bool":          withField(fullEvent, "br_cookies", "maybe"),
+	"invalid timestamp":     withField(fullEvent, "collector_tstamp", "yesterday"),
+	"invalid context":       withField(fullEvent, "contexts", invalidCtxt),
+	"invalid unstruct":      withField(fullEvent, "unstruct_event", invalidUnstruct),
+	"malformed contexts":    withField(fullEvent, "contexts", `{"data":[{"schema":"iglu:com.acme/test_context/jsonschema/1-0-0","data":{"a":}}]}`),
+	"trailing content":      withField(fullEvent, "contexts", ctxt+`}`),
+	"contexts not array":    withField(fullEvent, "contexts", `{"data":{}}`),
+	"context data array":    withField(fullEvent, "contexts", `{"data":[{"schema":"iglu:com.acme/test_context/jsonschema/1-0-0","data":[1]}]}`),
+	"unstruct not object":   withField(fullEvent, "unstruct_event", `[]`),
+	"missing unstruct data": withField(fullEvent, "unstruct_event", `{"schema":"iglu:com.snowplowanalytics.snowplow/unstruct_event/jsonschema/1-0-0"}`),
+	"unterminated string":   withField(fullEvent, "unstruct_event", `{"data":{"schema":"iglu:com.acme/event/jsonschema/1-0-0","data":{"a":"b}}}`),
+	"invalid number":        withField(fullEvent, "unstruct_event", `{"data":{"schema":"iglu:com.acme/event/jsonschema/1-0-0","data":{"a":01}}}`),
+}
+
+func TestAppendJson(t *testing.T) {
+	assert := assert.New(t)
+
+	for name, event := range encoderTestEvents {
+		for _, withGeo := range []bool{false, true} {
+			expected, err := marshalViaMap(event, withGeo)
+			assert.Nil(err, name)
+			expectedOrdered, _ := orderJson(expected)
+
+			var actual []byte
+			if withGeo {
+				actual, err = event.AppendJsonWithGeo(nil)
+			} else {
+				actual, err = event.AppendJson(nil)
+			}
+			assert.Nil(err, name)
+			actualOrdered, err := orderJson(actual)
+			assert.Nil(err, name)
+			assert.Equal(string(expectedOrdered), string(actualOrdered), name)
+		}
+	}
+
+	for name, event := range encoderInvalidEvents {
+		_, expectedErr := marshalViaMap(event, false)
+		assert.NotNil(expectedErr, name)
+
+		out, err := event.AppendJson([]byte("prefix"))
+		assert.NotNil(err, name)
+		assert.Equal("prefix", string(out), name)
+	}
+
+	// errors for invalid schemas match those of ToMap
+	_, expectedErr := encoderInvalidEvents["invalid context"].ToMap()
+	_, err := encoderInvalidEvents["invalid context"].AppendJson(nil)
+	assert.Equal(expectedErr, err)
+
+	// buffer reuse
+	buf := []byte(`[`)
+	buf, err = fullEvent.AppendJson(buf)
+	assert.Nil(err)
+	buf = append(buf, ',')
+	buf, err = fullEvent.AppendJson(buf)
+	assert.Nil(err)
+	buf = append(buf, ']')
+	var decoded []map[string]any
+	assert.Nil(json.Unmarshal(buf, &decoded))
+	assert.Len(decoded, 2)
+
+	// incorrect input length
+	out, err := ParsedEvent([]string{"one", "two"}).AppendJson(nil)
+	assert.NotNil(err)
+	assert.Nil(out)
+}
+
+func TestAppendJsonAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	buf := make([]byte, 0, 8192)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = fullEvent.AppendJson(buf[:0])
+	})
+	assert.Zero(t, allocs)
+}
+
+func BenchmarkAppendJson(b *testing.B) {
+	b.ReportAllocs()
+	var buf []byte
+	for i := 0; i < b.N; i++ {
+		buf, _ = fullEvent.AppendJson(buf[:0])
+	}
+}
+
+func BenchmarkToJsonViaMap(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		marshalViaMap(fullEvent, false)
+	}
+}
+
+func TestSplitSchemaURI(t *testing.T) {
+	assert := assert.New(t)
+
+	pattern := regexp.MustCompile(SCHEMA_URI_REGEX)
+	uris := []string{
+		"iglu:com.acme/event/jsonschema/1-0-0",
+		"iglu:com.acme.data/some_event/jsonschema/15-34-1",
+		"iglu:com-acme_x/Some-Event_1/json-schema_x/10-10-100",
+		"iglu:com.acme/event/jsonschema/0-0-0",
+		"iglu:com.acme/event/jsonschema/01-0-0",
+		"iglu:com.acme/event/jsonschema/1-01-0",
+		"iglu:com.acme/event/jsonschema/1-0-00",
+		"iglu:com.acme/event/jsonschema/1-0",
+		"iglu:com.acme/event/jsonschema/1-0-0-0",
+		"iglu:com.acme/event/jsonschema/1-0-",
+		"iglu:com.acme/event/jsonschema/1--0",
+		"iglu:com.acme/ev.ent/jsonschema/1-0-0",
+		"iglu:com.acme/event/json.schema/1-0-0",
+		"iglu:com.acme/event/jsonschema/1-0-0/",
+		"iglu:com.acme/event/jsonschema",
+		"iglu:com.acme//jsonschema/1-0-0",
+		"iglu:/event/jsonschema/1-0-0",
+		"iglu:com/acme/event/jsonschema/1-0-0",
+		"iglu:com.acme/event/jsonschema/1-0-0 ",
+		"iglu:com.acme/évent/jsonschema/1-0-0",
+		"IGLU:com.acme/event/jsonschema/1-0-0",
+		"com.acme/event/jsonschema/1-0-0",
+		"iglu:",
+		"",
+	}
+	for _, uri := range uris {
+		parts, ok := splitSchemaURI(uri)
+		match := pattern.FindStringSubmatch(uri)
+		assert.Equal(match != nil, ok, uri)
+		if match != nil {
+			assert.Equal(SchemaParts{match[1], match[2], match[3], match[4], match[5], match[6]}, parts, uri)
+		}
+	}
+}
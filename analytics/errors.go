@@ -0,0 +1,129 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrEmptyField is the cause of errors for fields that are empty.
+	ErrEmptyField = errors.New(EmptyFieldErr)
+	// ErrUnknownField is returned for keys that are not atomic fields.
+	ErrUnknownField = errors.New("not a valid atomic field")
+)
+
+// maxErrorValueLength is the length beyond which values are truncated in error messages.
+const maxErrorValueLength int = 64
+
+// FieldParseError is returned when the value of an atomic field cannot be parsed. Index is the column of the field,
+// or -1 when the field was parsed without an event. Cause is ErrEmptyField for empty fields.
+type FieldParseError struct {
+	Field string
+	Index int
+	Value string
+	Cause error
+}
+
+func (e *FieldParseError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("error parsing field '%s': %v", e.Field, e.Cause)
+	}
+	value := e.Value
+	if len(value) > maxErrorValueLength {
+		end := maxErrorValueLength
+		for end > 0 && !utf8.RuneStart(value[end]) {
+			end--
+		}
+		value = value[:end] + "..."
+	}
+	return fmt.Sprintf("error parsing field '%s', with value '%s': %v", e.Field, value, e.Cause)
+}
+
+func (e *FieldParseError) Unwrap() error {
+	return e.Cause
+}
+
+// SchemaError is returned for schema URIs of self-describing data which are not valid Iglu URIs.
+type SchemaError struct {
+	Schema string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("schema '%s' does not conform to regular expression '%s'", e.Schema, SCHEMA_URI_REGEX)
+}
+
+// LengthError is returned for events whose number of fields does not match a registered layout. Want is the number of
+// fields of the layout provided to ParseEventWithLayout, and is 0 when the layout is detected from the number of fields,
+// as any registered layout is then accepted.
+type LengthError struct {
+	Got  int
+	Want int
+}
+
+func (e *LengthError) Error() string {
+	if e.Want == 0 {
+		return fmt.Sprintf("wrong number of fields provided: %v", e.Got)
+	}
+	return fmt.Sprintf("wrong number of fields provided: %v, expected %v", e.Got, e.Want)
+}
+
+// FieldErrors is returned along with the partial output of transformations with FieldErrorsOmit or FieldErrorsRaw,
+// listing every field which failed to parse.
+type FieldErrors []*FieldParseError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%v fields failed to parse: %s", len(e), strings.Join(messages, "; "))
+}
+
+// orNil returns the errors as an error, or nil if there are none.
+func (e FieldErrors) orNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e FieldErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// emptyFieldError returns the error for an empty field.
+func emptyFieldError(key string) error {
+	return &FieldParseError{Field: key, Index: -1, Cause: ErrEmptyField}
+}
+
+// columnError returns the error of parsing a column of an event as a *FieldParseError with the index of the column,
+// wrapping the errors of custom parse functions and shredding.
+func columnError(err error, field string, index int, value string) *FieldParseError {
+	var parseErr *FieldParseError
+	if errors.As(err, &parseErr) {
+		if parseErr.Index < 0 {
+			parseErr.Index = index
+		}
+		return parseErr
+	}
+	return &FieldParseError{Field: field, Index: index, Value: value, Cause: err}
+}
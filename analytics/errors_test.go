@@ -0,0 +1,115 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	// lengths
+	_, err := ParseEvent("one\ttwo")
+	var lengthErr *LengthError
+	assert.True(errors.As(err, &lengthErr))
+	assert.Equal(&LengthError{Got: 2}, lengthErr)
+	assert.EqualError(err, "cannot parse tsv event - wrong number of fields provided: 2")
+	_, err = ParsedEvent{"one", "two"}.ToMap()
+	assert.True(errors.As(err, &lengthErr))
+	_, err = ParseEventWithLayout(tsvEvent, LayoutNoTrueTstamp)
+	assert.True(errors.As(err, &lengthErr))
+	assert.Equal(&LengthError{Got: 131, Want: 130}, lengthErr)
+	assert.EqualError(err, "cannot parse tsv event - layout no_true_tstamp: wrong number of fields provided: 131, expected 130")
+
+	// invalid values, with the index of their column
+	invalid := withField(fullEvent, "page_urlport", "eighty")
+	for name, transform := range map[string]func() error{
+		"ToMap":        func() error { _, err := invalid.ToMap(); return err },
+		"ToMapWithGeo": func() error { _, err := invalid.ToMapWithGeo(); return err },
+		"Transformer":  func() error { _, err := mustTransformer(WithNullFields()).ToMap(invalid); return err },
+		"ToJson":       func() error { _, err := invalid.ToJson(); return err },
+		"ToStruct":     func() error { _, err := invalid.ToStruct(); return err },
+		"ToLoaderRow":  func() error { _, err := invalid.ToLoaderRow(LoaderBigQuery); return err },
+		"GetValue":     func() error { _, err := invalid.GetValue("page_urlport"); return err },
+		"GetSubsetMap": func() error { _, err := invalid.GetSubsetMap("app_id", "page_urlport"); return err },
+	} {
+		err := transform()
+		var parseErr *FieldParseError
+		assert.True(errors.As(err, &parseErr), name)
+		assert.Equal("page_urlport", parseErr.Field, name)
+		assert.Equal(int(indexMap["page_urlport"]), parseErr.Index, name)
+		assert.Equal("eighty", parseErr.Value, name)
+		assert.True(errors.Is(err, strconv.ErrSyntax), name)
+	}
+	_, err = invalid.ToMap()
+	assert.EqualError(err, `error parsing field 'page_urlport', with value 'eighty': strconv.Atoi: parsing "eighty": invalid syntax`)
+
+	// empty fields
+	_, err = withField(fullEvent, "app_id", "").GetValue("app_id")
+	assert.True(errors.Is(err, ErrEmptyField))
+	assert.EqualError(err, EmptyFieldErr)
+	_, err = parseInt("page_urlport", "")
+	assert.True(errors.Is(err, ErrEmptyField))
+	assert.EqualError(err, "error parsing field 'page_urlport': field is empty")
+	value, err := fullEvent.GetContextValue("contexts_org_schema_web_page_1", "author")
+	assert.Nil(err)
+	assert.NotNil(value)
+
+	// unknown fields
+	_, err = fullEvent.GetValue("not_a_field")
+	assert.True(errors.Is(err, ErrUnknownField))
+	_, err = fullEvent.GetSubsetMap("app_id", "not_a_field")
+	assert.True(errors.Is(err, ErrUnknownField))
+	_, err = NewTransformer(WithFields("not_a_field"))
+	assert.True(errors.Is(err, ErrUnknownField))
+
+	// invalid schemas, in contexts and unstruct events
+	for _, event := range []ParsedEvent{withField(fullEvent, "contexts", invalidCtxt), withField(fullEvent, "unstruct_event", invalidUnstruct)} {
+		for _, transform := range []func() error{
+			func() error { _, err := event.ToMap(); return err },
+			func() error { _, err := event.ToJson(); return err },
+			func() error { _, err := mustTransformer(WithNamingStrategy(BigQueryNaming)).ToMap(event); return err },
+		} {
+			err := transform()
+			var schemaErr *SchemaError
+			assert.True(errors.As(err, &schemaErr))
+			assert.Equal("fail", schemaErr.Schema)
+			var parseErr *FieldParseError
+			assert.True(errors.As(err, &parseErr))
+		}
+	}
+	_, err = shredContexts(invalidCtxt, nil)
+	assert.True(errors.As(err, new(*SchemaError)))
+	_, err = shredUnstruct(invalidUnstruct, nil)
+	assert.True(errors.As(err, new(*SchemaError)))
+	_, err = ContextsKey("fail")
+	assert.True(errors.As(err, new(*SchemaError)))
+
+	// long values are truncated in messages
+	err = &FieldParseError{Field: "contexts", Index: 52, Value: strings.Repeat("é", 40), Cause: errors.New("invalid")}
+	assert.Equal("error parsing field 'contexts', with value '"+strings.Repeat("é", 32)+"...': invalid", err.Error())
+}
+
+func BenchmarkFieldParseError(b *testing.B) {
+	invalid := withField(fullEvent, "page_urlport", "eighty")
+	for i := 0; i < b.N; i++ {
+		invalid.ToMap()
+	}
+}
@@ -0,0 +1,772 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EnrichedEvent is a strongly-typed representation of a Snowplow enriched event.
+// Nullable columns are pointers and are nil when the column is empty. The collector_tstamp, event_id,
+// v_collector and v_etl columns are always populated by the pipeline, so they are plain values and decoding fails if they are empty.
+type EnrichedEvent struct {
+	AppID                  *string        `json:"app_id,omitempty"`
+	Platform               *string        `json:"platform,omitempty"`
+	EtlTstamp              *time.Time     `json:"etl_tstamp,omitempty"`
+	CollectorTstamp        time.Time      `json:"collector_tstamp"`
+	DvceCreatedTstamp      *time.Time     `json:"dvce_created_tstamp,omitempty"`
+	Event                  *string        `json:"event,omitempty"`
+	EventID                string         `json:"event_id"`
+	TxnID                  *int           `json:"txn_id,omitempty"`
+	NameTracker            *string        `json:"name_tracker,omitempty"`
+	VTracker               *string        `json:"v_tracker,omitempty"`
+	VCollector             string         `json:"v_collector"`
+	VEtl                   string         `json:"v_etl"`
+	UserID                 *string        `json:"user_id,omitempty"`
+	UserIpaddress          *string        `json:"user_ipaddress,omitempty"`
+	UserFingerprint        *string        `json:"user_fingerprint,omitempty"`
+	DomainUserid           *string        `json:"domain_userid,omitempty"`
+	DomainSessionidx       *int           `json:"domain_sessionidx,omitempty"`
+	NetworkUserid          *string        `json:"network_userid,omitempty"`
+	GeoCountry             *string        `json:"geo_country,omitempty"`
+	GeoRegion              *string        `json:"geo_region,omitempty"`
+	GeoCity                *string        `json:"geo_city,omitempty"`
+	GeoZipcode             *string        `json:"geo_zipcode,omitempty"`
+	GeoLatitude            *float64       `json:"geo_latitude,omitempty"`
+	GeoLongitude           *float64       `json:"geo_longitude,omitempty"`
+	GeoRegionName          *string        `json:"geo_region_name,omitempty"`
+	IPIsp                  *string        `json:"ip_isp,omitempty"`
+	IPOrganization         *string        `json:"ip_organization,omitempty"`
+	IPDomain               *string        `json:"ip_domain,omitempty"`
+	IPNetspeed             *string        `json:"ip_netspeed,omitempty"`
+	PageURL                *string        `json:"page_url,omitempty"`
+	PageTitle              *string        `json:"page_title,omitempty"`
+	PageReferrer           *string        `json:"page_referrer,omitempty"`
+	PageUrlscheme          *string        `json:"page_urlscheme,omitempty"`
+	PageUrlhost            *string        `json:"page_urlhost,omitempty"`
+	PageUrlport            *int           `json:"page_urlport,omitempty"`
+	PageUrlpath            *string        `json:"page_urlpath,omitempty"`
+	PageUrlquery           *string        `json:"page_urlquery,omitempty"`
+	PageUrlfragment        *string        `json:"page_urlfragment,omitempty"`
+	RefrUrlscheme          *string        `json:"refr_urlscheme,omitempty"`
+	RefrUrlhost            *string        `json:"refr_urlhost,omitempty"`
+	RefrUrlport            *int           `json:"refr_urlport,omitempty"`
+	RefrUrlpath            *string        `json:"refr_urlpath,omitempty"`
+	RefrUrlquery           *string        `json:"refr_urlquery,omitempty"`
+	RefrUrlfragment        *string        `json:"refr_urlfragment,omitempty"`
+	RefrMedium             *string        `json:"refr_medium,omitempty"`
+	RefrSource             *string        `json:"refr_source,omitempty"`
+	RefrTerm               *string        `json:"refr_term,omitempty"`
+	MktMedium              *string        `json:"mkt_medium,omitempty"`
+	MktSource              *string        `json:"mkt_source,omitempty"`
+	MktTerm                *string        `json:"mkt_term,omitempty"`
+	MktContent             *string        `json:"mkt_content,omitempty"`
+	MktCampaign            *string        `json:"mkt_campaign,omitempty"`
+	Contexts               *Contexts      `json:"contexts,omitempty"`
+	SeCategory             *string        `json:"se_category,omitempty"`
+	SeAction               *string        `json:"se_action,omitempty"`
+	SeLabel                *string        `json:"se_label,omitempty"`
+	SeProperty             *string        `json:"se_property,omitempty"`
+	SeValue                *string        `json:"se_value,omitempty"`
+	UnstructEvent          *UnstructEvent `json:"unstruct_event,omitempty"`
+	TrOrderid              *string        `json:"tr_orderid,omitempty"`
+	TrAffiliation          *string        `json:"tr_affiliation,omitempty"`
+	TrTotal                *float64       `json:"tr_total,omitempty"`
+	TrTax                  *float64       `json:"tr_tax,omitempty"`
+	TrShipping             *float64       `json:"tr_shipping,omitempty"`
+	TrCity                 *string        `json:"tr_city,omitempty"`
+	TrState                *string        `json:"tr_state,omitempty"`
+	TrCountry              *string        `json:"tr_country,omitempty"`
+	TiOrderid              *string        `json:"ti_orderid,omitempty"`
+	TiSku                  *string        `json:"ti_sku,omitempty"`
+	TiName                 *string        `json:"ti_name,omitempty"`
+	TiCategory             *string        `json:"ti_category,omitempty"`
+	TiPrice                *float64       `json:"ti_price,omitempty"`
+	TiQuantity             *int           `json:"ti_quantity,omitempty"`
+	PpXoffsetMin           *int           `json:"pp_xoffset_min,omitempty"`
+	PpXoffsetMax           *int           `json:"pp_xoffset_max,omitempty"`
+	PpYoffsetMin           *int           `json:"pp_yoffset_min,omitempty"`
+	PpYoffsetMax           *int           `json:"pp_yoffset_max,omitempty"`
+	Useragent              *string        `json:"useragent,omitempty"`
+	BrName                 *string        `json:"br_name,omitempty"`
+	BrFamily               *string        `json:"br_family,omitempty"`
+	BrVersion              *string        `json:"br_version,omitempty"`
+	BrType                 *string        `json:"br_type,omitempty"`
+	BrRenderengine         *string        `json:"br_renderengine,omitempty"`
+	BrLang                 *string        `json:"br_lang,omitempty"`
+	BrFeaturesPdf          *bool          `json:"br_features_pdf,omitempty"`
+	BrFeaturesFlash        *bool          `json:"br_features_flash,omitempty"`
+	BrFeaturesJava         *bool          `json:"br_features_java,omitempty"`
+	BrFeaturesDirector     *bool          `json:"br_features_director,omitempty"`
+	BrFeaturesQuicktime    *bool          `json:"br_features_quicktime,omitempty"`
+	BrFeaturesRealplayer   *bool          `json:"br_features_realplayer,omitempty"`
+	BrFeaturesWindowsmedia *bool          `json:"br_features_windowsmedia,omitempty"`
+	BrFeaturesGears        *bool          `json:"br_features_gears,omitempty"`
+	BrFeaturesSilverlight  *bool          `json:"br_features_silverlight,omitempty"`
+	BrCookies              *bool          `json:"br_cookies,omitempty"`
+	BrColordepth           *string        `json:"br_colordepth,omitempty"`
+	BrViewwidth            *int           `json:"br_viewwidth,omitempty"`
+	BrViewheight           *int           `json:"br_viewheight,omitempty"`
+	OsName                 *string        `json:"os_name,omitempty"`
+	OsFamily               *string        `json:"os_family,omitempty"`
+	OsManufacturer         *string        `json:"os_manufacturer,omitempty"`
+	OsTimezone             *string        `json:"os_timezone,omitempty"`
+	DvceType               *string        `json:"dvce_type,omitempty"`
+	DvceIsmobile           *bool          `json:"dvce_ismobile,omitempty"`
+	DvceScreenwidth        *int           `json:"dvce_screenwidth,omitempty"`
+	DvceScreenheight       *int           `json:"dvce_screenheight,omitempty"`
+	DocCharset             *string        `json:"doc_charset,omitempty"`
+	DocWidth               *int           `json:"doc_width,omitempty"`
+	DocHeight              *int           `json:"doc_height,omitempty"`
+	TrCurrency             *string        `json:"tr_currency,omitempty"`
+	TrTotalBase            *float64       `json:"tr_total_base,omitempty"`
+	TrTaxBase              *float64       `json:"tr_tax_base,omitempty"`
+	TrShippingBase         *float64       `json:"tr_shipping_base,omitempty"`
+	TiCurrency             *string        `json:"ti_currency,omitempty"`
+	TiPriceBase            *float64       `json:"ti_price_base,omitempty"`
+	BaseCurrency           *string        `json:"base_currency,omitempty"`
+	GeoTimezone            *string        `json:"geo_timezone,omitempty"`
+	MktClickid             *string        `json:"mkt_clickid,omitempty"`
+	MktNetwork             *string        `json:"mkt_network,omitempty"`
+	EtlTags                *string        `json:"etl_tags,omitempty"`
+	DvceSentTstamp         *time.Time     `json:"dvce_sent_tstamp,omitempty"`
+	RefrDomainUserid       *string        `json:"refr_domain_userid,omitempty"`
+	RefrDeviceTstamp       *time.Time     `json:"refr_device_tstamp,omitempty"`
+	DerivedContexts        *Contexts      `json:"derived_contexts,omitempty"`
+	DomainSessionid        *string        `json:"domain_sessionid,omitempty"`
+	DerivedTstamp          *time.Time     `json:"derived_tstamp,omitempty"`
+	EventVendor            *string        `json:"event_vendor,omitempty"`
+	EventName              *string        `json:"event_name,omitempty"`
+	EventFormat            *string        `json:"event_format,omitempty"`
+	EventVersion           *string        `json:"event_version,omitempty"`
+	EventFingerprint       *string        `json:"event_fingerprint,omitempty"`
+	TrueTstamp             *time.Time     `json:"true_tstamp,omitempty"`
+}
+
+// ToStruct transforms a valid Snowplow ParsedEvent to an EnrichedEvent.
+// Fields absent from the layout of the event are left nil, and columns unknown to EnrichedEvent are ignored.
+func (event ParsedEvent) ToStruct() (*EnrichedEvent, error) {
+	return defaultTransformer.ToStruct(event)
+}
+
+// ToStruct transforms a valid Snowplow ParsedEvent to an EnrichedEvent, as ParsedEvent.ToStruct does. With a lenient
+// FieldErrorMode, fields which fail to parse are left unset, as the typed fields of EnrichedEvent cannot hold raw
+// strings, and the event is returned along with FieldErrors. Other options do not apply.
+func (t *Transformer) ToStruct(event ParsedEvent) (*EnrichedEvent, error) {
+	layout, err := event.layoutOrErr("transform event")
+	if err != nil {
+		return nil, err
+	}
+	output := &EnrichedEvent{}
+	var fieldErrs FieldErrors
+	for index, value := range event {
+		if err := output.decodeColumn(layout, index, value); err != nil {
+			if err := t.config.fieldErrors.handle(&fieldErrs, err, layout.fields[index].Key, index, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return output, fieldErrs.orNil()
+}
+
+// ParseEventInto parses a Snowplow Enriched event tsv string directly into the provided EnrichedEvent,
+// without building an intermediate ParsedEvent or map. Any previous content of the EnrichedEvent is discarded,
+// and its content is unspecified if an error is returned.
+func ParseEventInto(line string, event *EnrichedEvent) error {
+	fields := strings.Count(line, "\t") + 1
+	layout, ok := LayoutFor(fields)
+	if !ok {
+		return fmt.Errorf("cannot parse tsv event - %w", &LengthError{Got: fields})
+	}
+	*event = EnrichedEvent{}
+	for index := 0; index < fields; index++ {
+		value := line
+		if end := strings.IndexByte(line, '\t'); end >= 0 {
+			value, line = line[:end], line[end+1:]
+		}
+		if err := event.decodeColumn(layout, index, value); err != nil {
+			return columnError(err, layout.fields[index].Key, index, value)
+		}
+	}
+	return nil
+}
+
+// decodeOptional decodes a nullable column, returning nil if the column is empty.
+func decodeOptional[T any](decode func(string, string) (T, error), key string, value string) (*T, error) {
+	if value == "" {
+		return nil, nil
+	}
+	out, err := decode(key, value)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// decodeColumn parses the column at the given index of an event with the provided layout into its EnrichedEvent field.
+func (e *EnrichedEvent) decodeColumn(layout *Layout, index int, value string) error {
+	if layout == LayoutCurrent {
+		return e.decodeField(index, value)
+	}
+	canonical := layout.canonical[index]
+	if canonical < 0 {
+		return nil
+	}
+	return e.decodeField(canonical, value)
+}
+
+// decodeField parses the column at the given index of an enriched event into its EnrichedEvent field.
+func (e *EnrichedEvent) decodeField(index int, value string) error {
+	key := enrichedEventFieldTypes[index].Key
+	var err error
+	switch index {
+	case 0:
+		e.AppID, err = decodeOptional(decodeString, key, value)
+	case 1:
+		e.Platform, err = decodeOptional(decodeString, key, value)
+	case 2:
+		e.EtlTstamp, err = decodeOptional(decodeTime, key, value)
+	case 3:
+		e.CollectorTstamp, err = decodeTime(key, value)
+	case 4:
+		e.DvceCreatedTstamp, err = decodeOptional(decodeTime, key, value)
+	case 5:
+		e.Event, err = decodeOptional(decodeString, key, value)
+	case 6:
+		e.EventID, err = decodeString(key, value)
+	case 7:
+		e.TxnID, err = decodeOptional(decodeInt, key, value)
+	case 8:
+		e.NameTracker, err = decodeOptional(decodeString, key, value)
+	case 9:
+		e.VTracker, err = decodeOptional(decodeString, key, value)
+	case 10:
+		e.VCollector, err = decodeString(key, value)
+	case 11:
+		e.VEtl, err = decodeString(key, value)
+	case 12:
+		e.UserID, err = decodeOptional(decodeString, key, value)
+	case 13:
+		e.UserIpaddress, err = decodeOptional(decodeString, key, value)
+	case 14:
+		e.UserFingerprint, err = decodeOptional(decodeString, key, value)
+	case 15:
+		e.DomainUserid, err = decodeOptional(decodeString, key, value)
+	case 16:
+		e.DomainSessionidx, err = decodeOptional(decodeInt, key, value)
+	case 17:
+		e.NetworkUserid, err = decodeOptional(decodeString, key, value)
+	case 18:
+		e.GeoCountry, err = decodeOptional(decodeString, key, value)
+	case 19:
+		e.GeoRegion, err = decodeOptional(decodeString, key, value)
+	case 20:
+		e.GeoCity, err = decodeOptional(decodeString, key, value)
+	case 21:
+		e.GeoZipcode, err = decodeOptional(decodeString, key, value)
+	case 22:
+		e.GeoLatitude, err = decodeOptional(decodeDouble, key, value)
+	case 23:
+		e.GeoLongitude, err = decodeOptional(decodeDouble, key, value)
+	case 24:
+		e.GeoRegionName, err = decodeOptional(decodeString, key, value)
+	case 25:
+		e.IPIsp, err = decodeOptional(decodeString, key, value)
+	case 26:
+		e.IPOrganization, err = decodeOptional(decodeString, key, value)
+	case 27:
+		e.IPDomain, err = decodeOptional(decodeString, key, value)
+	case 28:
+		e.IPNetspeed, err = decodeOptional(decodeString, key, value)
+	case 29:
+		e.PageURL, err = decodeOptional(decodeString, key, value)
+	case 30:
+		e.PageTitle, err = decodeOptional(decodeString, key, value)
+	case 31:
+		e.PageReferrer, err = decodeOptional(decodeString, key, value)
+	case 32:
+		e.PageUrlscheme, err = decodeOptional(decodeString, key, value)
+	case 33:
+		e.PageUrlhost, err = decodeOptional(decodeString, key, value)
+	case 34:
+		e.PageUrlport, err = decodeOptional(decodeInt, key, value)
+	case 35:
+		e.PageUrlpath, err = decodeOptional(decodeString, key, value)
+	case 36:
+		e.PageUrlquery, err = decodeOptional(decodeString, key, value)
+	case 37:
+		e.PageUrlfragment, err = decodeOptional(decodeString, key, value)
+	case 38:
+		e.RefrUrlscheme, err = decodeOptional(decodeString, key, value)
+	case 39:
+		e.RefrUrlhost, err = decodeOptional(decodeString, key, value)
+	case 40:
+		e.RefrUrlport, err = decodeOptional(decodeInt, key, value)
+	case 41:
+		e.RefrUrlpath, err = decodeOptional(decodeString, key, value)
+	case 42:
+		e.RefrUrlquery, err = decodeOptional(decodeString, key, value)
+	case 43:
+		e.RefrUrlfragment, err = decodeOptional(decodeString, key, value)
+	case 44:
+		e.RefrMedium, err = decodeOptional(decodeString, key, value)
+	case 45:
+		e.RefrSource, err = decodeOptional(decodeString, key, value)
+	case 46:
+		e.RefrTerm, err = decodeOptional(decodeString, key, value)
+	case 47:
+		e.MktMedium, err = decodeOptional(decodeString, key, value)
+	case 48:
+		e.MktSource, err = decodeOptional(decodeString, key, value)
+	case 49:
+		e.MktTerm, err = decodeOptional(decodeString, key, value)
+	case 50:
+		e.MktContent, err = decodeOptional(decodeString, key, value)
+	case 51:
+		e.MktCampaign, err = decodeOptional(decodeString, key, value)
+	case 52:
+		e.Contexts, err = decodeOptional(decodeContexts, key, value)
+	case 53:
+		e.SeCategory, err = decodeOptional(decodeString, key, value)
+	case 54:
+		e.SeAction, err = decodeOptional(decodeString, key, value)
+	case 55:
+		e.SeLabel, err = decodeOptional(decodeString, key, value)
+	case 56:
+		e.SeProperty, err = decodeOptional(decodeString, key, value)
+	case 57:
+		e.SeValue, err = decodeOptional(decodeString, key, value)
+	case 58:
+		e.UnstructEvent, err = decodeOptional(decodeUnstruct, key, value)
+	case 59:
+		e.TrOrderid, err = decodeOptional(decodeString, key, value)
+	case 60:
+		e.TrAffiliation, err = decodeOptional(decodeString, key, value)
+	case 61:
+		e.TrTotal, err = decodeOptional(decodeDouble, key, value)
+	case 62:
+		e.TrTax, err = decodeOptional(decodeDouble, key, value)
+	case 63:
+		e.TrShipping, err = decodeOptional(decodeDouble, key, value)
+	case 64:
+		e.TrCity, err = decodeOptional(decodeString, key, value)
+	case 65:
+		e.TrState, err = decodeOptional(decodeString, key, value)
+	case 66:
+		e.TrCountry, err = decodeOptional(decodeString, key, value)
+	case 67:
+		e.TiOrderid, err = decodeOptional(decodeString, key, value)
+	case 68:
+		e.TiSku, err = decodeOptional(decodeString, key, value)
+	case 69:
+		e.TiName, err = decodeOptional(decodeString, key, value)
+	case 70:
+		e.TiCategory, err = decodeOptional(decodeString, key, value)
+	case 71:
+		e.TiPrice, err = decodeOptional(decodeDouble, key, value)
+	case 72:
+		e.TiQuantity, err = decodeOptional(decodeInt, key, value)
+	case 73:
+		e.PpXoffsetMin, err = decodeOptional(decodeInt, key, value)
+	case 74:
+		e.PpXoffsetMax, err = decodeOptional(decodeInt, key, value)
+	case 75:
+		e.PpYoffsetMin, err = decodeOptional(decodeInt, key, value)
+	case 76:
+		e.PpYoffsetMax, err = decodeOptional(decodeInt, key, value)
+	case 77:
+		e.Useragent, err = decodeOptional(decodeString, key, value)
+	case 78:
+		e.BrName, err = decodeOptional(decodeString, key, value)
+	case 79:
+		e.BrFamily, err = decodeOptional(decodeString, key, value)
+	case 80:
+		e.BrVersion, err = decodeOptional(decodeString, key, value)
+	case 81:
+		e.BrType, err = decodeOptional(decodeString, key, value)
+	case 82:
+		e.BrRenderengine, err = decodeOptional(decodeString, key, value)
+	case 83:
+		e.BrLang, err = decodeOptional(decodeString, key, value)
+	case 84:
+		e.BrFeaturesPdf, err = decodeOptional(decodeBool, key, value)
+	case 85:
+		e.BrFeaturesFlash, err = decodeOptional(decodeBool, key, value)
+	case 86:
+		e.BrFeaturesJava, err = decodeOptional(decodeBool, key, value)
+	case 87:
+		e.BrFeaturesDirector, err = decodeOptional(decodeBool, key, value)
+	case 88:
+		e.BrFeaturesQuicktime, err = decodeOptional(decodeBool, key, value)
+	case 89:
+		e.BrFeaturesRealplayer, err = decodeOptional(decodeBool, key, value)
+	case 90:
+		e.BrFeaturesWindowsmedia, err = decodeOptional(decodeBool, key, value)
+	case 91:
+		e.BrFeaturesGears, err = decodeOptional(decodeBool, key, value)
+	case 92:
+		e.BrFeaturesSilverlight, err = decodeOptional(decodeBool, key, value)
+	case 93:
+		e.BrCookies, err = decodeOptional(decodeBool, key, value)
+	case 94:
+		e.BrColordepth, err = decodeOptional(decodeString, key, value)
+	case 95:
+		e.BrViewwidth, err = decodeOptional(decodeInt, key, value)
+	case 96:
+		e.BrViewheight, err = decodeOptional(decodeInt, key, value)
+	case 97:
+		e.OsName, err = decodeOptional(decodeString, key, value)
+	case 98:
+		e.OsFamily, err = decodeOptional(decodeString, key, value)
+	case 99:
+		e.OsManufacturer, err = decodeOptional(decodeString, key, value)
+	case 100:
+		e.OsTimezone, err = decodeOptional(decodeString, key, value)
+	case 101:
+		e.DvceType, err = decodeOptional(decodeString, key, value)
+	case 102:
+		e.DvceIsmobile, err = decodeOptional(decodeBool, key, value)
+	case 103:
+		e.DvceScreenwidth, err = decodeOptional(decodeInt, key, value)
+	case 104:
+		e.DvceScreenheight, err = decodeOptional(decodeInt, key, value)
+	case 105:
+		e.DocCharset, err = decodeOptional(decodeString, key, value)
+	case 106:
+		e.DocWidth, err = decodeOptional(decodeInt, key, value)
+	case 107:
+		e.DocHeight, err = decodeOptional(decodeInt, key, value)
+	case 108:
+		e.TrCurrency, err = decodeOptional(decodeString, key, value)
+	case 109:
+		e.TrTotalBase, err = decodeOptional(decodeDouble, key, value)
+	case 110:
+		e.TrTaxBase, err = decodeOptional(decodeDouble, key, value)
+	case 111:
+		e.TrShippingBase, err = decodeOptional(decodeDouble, key, value)
+	case 112:
+		e.TiCurrency, err = decodeOptional(decodeString, key, value)
+	case 113:
+		e.TiPriceBase, err = decodeOptional(decodeDouble, key, value)
+	case 114:
+		e.BaseCurrency, err = decodeOptional(decodeString, key, value)
+	case 115:
+		e.GeoTimezone, err = decodeOptional(decodeString, key, value)
+	case 116:
+		e.MktClickid, err = decodeOptional(decodeString, key, value)
+	case 117:
+		e.MktNetwork, err = decodeOptional(decodeString, key, value)
+	case 118:
+		e.EtlTags, err = decodeOptional(decodeString, key, value)
+	case 119:
+		e.DvceSentTstamp, err = decodeOptional(decodeTime, key, value)
+	case 120:
+		e.RefrDomainUserid, err = decodeOptional(decodeString, key, value)
+	case 121:
+		e.RefrDeviceTstamp, err = decodeOptional(decodeTime, key, value)
+	case 122:
+		e.DerivedContexts, err = decodeOptional(decodeContexts, key, value)
+	case 123:
+		e.DomainSessionid, err = decodeOptional(decodeString, key, value)
+	case 124:
+		e.DerivedTstamp, err = decodeOptional(decodeTime, key, value)
+	case 125:
+		e.EventVendor, err = decodeOptional(decodeString, key, value)
+	case 126:
+		e.EventName, err = decodeOptional(decodeString, key, value)
+	case 127:
+		e.EventFormat, err = decodeOptional(decodeString, key, value)
+	case 128:
+		e.EventVersion, err = decodeOptional(decodeString, key, value)
+	case 129:
+		e.EventFingerprint, err = decodeOptional(decodeString, key, value)
+	case 130:
+		e.TrueTstamp, err = decodeOptional(decodeTime, key, value)
+	default:
+		err = fmt.Errorf("cannot decode field - index %v out of range", index)
+	}
+	return err
+}
+
+// fieldValue returns the EnrichedEvent field holding the column at the given index.
+func (e *EnrichedEvent) fieldValue(index int) any {
+	switch index {
+	case 0:
+		return e.AppID
+	case 1:
+		return e.Platform
+	case 2:
+		return e.EtlTstamp
+	case 3:
+		return e.CollectorTstamp
+	case 4:
+		return e.DvceCreatedTstamp
+	case 5:
+		return e.Event
+	case 6:
+		return e.EventID
+	case 7:
+		return e.TxnID
+	case 8:
+		return e.NameTracker
+	case 9:
+		return e.VTracker
+	case 10:
+		return e.VCollector
+	case 11:
+		return e.VEtl
+	case 12:
+		return e.UserID
+	case 13:
+		return e.UserIpaddress
+	case 14:
+		return e.UserFingerprint
+	case 15:
+		return e.DomainUserid
+	case 16:
+		return e.DomainSessionidx
+	case 17:
+		return e.NetworkUserid
+	case 18:
+		return e.GeoCountry
+	case 19:
+		return e.GeoRegion
+	case 20:
+		return e.GeoCity
+	case 21:
+		return e.GeoZipcode
+	case 22:
+		return e.GeoLatitude
+	case 23:
+		return e.GeoLongitude
+	case 24:
+		return e.GeoRegionName
+	case 25:
+		return e.IPIsp
+	case 26:
+		return e.IPOrganization
+	case 27:
+		return e.IPDomain
+	case 28:
+		return e.IPNetspeed
+	case 29:
+		return e.PageURL
+	case 30:
+		return e.PageTitle
+	case 31:
+		return e.PageReferrer
+	case 32:
+		return e.PageUrlscheme
+	case 33:
+		return e.PageUrlhost
+	case 34:
+		return e.PageUrlport
+	case 35:
+		return e.PageUrlpath
+	case 36:
+		return e.PageUrlquery
+	case 37:
+		return e.PageUrlfragment
+	case 38:
+		return e.RefrUrlscheme
+	case 39:
+		return e.RefrUrlhost
+	case 40:
+		return e.RefrUrlport
+	case 41:
+		return e.RefrUrlpath
+	case 42:
+		return e.RefrUrlquery
+	case 43:
+		return e.RefrUrlfragment
+	case 44:
+		return e.RefrMedium
+	case 45:
+		return e.RefrSource
+	case 46:
+		return e.RefrTerm
+	case 47:
+		return e.MktMedium
+	case 48:
+		return e.MktSource
+	case 49:
+		return e.MktTerm
+	case 50:
+		return e.MktContent
+	case 51:
+		return e.MktCampaign
+	case 52:
+		return e.Contexts
+	case 53:
+		return e.SeCategory
+	case 54:
+		return e.SeAction
+	case 55:
+		return e.SeLabel
+	case 56:
+		return e.SeProperty
+	case 57:
+		return e.SeValue
+	case 58:
+		return e.UnstructEvent
+	case 59:
+		return e.TrOrderid
+	case 60:
+		return e.TrAffiliation
+	case 61:
+		return e.TrTotal
+	case 62:
+		return e.TrTax
+	case 63:
+		return e.TrShipping
+	case 64:
+		return e.TrCity
+	case 65:
+		return e.TrState
+	case 66:
+		return e.TrCountry
+	case 67:
+		return e.TiOrderid
+	case 68:
+		return e.TiSku
+	case 69:
+		return e.TiName
+	case 70:
+		return e.TiCategory
+	case 71:
+		return e.TiPrice
+	case 72:
+		return e.TiQuantity
+	case 73:
+		return e.PpXoffsetMin
+	case 74:
+		return e.PpXoffsetMax
+	case 75:
+		return e.PpYoffsetMin
+	case 76:
+		return e.PpYoffsetMax
+	case 77:
+		return e.Useragent
+	case 78:
+		return e.BrName
+	case 79:
+		return e.BrFamily
+	case 80:
+		return e.BrVersion
+	case 81:
+		return e.BrType
+	case 82:
+		return e.BrRenderengine
+	case 83:
+		return e.BrLang
+	case 84:
+		return e.BrFeaturesPdf
+	case 85:
+		return e.BrFeaturesFlash
+	case 86:
+		return e.BrFeaturesJava
+	case 87:
+		return e.BrFeaturesDirector
+	case 88:
+		return e.BrFeaturesQuicktime
+	case 89:
+		return e.BrFeaturesRealplayer
+	case 90:
+		return e.BrFeaturesWindowsmedia
+	case 91:
+		return e.BrFeaturesGears
+	case 92:
+		return e.BrFeaturesSilverlight
+	case 93:
+		return e.BrCookies
+	case 94:
+		return e.BrColordepth
+	case 95:
+		return e.BrViewwidth
+	case 96:
+		return e.BrViewheight
+	case 97:
+		return e.OsName
+	case 98:
+		return e.OsFamily
+	case 99:
+		return e.OsManufacturer
+	case 100:
+		return e.OsTimezone
+	case 101:
+		return e.DvceType
+	case 102:
+		return e.DvceIsmobile
+	case 103:
+		return e.DvceScreenwidth
+	case 104:
+		return e.DvceScreenheight
+	case 105:
+		return e.DocCharset
+	case 106:
+		return e.DocWidth
+	case 107:
+		return e.DocHeight
+	case 108:
+		return e.TrCurrency
+	case 109:
+		return e.TrTotalBase
+	case 110:
+		return e.TrTaxBase
+	case 111:
+		return e.TrShippingBase
+	case 112:
+		return e.TiCurrency
+	case 113:
+		return e.TiPriceBase
+	case 114:
+		return e.BaseCurrency
+	case 115:
+		return e.GeoTimezone
+	case 116:
+		return e.MktClickid
+	case 117:
+		return e.MktNetwork
+	case 118:
+		return e.EtlTags
+	case 119:
+		return e.DvceSentTstamp
+	case 120:
+		return e.RefrDomainUserid
+	case 121:
+		return e.RefrDeviceTstamp
+	case 122:
+		return e.DerivedContexts
+	case 123:
+		return e.DomainSessionid
+	case 124:
+		return e.DerivedTstamp
+	case 125:
+		return e.EventVendor
+	case 126:
+		return e.EventName
+	case 127:
+		return e.EventFormat
+	case 128:
+		return e.EventVersion
+	case 129:
+		return e.EventFingerprint
+	case 130:
+		return e.TrueTstamp
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,114 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToStruct(t *testing.T) {
+	assert := assert.New(t)
+
+	// correct value
+	enriched, err := fullEvent.ToStruct()
+	assert.Nil(err)
+	assert.Equal("<>angry-birds", *enriched.AppID)
+	assert.Equal(tstampValue, enriched.CollectorTstamp)
+	assert.Equal(tstampValue, *enriched.TrueTstamp)
+	assert.Equal("c6ef3124-b53a-4b13-a233-0088f79dcbcb", enriched.EventID)
+	assert.Equal(41828, *enriched.TxnID)
+	assert.Equal(37.443604, *enriched.GeoLatitude)
+	assert.Equal(80, *enriched.PageUrlport)
+	assert.Equal(true, *enriched.BrFeaturesPdf)
+	assert.Equal(false, *enriched.BrFeaturesFlash)
+	assert.Nil(enriched.PageReferrer)
+	assert.Nil(enriched.TiQuantity)
+	assert.Nil(enriched.DvceSentTstamp)
+
+	// self-describing fields
+	assert.Equal("iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1", enriched.UnstructEvent.Data.Schema)
+	assert.Equal("exampleLink", enriched.UnstructEvent.Data.Data["elementId"])
+	assert.Len(enriched.Contexts.Data, 2)
+	assert.Equal("iglu:org.schema/WebPage/jsonschema/1-0-0", enriched.Contexts.Data[0].Schema)
+	assert.Len(enriched.DerivedContexts.Data, 1)
+
+	// incorrect input length
+	failedStruct, err := ParsedEvent([]string{"one", "two"}).ToStruct()
+	assert.NotNil(err)
+	assert.Nil(failedStruct)
+
+	// unparseable field
+	brokenEvent := append(ParsedEvent{}, fullEvent...)
+	brokenEvent[indexMap["page_urlport"]] = "eighty"
+	failedStruct, err = brokenEvent.ToStruct()
+	assert.NotNil(err)
+	assert.Nil(failedStruct)
+
+	// missing required field
+	missingEvent := append(ParsedEvent{}, fullEvent...)
+	missingEvent[indexMap["event_id"]] = ""
+	failedStruct, err = missingEvent.ToStruct()
+	assert.NotNil(err)
+	assert.Nil(failedStruct)
+
+	// invalid context schema
+	invalidEvent := append(ParsedEvent{}, fullEvent...)
+	invalidEvent[indexMap["contexts"]] = invalidCtxt
+	failedStruct, err = invalidEvent.ToStruct()
+	assert.NotNil(err)
+	assert.Nil(failedStruct)
+}
+
+func BenchmarkToStruct(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		fullEvent.ToStruct()
+	}
+}
+
+func TestParseEventInto(t *testing.T) {
+	assert := assert.New(t)
+
+	expected, _ := fullEvent.ToStruct()
+
+	// correct value
+	var enriched EnrichedEvent
+	err := ParseEventInto(tsvEvent, &enriched)
+	assert.Nil(err)
+	assert.Equal(*expected, enriched)
+
+	// previous content is discarded
+	sparseEvent := make([]string, eventLength)
+	copy(sparseEvent, fullEvent)
+	sparseEvent[indexMap["app_id"]] = ""
+	err = ParseEventInto(strings.Join(sparseEvent, "\t"), &enriched)
+	assert.Nil(err)
+	assert.Nil(enriched.AppID)
+
+	// incorrect input
+	err = ParseEventInto("\t\t\t", &enriched)
+	assert.NotNil(err)
+
+	err = ParseEventInto(tsvEvent+"\textra", &enriched)
+	assert.NotNil(err)
+}
+
+func BenchmarkParseEventInto(b *testing.B) {
+	var enriched EnrichedEvent
+	for i := 0; i < b.N; i++ {
+		ParseEventInto(tsvEvent, &enriched)
+	}
+}
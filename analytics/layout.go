@@ -0,0 +1,228 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// FieldType is the type of the values of an atomic field, identifying the built-in ValueParser of the field so that
+// encoders can convert columns without going through it. It is set for each field of the mapping of enriched events,
+// and fields with any other ValueParser are of type FieldCustom.
+type FieldType uint8
+
+const (
+	FieldCustom FieldType = iota
+	FieldString
+	FieldInt
+	FieldDouble
+	FieldBool
+	FieldTime
+	FieldContexts
+	FieldUnstruct
+)
+
+var fieldTypeNames = [...]string{"custom", "string", "int", "double", "bool", "time", "contexts", "unstruct"}
+
+func (t FieldType) String() string {
+	if int(t) < len(fieldTypeNames) {
+		return fieldTypeNames[t]
+	}
+	return fmt.Sprintf("FieldType(%v)", uint8(t))
+}
+
+// Layout describes the columns of enriched event tsv lines produced by a version of the enrich process.
+// As columns have only ever been appended to the enriched event format, layouts are identified by their number of columns.
+type Layout struct {
+	name   string
+	fields []KeyFunctionPair
+	types  []FieldType
+	index  map[string]int
+	// canonical holds the index in enrichedEventFieldTypes of each column, or -1 for columns unknown to it
+	canonical []int
+	latitude  int
+	longitude int
+}
+
+// NewLayout returns a layout made of the provided fields, in column order.
+func NewLayout(name string, fields []KeyFunctionPair) (*Layout, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("cannot create layout %s: no fields provided", name)
+	}
+	layout := &Layout{
+		name:      name,
+		fields:    append([]KeyFunctionPair(nil), fields...),
+		types:     make([]FieldType, len(fields)),
+		index:     make(map[string]int, len(fields)),
+		canonical: make([]int, len(fields)),
+		latitude:  -1,
+		longitude: -1,
+	}
+	for i, field := range fields {
+		if field.ParseFunction == nil {
+			return nil, fmt.Errorf("cannot create layout %s: field %s has no parse function", name, field.Key)
+		}
+		if _, duplicate := layout.index[field.Key]; duplicate {
+			return nil, fmt.Errorf("cannot create layout %s: duplicate field %s", name, field.Key)
+		}
+		layout.index[field.Key] = i
+		layout.types[i] = field.Type
+		layout.canonical[i] = -1
+		if canonical, ok := indexMap[field.Key]; ok {
+			layout.canonical[i] = int(canonical)
+		}
+	}
+	if i, ok := layout.index["geo_latitude"]; ok {
+		layout.latitude = i
+	}
+	if i, ok := layout.index["geo_longitude"]; ok {
+		layout.longitude = i
+	}
+	return layout, nil
+}
+
+// Name returns the name of the layout.
+func (l *Layout) Name() string {
+	return l.name
+}
+
+// Len returns the number of columns of the layout.
+func (l *Layout) Len() int {
+	return len(l.fields)
+}
+
+// Fields returns the names of the columns of the layout, in order.
+func (l *Layout) Fields() []string {
+	names := make([]string, len(l.fields))
+	for i, field := range l.fields {
+		names[i] = field.Key
+	}
+	return names
+}
+
+// Column is an atomic field of a layout.
+type Column struct {
+	Name string
+	Type FieldType
+}
+
+// Columns returns the columns of the layout, in order.
+func (l *Layout) Columns() []Column {
+	columns := make([]Column, len(l.fields))
+	for i, field := range l.fields {
+		columns[i] = Column{Name: field.Key, Type: l.types[i]}
+	}
+	return columns
+}
+
+// Has reports whether the layout has a column for the provided atomic field.
+func (l *Layout) Has(field string) bool {
+	_, ok := l.index[field]
+	return ok
+}
+
+func mustLayout(name string, fields []KeyFunctionPair) *Layout {
+	layout, err := NewLayout(name, fields)
+	if err != nil {
+		panic(err)
+	}
+	return layout
+}
+
+// Built-in layouts. Older layouts lack the most recently added columns of LayoutCurrent.
+var (
+	// LayoutCurrent is the 131 column layout of current enrich versions.
+	LayoutCurrent = mustLayout("current", enrichedEventFieldTypes[:])
+	// LayoutNoTrueTstamp is the 130 column layout preceding the addition of true_tstamp.
+	LayoutNoTrueTstamp = mustLayout("no_true_tstamp", enrichedEventFieldTypes[:130])
+	// LayoutNoEventFingerprint is the 129 column layout preceding the addition of event_fingerprint.
+	LayoutNoEventFingerprint = mustLayout("no_event_fingerprint", enrichedEventFieldTypes[:129])
+	// LayoutNoEventSchemaFields is the 125 column layout preceding the addition of event_vendor, event_name,
+	// event_format and event_version.
+	LayoutNoEventSchemaFields = mustLayout("no_event_schema_fields", enrichedEventFieldTypes[:125])
+	// LayoutNoDerivedFields is the 119 column layout preceding the addition of dvce_sent_tstamp, refr_domain_userid,
+	// refr_device_tstamp, derived_contexts, domain_sessionid and derived_tstamp.
+	LayoutNoDerivedFields = mustLayout("no_derived_fields", enrichedEventFieldTypes[:119])
+)
+
+var (
+	layoutsMu sync.Mutex
+	layouts   atomic.Pointer[map[int]*Layout]
+)
+
+func init() {
+	registered := make(map[int]*Layout)
+	for _, layout := range []*Layout{LayoutCurrent, LayoutNoTrueTstamp, LayoutNoEventFingerprint, LayoutNoEventSchemaFields, LayoutNoDerivedFields} {
+		registered[layout.Len()] = layout
+	}
+	layouts.Store(&registered)
+}
+
+// RegisterLayout makes a layout available for detection by ParseEvent, for example to support columns appended by a
+// newer enrich version. A layout cannot be registered if another layout with the same number of columns already is.
+func RegisterLayout(layout *Layout) error {
+	layoutsMu.Lock()
+	defer layoutsMu.Unlock()
+
+	current := *layouts.Load()
+	if existing, ok := current[layout.Len()]; ok {
+		if existing == layout {
+			return nil
+		}
+		return fmt.Errorf("cannot register layout %s: layout %s already has %v columns", layout.name, existing.name, layout.Len())
+	}
+	registered := make(map[int]*Layout, len(current)+1)
+	for columns, existing := range current {
+		registered[columns] = existing
+	}
+	registered[layout.Len()] = layout
+	layouts.Store(&registered)
+	return nil
+}
+
+// LayoutFor returns the registered layout with the provided number of columns.
+func LayoutFor(columns int) (*Layout, bool) {
+	if columns == eventLength {
+		return LayoutCurrent, true
+	}
+	layout, ok := (*layouts.Load())[columns]
+	return layout, ok
+}
+
+// Layout returns the layout of the event, detected from its number of columns.
+func (event ParsedEvent) Layout() (*Layout, error) {
+	layout, ok := LayoutFor(len(event))
+	if !ok {
+		return nil, fmt.Errorf("no layout registered for events - %w", &LengthError{Got: len(event)})
+	}
+	return layout, nil
+}
+
+// ParseEventWithLayout takes a Snowplow Enriched event tsv string as input, and returns a 'ParsedEvent' typed slice of
+// strings, failing unless the event has the columns of the provided layout. The layout must be registered.
+func ParseEventWithLayout(event string, layout *Layout) (ParsedEvent, error) {
+	if registered, ok := LayoutFor(layout.Len()); !ok || registered != layout {
+		return nil, fmt.Errorf("cannot parse tsv event - layout %s is not registered", layout.name)
+	}
+	record, err := ParseEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	if len(record) != layout.Len() {
+		return nil, fmt.Errorf("cannot parse tsv event - layout %s: %w", layout.name, &LengthError{Got: len(record), Want: layout.Len()})
+	}
+	return record, nil
+}
@@ -0,0 +1,244 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var olderEvent = append(ParsedEvent{}, fullEvent[:LayoutNoEventSchemaFields.Len()]...)
+
+var customLayout = mustLayout("custom", append(append([]KeyFunctionPair{}, enrichedEventFieldTypes[:]...), KeyFunctionPair{"custom_field", parseInt, FieldInt}))
+
+func TestLayoutFor(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, layout := range []*Layout{LayoutCurrent, LayoutNoTrueTstamp, LayoutNoEventFingerprint, LayoutNoEventSchemaFields, LayoutNoDerivedFields} {
+		found, ok := LayoutFor(layout.Len())
+		assert.True(ok)
+		assert.Equal(layout, found)
+	}
+	assert.Equal(131, LayoutCurrent.Len())
+	assert.Equal(enrichedEventFieldTypes[118].Key, LayoutNoDerivedFields.Fields()[118])
+	assert.False(LayoutNoEventSchemaFields.Has("event_vendor"))
+
+	notFound, ok := LayoutFor(2)
+	assert.False(ok)
+	assert.Nil(notFound)
+
+	layout, err := olderEvent.Layout()
+	assert.Nil(err)
+	assert.Equal(LayoutNoEventSchemaFields, layout)
+
+	failedLayout, err := ParsedEvent([]string{"one", "two"}).Layout()
+	assert.NotNil(err)
+	assert.Nil(failedLayout)
+}
+
+func BenchmarkLayoutFor(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		LayoutFor(125)
+	}
+}
+
+func TestNewLayout(t *testing.T) {
+	assert := assert.New(t)
+
+	layout, err := NewLayout("minimal", []KeyFunctionPair{{"app_id", parseString, FieldString}, {"custom_field", parseInt, FieldInt}})
+	assert.Nil(err)
+	assert.Equal("minimal", layout.Name())
+	assert.Equal([]string{"app_id", "custom_field"}, layout.Fields())
+	assert.Equal([]int{0, -1}, layout.canonical)
+	assert.Equal(-1, layout.latitude)
+	assert.Equal([]FieldType{FieldString, FieldInt}, layout.types)
+
+	// fields of custom parse functions default to FieldCustom
+	custom, err := NewLayout("custom_parser", []KeyFunctionPair{{Key: "app_id", ParseFunction: func(key, value string) ([]KeyVal, error) {
+		return []KeyVal{{key, value}}, nil
+	}}})
+	assert.Nil(err)
+	assert.Equal([]FieldType{FieldCustom}, custom.types)
+
+	noFields, err := NewLayout("empty", nil)
+	assert.NotNil(err)
+	assert.Nil(noFields)
+
+	duplicate, err := NewLayout("duplicate", []KeyFunctionPair{{"app_id", parseString, FieldString}, {"app_id", parseString, FieldString}})
+	assert.NotNil(err)
+	assert.Nil(duplicate)
+
+	noParser, err := NewLayout("no_parser", []KeyFunctionPair{{Key: "app_id"}})
+	assert.NotNil(err)
+	assert.Nil(noParser)
+}
+
+func TestRegisterLayout(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(RegisterLayout(customLayout))
+	// registering the same layout again is a no-op
+	assert.Nil(RegisterLayout(customLayout))
+
+	conflicting := mustLayout("conflicting", customLayout.fields)
+	assert.NotNil(RegisterLayout(conflicting))
+
+	event, err := ParseEvent(tsvEvent + "\t42")
+	assert.Nil(err)
+	layout, err := event.Layout()
+	assert.Nil(err)
+	assert.Equal(customLayout, layout)
+
+	customValue, err := event.GetValue("custom_field")
+	assert.Nil(err)
+	assert.Equal(42, customValue)
+
+	mapified, err := event.ToMapWithGeo()
+	assert.Nil(err)
+	assert.Equal(42, mapified["custom_field"])
+	delete(mapified, "custom_field")
+	assert.Equal(eventMapWithGeo, mapified)
+
+	jsonified, err := event.ToJsonWithGeo()
+	assert.Nil(err)
+	expected, err := marshalViaMap(event, true)
+	assert.Nil(err)
+	assert.JSONEq(string(expected), string(jsonified))
+
+	structured, err := event.ToStruct()
+	assert.Nil(err)
+	expectedStruct, err := fullEvent.ToStruct()
+	assert.Nil(err)
+	assert.Equal(expectedStruct, structured)
+}
+
+func TestRegisterSubsetLayout(t *testing.T) {
+	assert := assert.New(t)
+
+	subset := mustLayout("subset", enrichedEventFieldTypes[:5])
+	assert.Nil(RegisterLayout(subset))
+
+	event, err := ParseEvent(strings.Join(fullEvent[:5], "\t"))
+	assert.Nil(err)
+	value, err := event.GetUnstructEventValue("elementId")
+	assert.EqualError(err, EmptyFieldErr)
+	assert.Nil(value)
+
+	lazy, err := ParseLazyEvent(strings.Join(fullEvent[:5], "\t"))
+	assert.Nil(err)
+	value, err = lazy.GetUnstructEventValue("elementId")
+	assert.EqualError(err, EmptyFieldErr)
+	assert.Nil(value)
+}
+
+func TestParseEventWithLayout(t *testing.T) {
+	assert := assert.New(t)
+
+	event, err := ParseEventWithLayout(strings.Join(olderEvent, "\t"), LayoutNoEventSchemaFields)
+	assert.Nil(err)
+	assert.Equal(olderEvent, event)
+
+	wrongLayout, err := ParseEventWithLayout(tsvEvent, LayoutNoEventSchemaFields)
+	assert.NotNil(err)
+	assert.Nil(wrongLayout)
+
+	unregistered := mustLayout("unregistered", enrichedEventFieldTypes[:100])
+	unregisteredEvent, err := ParseEventWithLayout(strings.Join(fullEvent[:100], "\t"), unregistered)
+	assert.NotNil(err)
+	assert.Nil(unregisteredEvent)
+}
+
+func BenchmarkParseEventWithLayout(b *testing.B) {
+	line := strings.Join(olderEvent, "\t")
+	for i := 0; i < b.N; i++ {
+		ParseEventWithLayout(line, LayoutNoEventSchemaFields)
+	}
+}
+
+func TestOlderLayoutEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	// fields present in the layout are parsed as usual
+	appID, err := olderEvent.GetValue("app_id")
+	assert.Nil(err)
+	assert.Equal("<>angry-birds", appID)
+
+	// fields absent from the layout are nil
+	absent, err := olderEvent.GetValue("event_vendor")
+	assert.Nil(err)
+	assert.Nil(absent)
+
+	unknown, err := olderEvent.GetValue("not_a_field")
+	assert.NotNil(err)
+	assert.Nil(unknown)
+
+	subset, err := olderEvent.GetSubsetMap("app_id", "true_tstamp")
+	assert.Nil(err)
+	assert.Equal(map[string]any{"app_id": "<>angry-birds"}, subset)
+
+	unstructValue, err := olderEvent.GetUnstructEventValue("targetUrl")
+	assert.Nil(err)
+	assert.Equal("http://www.example.com", unstructValue)
+
+	mapified, err := olderEvent.ToMapWithGeo()
+	assert.Nil(err)
+	expectedMap := make(map[string]any)
+	for key, value := range eventMapWithGeo {
+		expectedMap[key] = value
+	}
+	for _, field := range enrichedEventFieldTypes[LayoutNoEventSchemaFields.Len():] {
+		delete(expectedMap, field.Key)
+	}
+	assert.Equal(expectedMap, mapified)
+
+	jsonified, err := olderEvent.ToJsonWithGeo()
+	assert.Nil(err)
+	expectedJson, err := json.Marshal(expectedMap)
+	assert.Nil(err)
+	assert.JSONEq(string(expectedJson), string(jsonified))
+
+	structured, err := olderEvent.ToStruct()
+	assert.Nil(err)
+	assert.NotNil(structured.DerivedTstamp)
+	assert.Nil(structured.EventVendor)
+	assert.Nil(structured.TrueTstamp)
+
+	var parsedInto EnrichedEvent
+	assert.Nil(ParseEventInto(strings.Join(olderEvent, "\t"), &parsedInto))
+	assert.Equal(structured, &parsedInto)
+
+	tsv, err := olderEvent.ToTSV()
+	assert.Nil(err)
+	assert.Equal(strings.Join(olderEvent, "\t"), tsv)
+}
+
+func BenchmarkOlderLayoutToJson(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		olderEvent.ToJson()
+	}
+}
+
+func TestLayoutColumns(t *testing.T) {
+	assert := assert.New(t)
+
+	columns := LayoutCurrent.Columns()
+	assert.Len(columns, 131)
+	assert.Equal(Column{Name: "app_id", Type: FieldString}, columns[0])
+	assert.Equal(Column{Name: "collector_tstamp", Type: FieldTime}, columns[3])
+	assert.Equal(Column{Name: "contexts", Type: FieldContexts}, columns[52])
+	assert.Equal(Column{Name: "custom_field", Type: FieldInt}, customLayout.Columns()[131])
+	assert.Equal("unstruct", FieldUnstruct.String())
+}
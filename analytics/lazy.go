@@ -0,0 +1,357 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/snowplow/snowplow-golang-analytics-sdk/iglu"
+)
+
+// LazyEvent is an enriched event which keeps its tsv line and the offsets of its columns, rather than splitting the
+// line into a string per column. Columns are only parsed when accessed, and decoded contexts and unstruct events are
+// cached for repeated accesses. A LazyEvent is not safe for concurrent use.
+type LazyEvent struct {
+	line string
+	// raw holds the line of events parsed with ParseLazyEventBytes, sharing its memory with line
+	raw    []byte
+	layout *Layout
+	// offsets holds the start of each column, followed by the length of the line plus one
+	offsets []int32
+	// contexts holds the decoded contexts columns, by column index
+	contexts map[int]*lazyContexts
+	// unstruct holds the decoded unstruct_event column, once decoded
+	unstruct *lazyUnstruct
+}
+
+// lazyContexts is a decoded contexts column, with the shredded key of each of its entries.
+type lazyContexts struct {
+	contexts Contexts
+	keys     []string
+}
+
+// lazyUnstruct is a decoded unstruct_event column, as a generic JSON value for path lookups.
+type lazyUnstruct struct {
+	document any
+	event    SelfDescribingData
+	key      string
+}
+
+// ParseLazyEvent takes a Snowplow Enriched event tsv string as input, and returns a LazyEvent holding the offsets of
+// its columns. The layout of the event is detected from its number of fields, as it is by ParseEvent.
+func ParseLazyEvent(line string) (*LazyEvent, error) {
+	if len(line) >= math.MaxInt32 {
+		return nil, fmt.Errorf("cannot parse tsv event - line of %v bytes is too long", len(line))
+	}
+	offsets := make([]int32, 1, eventLength+1)
+	for start := 0; ; {
+		end := strings.IndexByte(line[start:], '\t')
+		if end < 0 {
+			break
+		}
+		start += end + 1
+		offsets = append(offsets, int32(start))
+	}
+	offsets = append(offsets, int32(len(line)+1))
+	layout, ok := LayoutFor(len(offsets) - 1)
+	if !ok {
+		return nil, fmt.Errorf("cannot parse tsv event - %w", &LengthError{Got: len(offsets) - 1})
+	}
+	return &LazyEvent{line: line, layout: layout, offsets: offsets}, nil
+}
+
+// Layout returns the layout of the event.
+func (e *LazyEvent) Layout() *Layout {
+	return e.layout
+}
+
+// column returns the raw value of the column at the given index.
+func (e *LazyEvent) column(index int) string {
+	return e.line[e.offsets[index] : e.offsets[index+1]-1]
+}
+
+// Column returns the raw value of an atomic field, without parsing it, and whether the layout of the event has the field.
+func (e *LazyEvent) Column(field string) (string, bool) {
+	index, ok := e.layout.index[field]
+	if !ok {
+		return "", false
+	}
+	return e.column(index), true
+}
+
+// ColumnBytes returns the raw value of an atomic field as bytes, without parsing it, and whether the layout of the event
+// has the field. For events parsed with ParseLazyEventBytes, the value is a sub-slice of the line; otherwise it is a copy.
+func (e *LazyEvent) ColumnBytes(field string) ([]byte, bool) {
+	index, ok := e.layout.index[field]
+	if !ok {
+		return nil, false
+	}
+	start, end := int(e.offsets[index]), int(e.offsets[index+1])-1
+	if e.raw != nil {
+		return e.raw[start:end:end], true
+	}
+	return []byte(e.line[start:end]), true
+}
+
+// ParsedEvent returns the event as a ParsedEvent, whose columns share the memory of the line.
+func (e *LazyEvent) ParsedEvent() ParsedEvent {
+	event := make(ParsedEvent, e.layout.Len())
+	for index := range event {
+		event[index] = e.column(index)
+	}
+	return event
+}
+
+// GetValue returns the value for a provided atomic field, as ParsedEvent.GetValue does. Contexts and unstruct events
+// are decoded once, and cached for later accesses.
+func (e *LazyEvent) GetValue(field string) (any, error) {
+	index, present, err := e.layout.fieldIndex(field)
+	if err != nil || !present {
+		return nil, err
+	}
+	value := e.column(index)
+	if value == "" {
+		return nil, ErrEmptyField
+	}
+	switch e.layout.types[index] {
+	case FieldContexts:
+		decoded, err := e.decodedContexts(index)
+		if err != nil {
+			return nil, err
+		}
+		output := make(map[string]any)
+		for i, entry := range decoded.contexts.Data {
+			existing, _ := output[decoded.keys[i]].([]any)
+			output[decoded.keys[i]] = append(existing, entry.Data)
+		}
+		return output, nil
+	case FieldUnstruct:
+		decoded, err := e.decodedUnstruct(index)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{decoded.key: decoded.event.Data}, nil
+	}
+	kvPairs, err := e.layout.fields[index].ParseFunction(e.layout.fields[index].Key, value)
+	if err != nil {
+		return nil, columnError(err, field, index, value)
+	}
+	if len(kvPairs) == 0 {
+		return nil, nil
+	}
+	return kvPairs[0].Value, nil
+}
+
+// GetUnstructEventValue returns the value at the provided path inside the data of the event's unstruct event, as
+// ParsedEvent.GetUnstructEventValue does. Path elements are object keys as strings, array indexes as ints, and '*'
+// to map the rest of the path over all values of an object or array.
+func (e *LazyEvent) GetUnstructEventValue(path ...any) (any, error) {
+	index, ok := e.layout.index["unstruct_event"]
+	if !ok {
+		return nil, ErrEmptyField
+	}
+	if e.column(index) == "" {
+		return nil, ErrEmptyField
+	}
+	decoded, err := e.decodedUnstruct(index)
+	if err != nil {
+		return nil, err
+	}
+	return lookupPath(decoded.document, append([]any{"data", "data"}, path...))
+}
+
+// GetContextValue returns the values at the provided path inside the contexts and derived contexts of the event
+// shredded under contextName, as ParsedEvent.GetContextValue does. Without a path, the whole contexts are returned.
+func (e *LazyEvent) GetContextValue(contextName string, path ...any) (any, error) {
+	var output []any
+	for _, column := range []string{"contexts", "derived_contexts"} {
+		index, ok := e.layout.index[column]
+		if !ok || e.column(index) == "" {
+			continue
+		}
+		decoded, err := e.decodedContexts(index)
+		if err != nil {
+			return nil, err
+		}
+		for i, entry := range decoded.contexts.Data {
+			if decoded.keys[i] != contextName {
+				continue
+			}
+			if len(path) == 0 {
+				output = append(output, entry.Data)
+				continue
+			}
+			if value, err := lookupPath(entry.Data, path); err == nil {
+				output = append(output, value)
+			}
+		}
+	}
+	return output, nil
+}
+
+// GetContexts returns the contexts and derived contexts of the event whose schema matches the criterion, as
+// ParsedEvent.GetContexts does.
+func (e *LazyEvent) GetContexts(criterion iglu.SchemaCriterion) ([]SelfDescribingData, error) {
+	var output []SelfDescribingData
+	for index, kind := range e.layout.types {
+		if kind != FieldContexts || e.column(index) == "" {
+			continue
+		}
+		decoded, err := e.decodedContexts(index)
+		if err != nil {
+			return nil, err
+		}
+		for _, entity := range decoded.contexts.Data {
+			if criterion.MatchesURI(entity.Schema) {
+				output = append(output, entity)
+			}
+		}
+	}
+	return output, nil
+}
+
+// GetUnstructEvent returns the unstruct event of the event if its schema matches the criterion, and nil otherwise.
+func (e *LazyEvent) GetUnstructEvent(criterion iglu.SchemaCriterion) (*SelfDescribingData, error) {
+	index, ok := e.layout.index["unstruct_event"]
+	if !ok || e.column(index) == "" {
+		return nil, nil
+	}
+	decoded, err := e.decodedUnstruct(index)
+	if err != nil {
+		return nil, err
+	}
+	if !criterion.MatchesURI(decoded.event.Schema) {
+		return nil, nil
+	}
+	event := decoded.event
+	return &event, nil
+}
+
+// decodedContexts returns the decoded contexts column at the given index, decoding it on first access.
+func (e *LazyEvent) decodedContexts(index int) (*lazyContexts, error) {
+	if decoded, ok := e.contexts[index]; ok {
+		return decoded, nil
+	}
+	key, value := e.layout.fields[index].Key, e.column(index)
+	contexts, err := decodeContexts(key, value)
+	if err != nil {
+		return nil, columnError(err, key, index, value)
+	}
+	decoded := &lazyContexts{contexts: contexts, keys: make([]string, len(contexts.Data))}
+	for i, entry := range contexts.Data {
+		if decoded.keys[i], err = fixSchema("contexts", entry.Schema); err != nil {
+			return nil, columnError(fmt.Errorf("error parsing contexts: %w", err), key, index, value)
+		}
+	}
+	if e.contexts == nil {
+		e.contexts = make(map[int]*lazyContexts, 2)
+	}
+	e.contexts[index] = decoded
+	return decoded, nil
+}
+
+// decodedUnstruct returns the decoded unstruct_event column at the given index, decoding it on first access.
+func (e *LazyEvent) decodedUnstruct(index int) (*lazyUnstruct, error) {
+	if e.unstruct != nil {
+		return e.unstruct, nil
+	}
+	key, value := e.layout.fields[index].Key, e.column(index)
+	decoded := &lazyUnstruct{}
+	if err := json.Unmarshal(stringBytes(value), &decoded.document); err != nil {
+		return nil, &FieldParseError{Field: key, Index: index, Value: value, Cause: fmt.Errorf("error unmarshaling unstruct event JSON: %w", err)}
+	}
+	envelope, _ := decoded.document.(map[string]any)
+	inner, ok := envelope["data"].(map[string]any)
+	if !ok {
+		return nil, &FieldParseError{Field: key, Index: index, Value: value, Cause: fmt.Errorf("error unmarshaling unstruct event JSON: data is not an object")}
+	}
+	decoded.event.Schema, _ = inner["schema"].(string)
+	if decoded.event.Data, ok = inner["data"].(map[string]any); !ok && inner["data"] != nil {
+		return nil, &FieldParseError{Field: key, Index: index, Value: value, Cause: fmt.Errorf("error unmarshaling unstruct event JSON: data is not an object")}
+	}
+	var err error
+	if decoded.key, err = fixSchema("unstruct_event", decoded.event.Schema); err != nil {
+		return nil, &FieldParseError{Field: key, Index: index, Value: value, Cause: fmt.Errorf("error parsing unstruct event: %w", err)}
+	}
+	e.unstruct = decoded
+	return decoded, nil
+}
+
+// lookupPath returns the value at a path of object keys and array indexes inside a decoded JSON value. As with
+// jsoniter's Get, a '*' element maps the rest of the path over all values of an object or array, returning an object
+// or array of the values found and leaving out those where the rest of the path is not found. Unlike jsoniter, which
+// wraps them in jsoniter.Any, the values are returned as decoded.
+func lookupPath(value any, path []any) (any, error) {
+	for i, step := range path {
+		found := false
+		switch step := step.(type) {
+		case string:
+			var object map[string]any
+			if object, found = value.(map[string]any); found {
+				value, found = object[step]
+			}
+		case int:
+			var array []any
+			if array, found = value.([]any); found && step >= 0 && step < len(array) {
+				value = array[step]
+			} else {
+				found = false
+			}
+		case int32:
+			if step != '*' {
+				return nil, fmt.Errorf("cannot get value - unsupported path element %v of type %T", step, step)
+			}
+			switch collection := value.(type) {
+			case map[string]any:
+				mapped := make(map[string]any, len(collection))
+				for key, element := range collection {
+					if found, err := lookupPath(element, path[i+1:]); err == nil {
+						mapped[key] = found
+					}
+				}
+				return mapped, nil
+			case []any:
+				mapped := make([]any, 0, len(collection))
+				for _, element := range collection {
+					if found, err := lookupPath(element, path[i+1:]); err == nil {
+						mapped = append(mapped, found)
+					}
+				}
+				return mapped, nil
+			}
+		default:
+			return nil, fmt.Errorf("cannot get value - unsupported path element %v of type %T", step, step)
+		}
+		if !found {
+			return nil, fmt.Errorf("cannot get value - %s not found", formatPath(path[:i+1]))
+		}
+	}
+	return value, nil
+}
+
+func formatPath(path []any) string {
+	var builder strings.Builder
+	for _, step := range path {
+		if key, ok := step.(string); ok {
+			builder.WriteString("." + key)
+		} else if step == any('*') {
+			builder.WriteString("[*]")
+		} else {
+			fmt.Fprintf(&builder, "[%v]", step)
+		}
+	}
+	return strings.TrimPrefix(builder.String(), ".")
+}
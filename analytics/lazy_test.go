@@ -0,0 +1,224 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/snowplow/snowplow-golang-analytics-sdk/iglu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLazyEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	event, err := ParseLazyEvent(tsvEvent)
+	assert.Nil(err)
+	assert.Equal(LayoutCurrent, event.Layout())
+	assert.Equal(fullEvent, event.ParsedEvent())
+	value, ok := event.Column("page_urlport")
+	assert.True(ok)
+	assert.Equal("80", value)
+	_, ok = event.Column("not_a_field")
+	assert.False(ok)
+
+	// older layouts
+	short, err := ParseLazyEvent(strings.Join(fullEvent[:LayoutNoDerivedFields.Len()], "\t"))
+	assert.Nil(err)
+	assert.Equal(LayoutNoDerivedFields, short.Layout())
+	derived, err := short.GetValue("derived_tstamp")
+	assert.Nil(err)
+	assert.Nil(derived)
+
+	// incorrect input
+	_, err = ParseLazyEvent("\t\t\t")
+	assert.True(errors.As(err, new(*LengthError)))
+}
+
+func BenchmarkParseLazyEvent(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ParseLazyEvent(tsvEvent)
+	}
+}
+
+func TestLazyEventGetValue(t *testing.T) {
+	assert := assert.New(t)
+
+	event, err := ParseLazyEvent(tsvEvent)
+	assert.Nil(err)
+
+	// values match those of ParsedEvent
+	for _, field := range LayoutCurrent.Fields() {
+		expected, expectedErr := fullEvent.GetValue(field)
+		actual, err := event.GetValue(field)
+		assert.Equal(expected, actual, field)
+		assert.Equal(expectedErr, err, field)
+	}
+	_, err = event.GetValue("not_a_field")
+	assert.True(errors.Is(err, ErrUnknownField))
+
+	// decoded contexts and unstruct events are cached
+	assert.Len(event.contexts, 2)
+	assert.NotNil(event.unstruct)
+	cached := event.unstruct
+	_, err = event.GetUnstructEventValue("elementId")
+	assert.Nil(err)
+	assert.Same(cached, event.unstruct)
+
+	// invalid values
+	for _, field := range []string{"page_urlport", "contexts", "unstruct_event"} {
+		invalid := withField(fullEvent, field, map[string]string{"page_urlport": "eighty", "contexts": invalidCtxt, "unstruct_event": invalidUnstruct}[field])
+		event, err := ParseLazyEvent(strings.Join(invalid, "\t"))
+		assert.Nil(err)
+		_, err = event.GetValue(field)
+		var parseErr *FieldParseError
+		assert.True(errors.As(err, &parseErr), field)
+		assert.Equal(field, parseErr.Field)
+	}
+	for _, value := range []string{`{"data":[]}`, `{"data":{"schema":"iglu:com.acme/event/jsonschema/1-0-0","data":[]}}`, `{"data"`} {
+		event, err := ParseLazyEvent(strings.Join(withField(fullEvent, "unstruct_event", value), "\t"))
+		assert.Nil(err)
+		_, err = event.GetValue("unstruct_event")
+		assert.NotNil(err, value)
+	}
+}
+
+func BenchmarkLazyEventGetValue(b *testing.B) {
+	event, _ := ParseLazyEvent(tsvEvent)
+	for i := 0; i < b.N; i++ {
+		event.GetValue("app_id")
+		event.GetValue("contexts")
+		event.GetValue("unstruct_event")
+	}
+}
+
+func TestLazyEventGetUnstructEventValue(t *testing.T) {
+	assert := assert.New(t)
+
+	event, err := ParseLazyEvent(tsvEvent)
+	assert.Nil(err)
+	for _, path := range [][]any{{"elementClasses", 0}, {"elementId"}, {"unicodeTest"}, {}} {
+		expected, _ := fullEvent.GetUnstructEventValue(path...)
+		actual, err := event.GetUnstructEventValue(path...)
+		assert.Nil(err)
+		assert.Equal(expected, actual)
+	}
+	for _, path := range [][]any{{"elementClassesBoo", 0}, {"elementClasses", 1}, {"elementId", "nested"}, {1.5}} {
+		value, err := event.GetUnstructEventValue(path...)
+		assert.NotNil(err)
+		assert.Nil(value)
+	}
+
+	// wildcards map the rest of the path over objects and arrays
+	classes, err := event.GetUnstructEventValue("elementClasses", '*')
+	assert.Nil(err)
+	assert.Equal([]any{"foreground"}, classes)
+	all, err := event.GetUnstructEventValue('*')
+	assert.Nil(err)
+	assert.Equal(map[string]any{
+		"elementClasses": []any{"foreground"},
+		"elementId":      "exampleLink",
+		"targetUrl":      "http://www.example.com",
+		"unicodeTest":    "<>angry_birds",
+	}, all)
+	firstClasses, err := event.GetUnstructEventValue('*', 0)
+	assert.Nil(err)
+	assert.Equal(map[string]any{"elementClasses": "foreground"}, firstClasses)
+
+	_, err = event.GetUnstructEventValue("elementClassesBoo")
+	assert.EqualError(err, "cannot get value - data.data.elementClassesBoo not found")
+
+	empty, err := ParseLazyEvent(strings.Join(withField(fullEvent, "unstruct_event", ""), "\t"))
+	assert.Nil(err)
+	_, err = empty.GetUnstructEventValue("elementId")
+	assert.True(errors.Is(err, ErrEmptyField))
+	assert.EqualError(err, EmptyFieldErr)
+}
+
+func BenchmarkLazyEventGetUnstructEventValue(b *testing.B) {
+	event, _ := ParseLazyEvent(tsvEvent)
+	for i := 0; i < b.N; i++ {
+		event.GetUnstructEventValue("elementClasses", 0)
+	}
+}
+
+func TestLazyEventGetContextValue(t *testing.T) {
+	assert := assert.New(t)
+
+	event, err := ParseLazyEvent(tsvEvent)
+	assert.Nil(err)
+	for _, path := range [][]any{{"breadcrumb", 0}, {}, {"breadcrumb", 3}, {"author"}} {
+		expected, expectedErr := fullEvent.GetContextValue("contexts_org_schema_web_page_1", path...)
+		actual, err := event.GetContextValue("contexts_org_schema_web_page_1", path...)
+		assert.Equal(expectedErr, err)
+		assert.Equal(expected, actual)
+	}
+	value, err := event.GetContextValue("contexts_com_acme_unknown_1")
+	assert.Nil(err)
+	assert.Nil(value)
+
+	invalid, err := ParseLazyEvent(strings.Join(withField(fullEvent, "contexts", invalidCtxt), "\t"))
+	assert.Nil(err)
+	_, err = invalid.GetContextValue("contexts_org_schema_web_page_1")
+	assert.True(errors.As(err, new(*SchemaError)))
+}
+
+func BenchmarkLazyEventGetContextValue(b *testing.B) {
+	event, _ := ParseLazyEvent(tsvEvent)
+	for i := 0; i < b.N; i++ {
+		event.GetContextValue("contexts_org_schema_web_page_1", "breadcrumb", 0)
+	}
+}
+
+func TestLazyEventGetContexts(t *testing.T) {
+	assert := assert.New(t)
+
+	event, err := ParseLazyEvent(tsvEvent)
+	assert.Nil(err)
+	all, _ := iglu.ParseSchemaCriterion("iglu:*/*/jsonschema/1-*-*")
+	expected, _ := fullEvent.GetContexts(all)
+	contexts, err := event.GetContexts(all)
+	assert.Nil(err)
+	assert.Equal(expected, contexts)
+
+	linkClick, _ := iglu.ParseSchemaCriterion("iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-*-*")
+	expectedUnstruct, _ := fullEvent.GetUnstructEvent(linkClick)
+	unstruct, err := event.GetUnstructEvent(linkClick)
+	assert.Nil(err)
+	assert.Equal(expectedUnstruct, unstruct)
+	other, _ := iglu.ParseSchemaCriterion("iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/2-*-*")
+	unstruct, err = event.GetUnstructEvent(other)
+	assert.Nil(err)
+	assert.Nil(unstruct)
+}
+
+func BenchmarkParseLazyEventAndGetValue(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		event, _ := ParseLazyEvent(tsvEvent)
+		event.GetValue("event_id")
+		event.GetContextValue("contexts_org_schema_web_page_1", "author")
+		event.GetContextValue("contexts_org_schema_web_page_1", "genre")
+	}
+}
+
+func BenchmarkParseEventAndGetValue(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		event, _ := ParseEvent(tsvEvent)
+		event.GetValue("event_id")
+		event.GetContextValue("contexts_org_schema_web_page_1", "author")
+		event.GetContextValue("contexts_org_schema_web_page_1", "genre")
+	}
+}
@@ -0,0 +1,158 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LoaderFormat selects the warehouse loader whose output ToLoaderRow reproduces.
+type LoaderFormat int
+
+const (
+	// LoaderSnowflake matches the Snowflake loader: contexts and unstruct events are VARIANT columns named with the
+	// model of their schema, holding the data as-is.
+	LoaderSnowflake LoaderFormat = iota
+	// LoaderBigQuery matches the BigQuery loader: contexts and unstruct events are columns named with the full version
+	// of their schema, whose nested field names are converted to snake case. BigQuery arrays cannot hold nulls, so
+	// null array items are dropped.
+	LoaderBigQuery
+	// LoaderDatabricks matches the Databricks loader: contexts and unstruct events are columns named with the full
+	// version of their schema, whose nested field names are converted to snake case. Nulls are kept.
+	LoaderDatabricks
+)
+
+var loaderFormatNames = [...]string{"snowflake", "bigquery", "databricks"}
+
+func (f LoaderFormat) String() string {
+	if f < 0 || int(f) >= len(loaderFormatNames) {
+		return fmt.Sprintf("LoaderFormat(%d)", int(f))
+	}
+	return loaderFormatNames[f]
+}
+
+// ToLoaderRow transforms a valid Snowplow ParsedEvent to the row a warehouse loader would write for it.
+// Every atomic field of the event's layout is present, with nil for empty fields, except the contexts,
+// derived_contexts and unstruct_event fields which are replaced by a column per schema: contexts as arrays and the
+// unstruct event as a single object. As in ToMap, a context found in both contexts and derived_contexts takes its
+// value from derived_contexts.
+func (event ParsedEvent) ToLoaderRow(format LoaderFormat) (map[string]any, error) {
+	return defaultTransformer.ToLoaderRow(event, format)
+}
+
+// ToLoaderRow transforms a valid Snowplow ParsedEvent to the row a warehouse loader would write for it, as
+// ParsedEvent.ToLoaderRow does. With a lenient FieldErrorMode, atomic fields which fail to parse are output as nil, or
+// as their raw string with FieldErrorsRaw, and the row is returned along with FieldErrors. Other options do not apply,
+// the naming of columns following the loader format.
+func (t *Transformer) ToLoaderRow(event ParsedEvent, format LoaderFormat) (map[string]any, error) {
+	var naming NamingStrategy
+	switch format {
+	case LoaderSnowflake:
+		naming = SnowflakeNaming
+	case LoaderBigQuery:
+		naming = BigQueryNaming
+	case LoaderDatabricks:
+		naming = DatabricksNaming
+	default:
+		return nil, fmt.Errorf("cannot transform event - unknown loader format: %v", format)
+	}
+	layout, err := event.layoutOrErr("transform event")
+	if err != nil {
+		return nil, err
+	}
+
+	row := make(map[string]any, len(event))
+	var fieldErrs FieldErrors
+	for index, value := range event {
+		field := layout.fields[index]
+		var kvPairs []KeyVal
+		switch layout.types[index] {
+		case FieldContexts:
+			if value == "" {
+				continue
+			}
+			kvPairs, err = shredContexts(value, naming)
+		case FieldUnstruct:
+			if value == "" {
+				continue
+			}
+			kvPairs, err = shredUnstruct(value, naming)
+		default:
+			if value == "" {
+				row[field.Key] = nil
+				continue
+			}
+			kvPairs, err = field.ParseFunction(field.Key, value)
+		}
+		if err != nil {
+			if err := t.config.fieldErrors.handle(&fieldErrs, err, field.Key, index, value); err != nil {
+				return nil, err
+			}
+			if t.config.fieldErrors == FieldErrorsRaw {
+				row[field.Key] = value
+			} else if layout.types[index] != FieldContexts && layout.types[index] != FieldUnstruct {
+				row[field.Key] = nil
+			}
+			continue
+		}
+		for _, pair := range kvPairs {
+			if format == LoaderSnowflake || (layout.types[index] != FieldContexts && layout.types[index] != FieldUnstruct) {
+				row[pair.Key] = pair.Value
+				continue
+			}
+			row[pair.Key] = normalizeEntity(pair.Value, format == LoaderBigQuery)
+		}
+	}
+	return row, fieldErrs.orNil()
+}
+
+// normalizeEntity converts the keys of objects to snake case, as the BigQuery and Databricks loaders name nested
+// fields, optionally dropping null array items.
+func normalizeEntity(value any, dropNullItems bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		normalized := make(map[string]any, len(v))
+		for key, item := range v {
+			normalized[snakeCase(key)] = normalizeEntity(item, dropNullItems)
+		}
+		return normalized
+	case []any:
+		normalized := make([]any, 0, len(v))
+		for _, item := range v {
+			if item == nil && dropNullItems {
+				continue
+			}
+			normalized = append(normalized, normalizeEntity(item, dropNullItems))
+		}
+		return normalized
+	default:
+		return value
+	}
+}
+
+// snakeCase converts a property name to a column name: camel case is split with underscores, the name is lower
+// cased, and characters other than letters, digits and underscores are replaced with underscores.
+func snakeCase(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '_'
+		}
+	}, insertUnderscores(name))
+}
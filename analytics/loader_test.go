@@ -0,0 +1,153 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToLoaderRow(t *testing.T) {
+	assert := assert.New(t)
+
+	// the ua_parser_context contains null values, kept or dropped in arrays depending on the loader
+	event := append(ParsedEvent{}, fullEvent...)
+	event[indexMap["derived_contexts"]] = `{"schema":"iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-1","data":[{"schema":"iglu:com.snowplowanalytics.snowplow/ua_parser_context/jsonschema/1-0-0","data":{"useragentFamily":"IE","useragentPatch":null,"tags":["a",null]}}]}`
+	event[indexMap["se_category"]] = ""
+
+	// Snowflake keeps the properties of entities as they are, in columns named with the model of their schema
+	snowflakeWebPage := map[string]any{
+		"genre":         "blog",
+		"inLanguage":    "en-US",
+		"datePublished": "2014-11-06T00:00:00Z",
+		"author":        "Fred Blundun",
+		"breadcrumb":    []any{"blog", "releases"},
+		"keywords":      []any{"snowplow", "javascript", "tracker", "event"},
+	}
+	// BigQuery and Databricks convert property names to snake case, in columns named with the full schema version
+	snakeWebPage := map[string]any{
+		"genre":          "blog",
+		"in_language":    "en-US",
+		"date_published": "2014-11-06T00:00:00Z",
+		"author":         "Fred Blundun",
+		"breadcrumb":     []any{"blog", "releases"},
+		"keywords":       []any{"snowplow", "javascript", "tracker", "event"},
+	}
+	snakeLinkClick := map[string]any{
+		"target_url":      "http://www.example.com",
+		"element_classes": []any{"foreground"},
+		"element_id":      "exampleLink",
+		"unicode_test":    "<>angry_birds",
+	}
+	expected := map[LoaderFormat]map[string]any{
+		LoaderSnowflake: {
+			"contexts_org_schema_web_page_1": []any{snowflakeWebPage},
+			"contexts_com_snowplowanalytics_snowplow_ua_parser_context_1": []any{map[string]any{
+				"useragentFamily": "IE",
+				"useragentPatch":  nil,
+				"tags":            []any{"a", nil},
+			}},
+			"unstruct_event_com_snowplowanalytics_snowplow_link_click_1": map[string]any{
+				"targetUrl":      "http://www.example.com",
+				"elementClasses": []any{"foreground"},
+				"elementId":      "exampleLink",
+				"unicodeTest":    "<>angry_birds",
+			},
+		},
+		// BigQuery drops null array items
+		LoaderBigQuery: {
+			"contexts_org_schema_web_page_1_0_0": []any{snakeWebPage},
+			"contexts_com_snowplowanalytics_snowplow_ua_parser_context_1_0_0": []any{map[string]any{
+				"useragent_family": "IE",
+				"useragent_patch":  nil,
+				"tags":             []any{"a"},
+			}},
+			"unstruct_event_com_snowplowanalytics_snowplow_link_click_1_0_1": snakeLinkClick,
+		},
+		LoaderDatabricks: {
+			"contexts_org_schema_web_page_1_0_0": []any{snakeWebPage},
+			"contexts_com_snowplowanalytics_snowplow_ua_parser_context_1_0_0": []any{map[string]any{
+				"useragent_family": "IE",
+				"useragent_patch":  nil,
+				"tags":             []any{"a", nil},
+			}},
+			"unstruct_event_com_snowplowanalytics_snowplow_link_click_1_0_1": snakeLinkClick,
+		},
+	}
+
+	for format, columns := range expected {
+		row, err := event.ToLoaderRow(format)
+		assert.Nil(err)
+		for column, value := range columns {
+			assert.Equal(value, row[column], "%v %s", format, column)
+		}
+
+		// atomic fields keep their names and types
+		assert.Equal("<>angry-birds", row["app_id"], format)
+		assert.Equal(41828, row["txn_id"], format)
+		assert.Equal(37.443604, row["geo_latitude"], format)
+		assert.Equal(true, row["br_features_pdf"], format)
+		assert.Equal(tstampValue, row["collector_tstamp"], format)
+		assert.Contains(row, "se_category")
+		assert.Nil(row["se_category"])
+		assert.NotContains(row, "contexts")
+		assert.NotContains(row, "derived_contexts")
+		assert.NotContains(row, "unstruct_event")
+		assert.NotContains(row, "geo_location")
+	}
+
+	// older layouts only have their own fields, the contexts and unstruct_event columns being replaced by a column per
+	// schema found in them
+	short, err := ParseEvent(strings.Join(fullEvent[:LayoutNoDerivedFields.Len()], "\t"))
+	assert.Nil(err)
+	row, err := short.ToLoaderRow(LoaderBigQuery)
+	assert.Nil(err)
+	replaced := []string{"contexts", "unstruct_event"}
+	shredded := []string{
+		"contexts_org_schema_web_page_1_0_0",
+		"contexts_org_w3_performance_timing_1_0_0",
+		"unstruct_event_com_snowplowanalytics_snowplow_link_click_1_0_1",
+	}
+	for _, column := range shredded {
+		assert.Contains(row, column)
+	}
+	assert.Len(row, LayoutNoDerivedFields.Len()-len(replaced)+len(shredded))
+	assert.NotContains(row, "true_tstamp")
+
+	_, err = fullEvent.ToLoaderRow(LoaderFormat(7))
+	assert.EqualError(err, "cannot transform event - unknown loader format: LoaderFormat(7)")
+	_, err = ParsedEvent{"one"}.ToLoaderRow(LoaderSnowflake)
+	assert.NotNil(err)
+	invalid := append(ParsedEvent{}, fullEvent...)
+	invalid[indexMap["contexts"]] = invalidCtxt
+	_, err = invalid.ToLoaderRow(LoaderSnowflake)
+	assert.NotNil(err)
+}
+
+func TestSnakeCase(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("useragent_family", snakeCase("useragentFamily"))
+	assert.Equal("target_url", snakeCase("targetUrl"))
+	assert.Equal("already_snake", snakeCase("already_snake"))
+	assert.Equal("a_b_c", snakeCase("a-b.c"))
+}
+
+func BenchmarkToLoaderRow(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		fullEvent.ToLoaderRow(LoaderBigQuery)
+	}
+}
@@ -15,137 +15,137 @@ package analytics
 
 // TODO: Investigate if enrichedEventFieldTypes and indexMap can become a single struct to simplify mapping without performance cost.
 
-var enrichedEventFieldTypes = [131]KeyFunctionPair{{"app_id", parseString},
-	{"platform", parseString},
-	{"etl_tstamp", parseTime},
-	{"collector_tstamp", parseTime},
-	{"dvce_created_tstamp", parseTime},
-	{"event", parseString},
-	{"event_id", parseString},
-	{"txn_id", parseInt},
-	{"name_tracker", parseString},
-	{"v_tracker", parseString},
-	{"v_collector", parseString},
-	{"v_etl", parseString},
-	{"user_id", parseString},
-	{"user_ipaddress", parseString},
-	{"user_fingerprint", parseString},
-	{"domain_userid", parseString},
-	{"domain_sessionidx", parseInt},
-	{"network_userid", parseString},
-	{"geo_country", parseString},
-	{"geo_region", parseString},
-	{"geo_city", parseString},
-	{"geo_zipcode", parseString},
-	{"geo_latitude", parseDouble},
-	{"geo_longitude", parseDouble},
-	{"geo_region_name", parseString},
-	{"ip_isp", parseString},
-	{"ip_organization", parseString},
-	{"ip_domain", parseString},
-	{"ip_netspeed", parseString},
-	{"page_url", parseString},
-	{"page_title", parseString},
-	{"page_referrer", parseString},
-	{"page_urlscheme", parseString},
-	{"page_urlhost", parseString},
-	{"page_urlport", parseInt},
-	{"page_urlpath", parseString},
-	{"page_urlquery", parseString},
-	{"page_urlfragment", parseString},
-	{"refr_urlscheme", parseString},
-	{"refr_urlhost", parseString},
-	{"refr_urlport", parseInt},
-	{"refr_urlpath", parseString},
-	{"refr_urlquery", parseString},
-	{"refr_urlfragment", parseString},
-	{"refr_medium", parseString},
-	{"refr_source", parseString},
-	{"refr_term", parseString},
-	{"mkt_medium", parseString},
-	{"mkt_source", parseString},
-	{"mkt_term", parseString},
-	{"mkt_content", parseString},
-	{"mkt_campaign", parseString},
-	{"contexts", parseContexts},
-	{"se_category", parseString},
-	{"se_action", parseString},
-	{"se_label", parseString},
-	{"se_property", parseString},
-	{"se_value", parseString},
-	{"unstruct_event", parseUnstruct},
-	{"tr_orderid", parseString},
-	{"tr_affiliation", parseString},
-	{"tr_total", parseDouble},
-	{"tr_tax", parseDouble},
-	{"tr_shipping", parseDouble},
-	{"tr_city", parseString},
-	{"tr_state", parseString},
-	{"tr_country", parseString},
-	{"ti_orderid", parseString},
-	{"ti_sku", parseString},
-	{"ti_name", parseString},
-	{"ti_category", parseString},
-	{"ti_price", parseDouble},
-	{"ti_quantity", parseInt},
-	{"pp_xoffset_min", parseInt},
-	{"pp_xoffset_max", parseInt},
-	{"pp_yoffset_min", parseInt},
-	{"pp_yoffset_max", parseInt},
-	{"useragent", parseString},
-	{"br_name", parseString},
-	{"br_family", parseString},
-	{"br_version", parseString},
-	{"br_type", parseString},
-	{"br_renderengine", parseString},
-	{"br_lang", parseString},
-	{"br_features_pdf", parseBool},
-	{"br_features_flash", parseBool},
-	{"br_features_java", parseBool},
-	{"br_features_director", parseBool},
-	{"br_features_quicktime", parseBool},
-	{"br_features_realplayer", parseBool},
-	{"br_features_windowsmedia", parseBool},
-	{"br_features_gears", parseBool},
-	{"br_features_silverlight", parseBool},
-	{"br_cookies", parseBool},
-	{"br_colordepth", parseString},
-	{"br_viewwidth", parseInt},
-	{"br_viewheight", parseInt},
-	{"os_name", parseString},
-	{"os_family", parseString},
-	{"os_manufacturer", parseString},
-	{"os_timezone", parseString},
-	{"dvce_type", parseString},
-	{"dvce_ismobile", parseBool},
-	{"dvce_screenwidth", parseInt},
-	{"dvce_screenheight", parseInt},
-	{"doc_charset", parseString},
-	{"doc_width", parseInt},
-	{"doc_height", parseInt},
-	{"tr_currency", parseString},
-	{"tr_total_base", parseDouble},
-	{"tr_tax_base", parseDouble},
-	{"tr_shipping_base", parseDouble},
-	{"ti_currency", parseString},
-	{"ti_price_base", parseDouble},
-	{"base_currency", parseString},
-	{"geo_timezone", parseString},
-	{"mkt_clickid", parseString},
-	{"mkt_network", parseString},
-	{"etl_tags", parseString},
-	{"dvce_sent_tstamp", parseTime},
-	{"refr_domain_userid", parseString},
-	{"refr_device_tstamp", parseTime},
-	{"derived_contexts", parseContexts},
-	{"domain_sessionid", parseString},
-	{"derived_tstamp", parseTime},
-	{"event_vendor", parseString},
-	{"event_name", parseString},
-	{"event_format", parseString},
-	{"event_version", parseString},
-	{"event_fingerprint", parseString},
-	{"true_tstamp", parseTime}}
+var enrichedEventFieldTypes = [131]KeyFunctionPair{{"app_id", parseString, FieldString},
+	{"platform", parseString, FieldString},
+	{"etl_tstamp", parseTime, FieldTime},
+	{"collector_tstamp", parseTime, FieldTime},
+	{"dvce_created_tstamp", parseTime, FieldTime},
+	{"event", parseString, FieldString},
+	{"event_id", parseString, FieldString},
+	{"txn_id", parseInt, FieldInt},
+	{"name_tracker", parseString, FieldString},
+	{"v_tracker", parseString, FieldString},
+	{"v_collector", parseString, FieldString},
+	{"v_etl", parseString, FieldString},
+	{"user_id", parseString, FieldString},
+	{"user_ipaddress", parseString, FieldString},
+	{"user_fingerprint", parseString, FieldString},
+	{"domain_userid", parseString, FieldString},
+	{"domain_sessionidx", parseInt, FieldInt},
+	{"network_userid", parseString, FieldString},
+	{"geo_country", parseString, FieldString},
+	{"geo_region", parseString, FieldString},
+	{"geo_city", parseString, FieldString},
+	{"geo_zipcode", parseString, FieldString},
+	{"geo_latitude", parseDouble, FieldDouble},
+	{"geo_longitude", parseDouble, FieldDouble},
+	{"geo_region_name", parseString, FieldString},
+	{"ip_isp", parseString, FieldString},
+	{"ip_organization", parseString, FieldString},
+	{"ip_domain", parseString, FieldString},
+	{"ip_netspeed", parseString, FieldString},
+	{"page_url", parseString, FieldString},
+	{"page_title", parseString, FieldString},
+	{"page_referrer", parseString, FieldString},
+	{"page_urlscheme", parseString, FieldString},
+	{"page_urlhost", parseString, FieldString},
+	{"page_urlport", parseInt, FieldInt},
+	{"page_urlpath", parseString, FieldString},
+	{"page_urlquery", parseString, FieldString},
+	{"page_urlfragment", parseString, FieldString},
+	{"refr_urlscheme", parseString, FieldString},
+	{"refr_urlhost", parseString, FieldString},
+	{"refr_urlport", parseInt, FieldInt},
+	{"refr_urlpath", parseString, FieldString},
+	{"refr_urlquery", parseString, FieldString},
+	{"refr_urlfragment", parseString, FieldString},
+	{"refr_medium", parseString, FieldString},
+	{"refr_source", parseString, FieldString},
+	{"refr_term", parseString, FieldString},
+	{"mkt_medium", parseString, FieldString},
+	{"mkt_source", parseString, FieldString},
+	{"mkt_term", parseString, FieldString},
+	{"mkt_content", parseString, FieldString},
+	{"mkt_campaign", parseString, FieldString},
+	{"contexts", parseContexts, FieldContexts},
+	{"se_category", parseString, FieldString},
+	{"se_action", parseString, FieldString},
+	{"se_label", parseString, FieldString},
+	{"se_property", parseString, FieldString},
+	{"se_value", parseString, FieldString},
+	{"unstruct_event", parseUnstruct, FieldUnstruct},
+	{"tr_orderid", parseString, FieldString},
+	{"tr_affiliation", parseString, FieldString},
+	{"tr_total", parseDouble, FieldDouble},
+	{"tr_tax", parseDouble, FieldDouble},
+	{"tr_shipping", parseDouble, FieldDouble},
+	{"tr_city", parseString, FieldString},
+	{"tr_state", parseString, FieldString},
+	{"tr_country", parseString, FieldString},
+	{"ti_orderid", parseString, FieldString},
+	{"ti_sku", parseString, FieldString},
+	{"ti_name", parseString, FieldString},
+	{"ti_category", parseString, FieldString},
+	{"ti_price", parseDouble, FieldDouble},
+	{"ti_quantity", parseInt, FieldInt},
+	{"pp_xoffset_min", parseInt, FieldInt},
+	{"pp_xoffset_max", parseInt, FieldInt},
+	{"pp_yoffset_min", parseInt, FieldInt},
+	{"pp_yoffset_max", parseInt, FieldInt},
+	{"useragent", parseString, FieldString},
+	{"br_name", parseString, FieldString},
+	{"br_family", parseString, FieldString},
+	{"br_version", parseString, FieldString},
+	{"br_type", parseString, FieldString},
+	{"br_renderengine", parseString, FieldString},
+	{"br_lang", parseString, FieldString},
+	{"br_features_pdf", parseBool, FieldBool},
+	{"br_features_flash", parseBool, FieldBool},
+	{"br_features_java", parseBool, FieldBool},
+	{"br_features_director", parseBool, FieldBool},
+	{"br_features_quicktime", parseBool, FieldBool},
+	{"br_features_realplayer", parseBool, FieldBool},
+	{"br_features_windowsmedia", parseBool, FieldBool},
+	{"br_features_gears", parseBool, FieldBool},
+	{"br_features_silverlight", parseBool, FieldBool},
+	{"br_cookies", parseBool, FieldBool},
+	{"br_colordepth", parseString, FieldString},
+	{"br_viewwidth", parseInt, FieldInt},
+	{"br_viewheight", parseInt, FieldInt},
+	{"os_name", parseString, FieldString},
+	{"os_family", parseString, FieldString},
+	{"os_manufacturer", parseString, FieldString},
+	{"os_timezone", parseString, FieldString},
+	{"dvce_type", parseString, FieldString},
+	{"dvce_ismobile", parseBool, FieldBool},
+	{"dvce_screenwidth", parseInt, FieldInt},
+	{"dvce_screenheight", parseInt, FieldInt},
+	{"doc_charset", parseString, FieldString},
+	{"doc_width", parseInt, FieldInt},
+	{"doc_height", parseInt, FieldInt},
+	{"tr_currency", parseString, FieldString},
+	{"tr_total_base", parseDouble, FieldDouble},
+	{"tr_tax_base", parseDouble, FieldDouble},
+	{"tr_shipping_base", parseDouble, FieldDouble},
+	{"ti_currency", parseString, FieldString},
+	{"ti_price_base", parseDouble, FieldDouble},
+	{"base_currency", parseString, FieldString},
+	{"geo_timezone", parseString, FieldString},
+	{"mkt_clickid", parseString, FieldString},
+	{"mkt_network", parseString, FieldString},
+	{"etl_tags", parseString, FieldString},
+	{"dvce_sent_tstamp", parseTime, FieldTime},
+	{"refr_domain_userid", parseString, FieldString},
+	{"refr_device_tstamp", parseTime, FieldTime},
+	{"derived_contexts", parseContexts, FieldContexts},
+	{"domain_sessionid", parseString, FieldString},
+	{"derived_tstamp", parseTime, FieldTime},
+	{"event_vendor", parseString, FieldString},
+	{"event_name", parseString, FieldString},
+	{"event_format", parseString, FieldString},
+	{"event_version", parseString, FieldString},
+	{"event_fingerprint", parseString, FieldString},
+	{"true_tstamp", parseTime, FieldTime}}
 
 const latitudeIndex int8 = 22
 const longitudeIndex int8 = 23
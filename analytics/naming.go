@@ -0,0 +1,69 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NamingStrategy returns the key under which shredded self-describing data is output, from its prefix, either
+// "contexts" or "unstruct_event", and the parts of its schema URI.
+// Data whose schemas produce the same key are output together, so a strategy should only produce the same key for
+// schemas whose data share a structure, such as schemas of the same model.
+type NamingStrategy func(prefix string, schema SchemaParts) string
+
+// ElasticsearchNaming is the default NamingStrategy, producing keys with the model of the schema such as
+// contexts_com_acme_my_context_1. Dots of the vendor and camel case of the name are replaced with underscores.
+func ElasticsearchNaming(prefix string, schema SchemaParts) string {
+	return string(appendShreddedKey(nil, prefix, schema))
+}
+
+// BigQueryNaming produces keys with the full version of the schema, such as contexts_com_acme_my_context_1_0_0,
+// as the BigQuery loader names its columns.
+func BigQueryNaming(prefix string, schema SchemaParts) string {
+	key := appendShreddedKey(nil, prefix, schema)
+	return string(append(key, strings.ReplaceAll(schema.Revision, "-", "_")...))
+}
+
+// SnowflakeNaming produces keys with the model of the schema, such as contexts_com_acme_my_context_1, as the
+// Snowflake loader names its VARIANT columns. Contexts are output as arrays, which the loader stores as VARIANT arrays.
+func SnowflakeNaming(prefix string, schema SchemaParts) string {
+	return ElasticsearchNaming(prefix, schema)
+}
+
+// DatabricksNaming produces keys with the full version of the schema, such as contexts_com_acme_my_context_1_0_0,
+// as the Databricks loader names its columns.
+func DatabricksNaming(prefix string, schema SchemaParts) string {
+	return BigQueryNaming(prefix, schema)
+}
+
+// WithNamingStrategy sets the strategy naming the keys of contexts and unstruct events, ElasticsearchNaming by default.
+func WithNamingStrategy(naming NamingStrategy) TransformOption {
+	return func(c *transformConfig) {
+		c.naming = naming
+	}
+}
+
+// shreddedKey returns the key of self-describing data with the provided schema URI, named by the strategy or as fixSchema does if it is nil.
+func shreddedKey(prefix string, schemaUri string, naming NamingStrategy) (string, error) {
+	if naming == nil {
+		return fixSchema(prefix, schemaUri)
+	}
+	parts, err := extractSchema(schemaUri)
+	if err != nil {
+		return "", fmt.Errorf("error parsing schema path: %w", err)
+	}
+	return naming(prefix, parts), nil
+}
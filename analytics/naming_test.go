@@ -0,0 +1,97 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamingStrategies(t *testing.T) {
+	assert := assert.New(t)
+
+	parts, err := extractSchema("iglu:com.acme/myContext/jsonschema/1-2-3")
+	assert.Nil(err)
+	assert.Equal("contexts_com_acme_my_context_1", ElasticsearchNaming("contexts", parts))
+	assert.Equal("contexts_com_acme_my_context_1_2_3", BigQueryNaming("contexts", parts))
+	assert.Equal("contexts_com_acme_my_context_1", SnowflakeNaming("contexts", parts))
+	assert.Equal("unstruct_event_com_acme_my_context_1_2_3", DatabricksNaming("unstruct_event", parts))
+
+	// the default strategy names keys as fixSchema does
+	key, err := fixSchema("contexts", "iglu:com.acme/myContext/jsonschema/1-2-3")
+	assert.Nil(err)
+	assert.Equal(key, ElasticsearchNaming("contexts", parts))
+}
+
+func TestTransformWithNamingStrategy(t *testing.T) {
+	assert := assert.New(t)
+
+	transformer, err := NewTransformer(WithNamingStrategy(BigQueryNaming))
+	assert.Nil(err)
+	mapped, err := transformer.ToMap(fullEvent)
+	assert.Nil(err)
+	assert.Contains(mapped, "contexts_org_schema_web_page_1_0_0")
+	assert.Contains(mapped, "contexts_com_snowplowanalytics_snowplow_ua_parser_context_1_0_0")
+	assert.Contains(mapped, "unstruct_event_com_snowplowanalytics_snowplow_link_click_1_0_1")
+	assert.NotContains(mapped, "contexts_org_schema_web_page_1")
+	assert.Equal(eventMapWithoutGeo["contexts_org_schema_web_page_1"], mapped["contexts_org_schema_web_page_1_0_0"])
+
+	// ToJson and ToMap produce the same keys
+	for _, naming := range []NamingStrategy{ElasticsearchNaming, BigQueryNaming, SnowflakeNaming, DatabricksNaming} {
+		transformer, err := NewTransformer(WithGeoLocation(), WithNamingStrategy(naming))
+		assert.Nil(err)
+		mapped, err := transformer.ToMap(fullEvent)
+		assert.Nil(err)
+		jsonified, err := transformer.ToJson(fullEvent)
+		assert.Nil(err)
+		expected, err := jsoniter.Marshal(mapped)
+		assert.Nil(err)
+		assert.JSONEq(string(expected), string(jsonified))
+	}
+
+	// custom strategies
+	upper := func(prefix string, schema SchemaParts) string {
+		return strings.ToUpper(prefix + "_" + schema.Name)
+	}
+	jsonified, err := mustTransformer(WithNamingStrategy(upper)).ToJson(fullEvent)
+	assert.Nil(err)
+	decoded := make(map[string]any)
+	assert.Nil(jsoniter.Unmarshal(jsonified, &decoded))
+	assert.Contains(decoded, "CONTEXTS_WEBPAGE")
+	assert.Contains(decoded, "UNSTRUCT_EVENT_LINK_CLICK")
+	assert.Equal(eventMapWithoutGeo["unstruct_event_com_snowplowanalytics_snowplow_link_click_1"], decoded["UNSTRUCT_EVENT_LINK_CLICK"])
+
+	// invalid schemas are reported whatever the strategy
+	invalid := append(ParsedEvent{}, fullEvent...)
+	invalid[52] = invalidCtxt
+	_, err = transformer.ToMap(invalid)
+	assert.NotNil(err)
+	_, err = transformer.ToJson(invalid)
+	assert.NotNil(err)
+
+	// batches
+	results, err := TransformBatch(t.Context(), []string{tsvEvent}, BatchOptions{Format: OutputMap, Transformer: transformer})
+	assert.Nil(err)
+	assert.Contains(results[0].Map, "contexts_org_schema_web_page_1_0_0")
+}
+
+func BenchmarkToJsonWithNamingStrategy(b *testing.B) {
+	transformer, _ := NewTransformer(WithNamingStrategy(BigQueryNaming))
+	for n := 0; n < b.N; n++ {
+		transformer.ToJson(fullEvent)
+	}
+}
@@ -0,0 +1,308 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Projection is a set of atomic fields to parse from enriched events. Parsing an event with a projection only splits
+// its line up to the projected columns, from its start or from its end, and counts the other columns to detect its
+// layout, which makes filters and extractions reading a few fields of high volumes of events much cheaper than
+// parsing whole events. A Projection should be built once and reused, and is safe for concurrent use.
+type Projection struct {
+	// fields holds the projected fields, in the column order of LayoutCurrent
+	fields []string
+	// plans holds the *projectionPlan of each layout
+	plans sync.Map
+	// last holds the layout of the last parsed event, whose plan splits the start of the next lines
+	last atomic.Pointer[Layout]
+}
+
+// projectionPlan holds the columns of a layout which a Projection keeps.
+type projectionPlan struct {
+	// columns holds the column index of each projected field in the layout, or -1 for fields absent from it
+	columns []int
+	// slots holds the position in the projection of each column of the layout, or -1 for columns not projected
+	slots []int
+	// front is the number of columns split from the start of lines, and back the first column split from their end
+	front, back int
+}
+
+// NewProjection returns a Projection of the provided atomic fields. As for GetSubsetMap, "contexts",
+// "derived_contexts" and "unstruct_event" select the shredded data of these fields. It fails if fields are not
+// columns of LayoutCurrent.
+func NewProjection(fields ...string) (*Projection, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("cannot create projection: no fields provided")
+	}
+	p := &Projection{}
+	for _, field := range fields {
+		if _, ok := indexMap[field]; !ok {
+			return nil, fmt.Errorf("cannot create projection: key %s %w", field, ErrUnknownField)
+		}
+		if !slices.Contains(p.fields, field) {
+			p.fields = append(p.fields, field)
+		}
+	}
+	slices.SortFunc(p.fields, func(a, b string) int {
+		return int(indexMap[a]) - int(indexMap[b])
+	})
+	p.plans.Store(LayoutCurrent, p.newPlan(LayoutCurrent))
+	p.last.Store(LayoutCurrent)
+	return p, nil
+}
+
+// Fields returns the projected fields, in column order.
+func (p *Projection) Fields() []string {
+	return append([]string(nil), p.fields...)
+}
+
+// newPlan computes the columns of a layout to keep, and splits them between those found from the start of lines and
+// those found from their end so as to scan as few columns as possible.
+func (p *Projection) newPlan(layout *Layout) *projectionPlan {
+	plan := &projectionPlan{columns: make([]int, len(p.fields)), slots: make([]int, layout.Len())}
+	for column := range plan.slots {
+		plan.slots[column] = -1
+	}
+	var kept []int
+	for slot, field := range p.fields {
+		plan.columns[slot] = -1
+		if index, ok := layout.index[field]; ok {
+			plan.columns[slot] = index
+			plan.slots[index] = slot
+			kept = append(kept, index)
+		}
+	}
+	slices.Sort(kept)
+	plan.front, plan.back = 0, layout.Len()
+	for split := 0; split <= len(kept); split++ {
+		front, back := 0, layout.Len()
+		if split > 0 {
+			front = kept[split-1] + 1
+		}
+		if split < len(kept) {
+			back = kept[split]
+		}
+		if front+layout.Len()-back < plan.front+layout.Len()-plan.back || split == 0 {
+			plan.front, plan.back = front, back
+		}
+	}
+	return plan
+}
+
+// plan returns the plan of a layout, computing it on first use.
+func (p *Projection) plan(layout *Layout) *projectionPlan {
+	if plan, ok := p.plans.Load(layout); ok {
+		return plan.(*projectionPlan)
+	}
+	plan, _ := p.plans.LoadOrStore(layout, p.newPlan(layout))
+	return plan.(*projectionPlan)
+}
+
+// Parse takes a Snowplow Enriched event tsv string as input, and returns a ProjectedEvent holding the values of the
+// projected fields. The layout of the event is detected from its number of fields, as it is by ParseEvent, and the
+// values of the event share the memory of the line.
+//
+// Parse scans the line once: it splits the start of the line as the layout of the last parsed event requires, then
+// counts the tabs of the rest of the line, and only splits the columns found from the end of the line once its
+// layout is known. Lines of another layout than the last one are split again with the plan of their layout.
+func (p *Projection) Parse(line string) (*ProjectedEvent, error) {
+	last := p.last.Load()
+	plan := p.plan(last)
+	values := make([]string, len(p.fields))
+	start, columns := 0, 0
+	for columns < plan.front {
+		end := strings.IndexByte(line[start:], '\t')
+		if end < 0 {
+			end = len(line) - start
+		}
+		if slot := plan.slots[columns]; slot >= 0 {
+			values[slot] = line[start : start+end]
+		}
+		start += end + 1
+		columns++
+		if start > len(line) {
+			break
+		}
+	}
+	if start <= len(line) {
+		columns += strings.Count(line[start:], "\t") + 1
+	}
+	layout, ok := LayoutFor(columns)
+	if !ok {
+		return nil, fmt.Errorf("cannot parse tsv event - %w", &LengthError{Got: columns})
+	}
+	if layout != last {
+		p.last.Store(layout)
+		return p.split(line, layout), nil
+	}
+	p.splitBack(line, plan, layout, values)
+	return &ProjectedEvent{projection: p, layout: layout, values: values}, nil
+}
+
+// split returns a ProjectedEvent holding the values of the projected fields of a line of the provided layout.
+func (p *Projection) split(line string, layout *Layout) *ProjectedEvent {
+	plan := p.plan(layout)
+	values := make([]string, len(p.fields))
+	start := 0
+	for column := 0; column < plan.front; column++ {
+		end := strings.IndexByte(line[start:], '\t')
+		if end < 0 {
+			end = len(line) - start
+		}
+		if slot := plan.slots[column]; slot >= 0 {
+			values[slot] = line[start : start+end]
+		}
+		start += end + 1
+	}
+	p.splitBack(line, plan, layout, values)
+	return &ProjectedEvent{projection: p, layout: layout, values: values}
+}
+
+// splitBack sets the values of the projected columns found from the end of a line of the provided layout.
+func (p *Projection) splitBack(line string, plan *projectionPlan, layout *Layout, values []string) {
+	end := len(line)
+	for column := layout.Len() - 1; column >= plan.back; column-- {
+		start := strings.LastIndexByte(line[:end], '\t') + 1
+		if slot := plan.slots[column]; slot >= 0 {
+			values[slot] = line[start:end]
+		}
+		end = start - 1
+	}
+}
+
+// ParseBytes takes a Snowplow Enriched event tsv line as bytes, and returns a ProjectedEvent as Parse does, without
+// copying the line. As for ParseEventBytes, the line must not be modified, nor its buffer reused, while the event or
+// values returned by its methods are in use.
+func (p *Projection) ParseBytes(line []byte) (*ProjectedEvent, error) {
+	return p.Parse(bytesString(line))
+}
+
+// ProjectedEvent is an enriched event parsed with a Projection, which only holds the values of the projected fields.
+// Its methods mirror those of ParsedEvent, and fail for fields outside of the projection.
+type ProjectedEvent struct {
+	projection *Projection
+	layout     *Layout
+	values     []string
+}
+
+// Layout returns the layout of the event.
+func (e *ProjectedEvent) Layout() *Layout {
+	return e.layout
+}
+
+// Column returns the raw value of a projected atomic field, without parsing it, and whether the layout of the event
+// has the field. Fields outside of the projection are reported as absent.
+func (e *ProjectedEvent) Column(field string) (string, bool) {
+	slot, err := e.slot(field)
+	if err != nil || e.plan().columns[slot] < 0 {
+		return "", false
+	}
+	return e.values[slot], true
+}
+
+// slot returns the position of a field in the projection, failing for fields outside of it.
+func (e *ProjectedEvent) slot(field string) (int, error) {
+	if slot := slices.Index(e.projection.fields, field); slot >= 0 {
+		return slot, nil
+	}
+	if _, ok := indexMap[field]; !ok {
+		return -1, fmt.Errorf("key %s %w", field, ErrUnknownField)
+	}
+	return -1, fmt.Errorf("key %s is not projected", field)
+}
+
+func (e *ProjectedEvent) plan() *projectionPlan {
+	return e.projection.plan(e.layout)
+}
+
+// parsedValue parses the value of a projected field with its specific ParseFunction. It returns no value and no
+// error for fields absent from the layout of the event or empty, along with whether the field is present and set.
+func (e *ProjectedEvent) parsedValue(field string) ([]KeyVal, bool, error) {
+	slot, err := e.slot(field)
+	if err != nil {
+		return nil, false, err
+	}
+	index := e.plan().columns[slot]
+	if index < 0 || e.values[slot] == "" {
+		return nil, index >= 0, nil
+	}
+	column := e.layout.fields[index]
+	kvPairs, err := column.ParseFunction(column.Key, e.values[slot])
+	if err != nil {
+		return nil, true, columnError(err, column.Key, index, e.values[slot])
+	}
+	return kvPairs, true, nil
+}
+
+// GetValue returns the value for a projected atomic field, as ParsedEvent.GetValue does.
+// For fields absent from the layout of the event, it returns nil.
+func (e *ProjectedEvent) GetValue(field string) (any, error) {
+	kvPairs, present, err := e.parsedValue(field)
+	if err != nil || !present {
+		return nil, err
+	}
+	if kvPairs == nil {
+		return nil, ErrEmptyField
+	}
+	if field == "contexts" || field == "derived_contexts" || field == "unstruct_event" {
+		output := make(map[string]any)
+		for _, pair := range kvPairs {
+			output[pair.Key] = pair.Value
+		}
+		return output, nil
+	}
+	return kvPairs[0].Value, nil
+}
+
+// GetSubsetMap returns a map of a subset of the projected fields of the event, as ParsedEvent.GetSubsetMap does.
+func (e *ProjectedEvent) GetSubsetMap(fields ...string) (map[string]any, error) {
+	output := make(map[string]any)
+	for _, field := range fields {
+		kvPairs, _, err := e.parsedValue(field)
+		if err != nil {
+			return nil, err
+		}
+		for _, pair := range kvPairs {
+			output[pair.Key] = pair.Value
+		}
+	}
+	return output, nil
+}
+
+// GetSubsetJson returns a JSON object of a subset of the projected fields of the event, as ParsedEvent.GetSubsetJson
+// does.
+func (e *ProjectedEvent) GetSubsetJson(fields ...string) ([]byte, error) {
+	subsetMap, err := e.GetSubsetMap(fields...)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(subsetMap)
+}
+
+// ToMap transforms the projected fields of the event to a Go map, as GetSubsetMap does for all of them. Derived
+// contexts are output in place of contexts sharing their key, as they are by ParsedEvent.ToMap.
+func (e *ProjectedEvent) ToMap() (map[string]any, error) {
+	return e.GetSubsetMap(e.projection.fields...)
+}
+
+// ToJson transforms the projected fields of the event to a JSON object.
+func (e *ProjectedEvent) ToJson() ([]byte, error) {
+	return e.GetSubsetJson(e.projection.fields...)
+}
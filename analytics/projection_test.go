@@ -0,0 +1,256 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var projectedFields = []string{"app_id", "br_features_flash", "br_features_pdf", "collector_tstamp", "contexts", "unstruct_event", "derived_contexts"}
+
+func TestNewProjection(t *testing.T) {
+	assert := assert.New(t)
+
+	projection, err := NewProjection("contexts", "event_id", "collector_tstamp", "event_id")
+	assert.Nil(err)
+	assert.Equal([]string{"collector_tstamp", "event_id", "contexts"}, projection.Fields())
+
+	unknown, err := NewProjection("event_id", "not_a_field")
+	assert.True(errors.Is(err, ErrUnknownField))
+	assert.Nil(unknown)
+
+	empty, err := NewProjection()
+	assert.NotNil(err)
+	assert.Nil(empty)
+}
+
+func TestProjectionParse(t *testing.T) {
+	assert := assert.New(t)
+
+	projection, err := NewProjection(projectedFields...)
+	assert.Nil(err)
+	event, err := projection.Parse(tsvEvent)
+	assert.Nil(err)
+	assert.Equal(LayoutCurrent, event.Layout())
+	value, ok := event.Column("app_id")
+	assert.True(ok)
+	assert.Equal(fullEvent[0], value)
+	_, ok = event.Column("event_id")
+	assert.False(ok)
+
+	fromBytes, err := projection.ParseBytes([]byte(tsvEvent))
+	assert.Nil(err)
+	assert.Equal(event.values, fromBytes.values)
+
+	// the last column
+	last, err := NewProjection("event_fingerprint", "true_tstamp")
+	assert.Nil(err)
+	event, err = last.Parse(tsvEvent)
+	assert.Nil(err)
+	value, _ = event.Column("true_tstamp")
+	assert.Equal(fullEvent[eventLength-1], value)
+
+	// older layouts
+	short, err := projection.Parse(strings.Join(fullEvent[:LayoutNoDerivedFields.Len()], "\t"))
+	assert.Nil(err)
+	assert.Equal(LayoutNoDerivedFields, short.Layout())
+	_, ok = short.Column("derived_contexts")
+	assert.False(ok)
+	derived, err := short.GetValue("derived_contexts")
+	assert.Nil(err)
+	assert.Nil(derived)
+
+	// lines of the layout of the last event are split from their start as they are counted
+	assert.Equal(LayoutNoDerivedFields, projection.last.Load())
+	again, err := projection.Parse(strings.Join(fullEvent[:LayoutNoDerivedFields.Len()], "\t"))
+	assert.Nil(err)
+	assert.Equal(short.values, again.values)
+	current, err := projection.Parse(tsvEvent)
+	assert.Nil(err)
+	assert.Equal(LayoutCurrent, current.Layout())
+	assert.Equal(fromBytes.values, current.values)
+
+	// incorrect input
+	_, err = projection.Parse("\t\t\t")
+	assert.True(errors.As(err, new(*LengthError)))
+	_, err = last.Parse("")
+	assert.True(errors.As(err, new(*LengthError)))
+}
+
+func BenchmarkProjectionParse(b *testing.B) {
+	projection, _ := NewProjection("event_id", "collector_tstamp", "contexts")
+	for i := 0; i < b.N; i++ {
+		projection.Parse(tsvEvent)
+	}
+}
+
+func TestProjectionLayouts(t *testing.T) {
+	assert := assert.New(t)
+
+	projection, err := NewProjection("app_id", "platform", "event_id", "true_tstamp")
+	assert.Nil(err)
+	plan := projection.newPlan(LayoutCurrent)
+	assert.Equal(7, plan.front)
+	assert.Equal(eventLength-1, plan.back)
+
+	// fields absent from a layout, and layouts ordering fields differently from LayoutCurrent
+	layout, err := NewLayout("reordered", []KeyFunctionPair{{"platform", parseString, FieldString}, {"custom_field", parseInt, FieldInt}, {"app_id", parseString, FieldString}})
+	assert.Nil(err)
+	plan = projection.newPlan(layout)
+	assert.Equal([]int{2, 0, -1, -1}, plan.columns)
+	assert.Equal([]int{1, -1, 0}, plan.slots)
+	projection.plans.Store(layout, plan)
+	var event *ProjectedEvent
+	for front := 0; front <= layout.Len(); front++ {
+		plan.front, plan.back = front, front
+		event = projection.split("web\t42\tangry-birds", layout)
+		assert.Equal([]string{"angry-birds", "web", "", ""}, event.values)
+	}
+	_, ok := event.Column("event_id")
+	assert.False(ok)
+	value, ok := event.Column("platform")
+	assert.True(ok)
+	assert.Equal("web", value)
+}
+
+func TestProjectedEventGetValue(t *testing.T) {
+	assert := assert.New(t)
+
+	projection, err := NewProjection("app_id", "collector_tstamp", "contexts", "unstruct_event", "tr_total", "event_fingerprint")
+	assert.Nil(err)
+	event, err := projection.Parse(tsvEvent)
+	assert.Nil(err)
+	for _, field := range []string{"app_id", "collector_tstamp", "contexts", "unstruct_event"} {
+		value, err := event.GetValue(field)
+		assert.Nil(err)
+		expected, err := fullEvent.GetValue(field)
+		assert.Nil(err)
+		assert.Equal(expected, value, field)
+	}
+
+	_, err = event.GetValue("tr_total")
+	assert.EqualError(err, EmptyFieldErr)
+
+	_, err = event.GetValue("event_id")
+	assert.NotNil(err)
+	assert.False(errors.Is(err, ErrUnknownField))
+	_, err = event.GetValue("not_a_field")
+	assert.True(errors.Is(err, ErrUnknownField))
+
+	invalid, err := projection.Parse(strings.Join(withField(fullEvent, "contexts", invalidCtxt), "\t"))
+	assert.Nil(err)
+	_, err = invalid.GetValue("contexts")
+	var parseErr *FieldParseError
+	assert.True(errors.As(err, &parseErr))
+	assert.Equal("contexts", parseErr.Field)
+}
+
+func BenchmarkProjectedEventGetValue(b *testing.B) {
+	projection, _ := NewProjection("event_id", "collector_tstamp", "contexts")
+	event, _ := projection.Parse(tsvEvent)
+	for i := 0; i < b.N; i++ {
+		event.GetValue("collector_tstamp")
+	}
+}
+
+func TestProjectedEventGetSubsetMap(t *testing.T) {
+	assert := assert.New(t)
+
+	projection, err := NewProjection(projectedFields...)
+	assert.Nil(err)
+	event, err := projection.Parse(tsvEvent)
+	assert.Nil(err)
+
+	subset, err := event.GetSubsetMap("app_id", "contexts")
+	assert.Nil(err)
+	expected, err := fullEvent.GetSubsetMap("app_id", "contexts")
+	assert.Nil(err)
+	assert.Equal(expected, subset)
+
+	mapified, err := event.ToMap()
+	assert.Nil(err)
+	expected, err = fullEvent.GetSubsetMap(projectedFields...)
+	assert.Nil(err)
+	assert.Equal(expected, mapified)
+
+	transformer, err := NewTransformer(WithFields(projectedFields...))
+	assert.Nil(err)
+	transformed, err := transformer.ToMap(fullEvent)
+	assert.Nil(err)
+	assert.Equal(transformed, mapified)
+
+	_, err = event.GetSubsetMap("app_id", "event_id")
+	assert.NotNil(err)
+}
+
+func BenchmarkProjectedEventGetSubsetMap(b *testing.B) {
+	projection, _ := NewProjection("app_id", "br_features_flash", "br_features_pdf", "collector_tstamp", "contexts", "unstruct_event")
+	for i := 0; i < b.N; i++ {
+		event, _ := projection.Parse(tsvEvent)
+		event.ToMap()
+	}
+}
+
+func BenchmarkParseEventAndGetSubsetMap(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		event, _ := ParseEvent(tsvEvent)
+		event.GetSubsetMap("app_id", "br_features_flash", "br_features_pdf", "collector_tstamp", "contexts", "unstruct_event")
+	}
+}
+
+func TestProjectedEventToJson(t *testing.T) {
+	assert := assert.New(t)
+
+	projection, err := NewProjection(projectedFields...)
+	assert.Nil(err)
+	event, err := projection.Parse(tsvEvent)
+	assert.Nil(err)
+
+	jsonified, err := event.ToJson()
+	assert.Nil(err)
+	expected, err := fullEvent.GetSubsetJson(projectedFields...)
+	assert.Nil(err)
+	assert.JSONEq(string(expected), string(jsonified))
+
+	subset, err := event.GetSubsetJson("app_id")
+	assert.Nil(err)
+	assert.JSONEq(`{"app_id":"<>angry-birds"}`, string(subset))
+}
+
+func BenchmarkProjectedEventToJson(b *testing.B) {
+	projection, _ := NewProjection("event_id", "collector_tstamp", "contexts")
+	event, _ := projection.Parse(tsvEvent)
+	for i := 0; i < b.N; i++ {
+		event.ToJson()
+	}
+}
+
+func BenchmarkProjectionFilter(b *testing.B) {
+	projection, _ := NewProjection("event_name", "app_id")
+	for i := 0; i < b.N; i++ {
+		event, _ := projection.Parse(tsvEvent)
+		event.Column("event_name")
+	}
+}
+
+func BenchmarkParseEventFilter(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		event, _ := ParseEvent(tsvEvent)
+		event.GetValue("event_name")
+	}
+}
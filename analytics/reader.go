@@ -0,0 +1,129 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+)
+
+// LineError is returned by a Reader when a line of its input cannot be parsed as an enriched event.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads Snowplow Enriched events from newline-delimited tsv input, such as files or pipes.
+// Lines may be of any length.
+type Reader struct {
+	input         *bufio.Reader
+	line          int
+	skipMalformed bool
+	skipped       int
+	err           error
+}
+
+// ReaderOption configures a Reader.
+type ReaderOption func(*Reader)
+
+// SkipMalformed makes the Reader skip lines which cannot be parsed, counting them rather than returning an error.
+func SkipMalformed() ReaderOption {
+	return func(r *Reader) {
+		r.skipMalformed = true
+	}
+}
+
+// NewReader returns a Reader reading enriched events from the provided io.Reader.
+func NewReader(input io.Reader, opts ...ReaderOption) *Reader {
+	r := &Reader{input: bufio.NewReader(input)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Next returns the next event of the input. It returns io.EOF once the input is exhausted,
+// and a *LineError if a line cannot be parsed. Read errors are returned, and then returned by every later call,
+// without parsing the partial line read before them.
+func (r *Reader) Next() (ParsedEvent, error) {
+	for {
+		if r.err != nil {
+			return nil, r.err
+		}
+		line, err := r.input.ReadString('\n')
+		if err != nil && err != io.EOF {
+			// the line read before the error may be truncated, so it is not parsed
+			r.err = fmt.Errorf("error reading line %d: %w", r.line+1, err)
+			return nil, r.err
+		}
+		if err != nil {
+			r.err = err
+			if line == "" {
+				return nil, err
+			}
+		}
+		r.line++
+		line = strings.TrimSuffix(line, "\n")
+		line = strings.TrimSuffix(line, "\r")
+
+		event, err := ParseEvent(line)
+		if err != nil {
+			if r.skipMalformed {
+				r.skipped++
+				continue
+			}
+			return nil, &LineError{Line: r.line, Err: err}
+		}
+		return event, nil
+	}
+}
+
+// All returns an iterator over the remaining events of the input. Lines which cannot be parsed are yielded
+// with a *LineError, after which iteration continues. Iteration stops at the end of the input or on a read error.
+func (r *Reader) All() iter.Seq2[ParsedEvent, error] {
+	return func(yield func(ParsedEvent, error) bool) {
+		for {
+			event, err := r.Next()
+			if err == io.EOF {
+				return
+			}
+			var lineErr *LineError
+			if !yield(event, err) || (err != nil && !errors.As(err, &lineErr)) {
+				return
+			}
+		}
+	}
+}
+
+// Line returns the number of lines read so far.
+func (r *Reader) Line() int {
+	return r.line
+}
+
+// Skipped returns the number of malformed lines skipped so far.
+func (r *Reader) Skipped() int {
+	return r.skipped
+}
@@ -0,0 +1,139 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReaderNext(t *testing.T) {
+	assert := assert.New(t)
+
+	// correct values, including a trailing carriage return and no final newline
+	reader := NewReader(strings.NewReader(tsvEvent + "\n" + tsvEvent + "\r\n" + tsvEvent))
+	for i := 1; i <= 3; i++ {
+		event, err := reader.Next()
+		assert.Nil(err)
+		assert.Equal(fullEvent, event)
+		assert.Equal(i, reader.Line())
+	}
+	event, err := reader.Next()
+	assert.Equal(io.EOF, err)
+	assert.Nil(event)
+
+	// malformed line
+	reader = NewReader(strings.NewReader(tsvEvent + "\nnot an event\n" + tsvEvent + "\n"))
+	_, err = reader.Next()
+	assert.Nil(err)
+	event, err = reader.Next()
+	assert.Nil(event)
+	var lineErr *LineError
+	assert.True(errors.As(err, &lineErr))
+	assert.Equal(2, lineErr.Line)
+	assert.Contains(err.Error(), "line 2")
+	event, err = reader.Next()
+	assert.Nil(err)
+	assert.Equal(fullEvent, event)
+
+	// read error
+	reader = NewReader(iotest.ErrReader(errors.New("broken pipe")))
+	event, err = reader.Next()
+	assert.Nil(event)
+	assert.NotNil(err)
+	assert.NotEqual(io.EOF, err)
+
+	// the partial line read before a read error is not parsed
+	broken := errors.New("broken pipe")
+	reader = NewReader(io.MultiReader(strings.NewReader(tsvEvent+"\n"+tsvEvent[:100]), iotest.ErrReader(broken)))
+	event, err = reader.Next()
+	assert.Nil(err)
+	assert.Equal(fullEvent, event)
+	for range 2 {
+		event, err = reader.Next()
+		assert.Nil(event)
+		assert.ErrorIs(err, broken)
+		assert.False(errors.As(err, &lineErr))
+		assert.Contains(err.Error(), "line 2")
+	}
+	assert.Equal(1, reader.Line())
+}
+
+func TestReaderLongLines(t *testing.T) {
+	assert := assert.New(t)
+
+	// contexts payload larger than the default bufio.Scanner token size
+	longEvent := append(ParsedEvent{}, fullEvent...)
+	longEvent[indexMap["page_title"]] = strings.Repeat("a", 1<<20)
+
+	reader := NewReader(strings.NewReader(strings.Join(longEvent, "\t") + "\n"))
+	event, err := reader.Next()
+	assert.Nil(err)
+	assert.Equal(longEvent, event)
+}
+
+func TestReaderSkipMalformed(t *testing.T) {
+	assert := assert.New(t)
+
+	reader := NewReader(strings.NewReader("broken\n"+tsvEvent+"\n\t\t\n"+tsvEvent+"\n"), SkipMalformed())
+	count := 0
+	for event, err := range reader.All() {
+		assert.Nil(err)
+		assert.Equal(fullEvent, event)
+		count++
+	}
+	assert.Equal(2, count)
+	assert.Equal(2, reader.Skipped())
+	assert.Equal(4, reader.Line())
+}
+
+func TestReaderAll(t *testing.T) {
+	assert := assert.New(t)
+
+	// line errors are yielded without stopping iteration
+	reader := NewReader(strings.NewReader(tsvEvent + "\nbroken\n" + tsvEvent + "\n"))
+	var errs []error
+	count := 0
+	for event, err := range reader.All() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		assert.Equal(fullEvent, event)
+		count++
+	}
+	assert.Equal(2, count)
+	assert.Len(errs, 1)
+
+	// iteration may be stopped early
+	reader = NewReader(strings.NewReader(tsvEvent + "\n" + tsvEvent + "\n"))
+	for range reader.All() {
+		break
+	}
+	assert.Equal(1, reader.Line())
+}
+
+func BenchmarkReader(b *testing.B) {
+	input := strings.Repeat(tsvEvent+"\n", 100)
+	for i := 0; i < b.N; i++ {
+		reader := NewReader(strings.NewReader(input))
+		for range reader.All() {
+		}
+	}
+}
@@ -15,26 +15,26 @@ package analytics
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 	"unicode" // For camel to snake case - consider alternative?
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/iglu"
 )
 
 type SelfDescribingData struct {
-	Schema string
-	Data   map[string]any // TODO: See if leaving data as a string or byte array would work, and would be faster.
+	Schema string         `json:"schema"`
+	Data   map[string]any `json:"data"` // TODO: See if leaving data as a string or byte array would work, and would be faster.
 }
 
 type Contexts struct {
-	Schema string
-	Data   []SelfDescribingData
+	Schema string               `json:"schema"`
+	Data   []SelfDescribingData `json:"data"`
 }
 
 type UnstructEvent struct {
-	Schema string
-	Data   SelfDescribingData
+	Schema string             `json:"schema"`
+	Data   SelfDescribingData `json:"data"`
 }
 
 type SchemaParts struct {
@@ -46,28 +46,37 @@ type SchemaParts struct {
 	Revision string
 }
 
-const SCHEMA_URI_REGEX string = `(?P<protocol>^iglu:)(?P<vendor>[a-zA-Z0-9-_.]+)/(?P<name>[a-zA-Z0-9-_]+)/(?P<format>[a-zA-Z0-9-_]+)/(?P<model>[1-9][0-9]*)(?P<revision>(?:-(?:0|[1-9][0-9]*)){2}$)`
+// Key returns the schema key of the parts, with its version parsed for comparisons and matching.
+func (p SchemaParts) Key() (iglu.SchemaKey, error) {
+	return iglu.ParseSchemaKey(p.Protocol + p.Vendor + "/" + p.Name + "/" + p.Format + "/" + p.Model + p.Revision)
+}
 
-// Take regex capture group names out, as not used?
-// https://golang.org/pkg/regexp/#example_Regexp_SubexpNames
+const SCHEMA_URI_REGEX string = `(?P<protocol>^iglu:)(?P<vendor>[a-zA-Z0-9-_.]+)/(?P<name>[a-zA-Z0-9-_]+)/(?P<format>[a-zA-Z0-9-_]+)/(?P<model>[1-9][0-9]*)(?P<revision>(?:-(?:0|[1-9][0-9]*)){2}$)`
 
+// extractSchema splits a schema URI into its parts with iglu.SplitSchemaURI. It is equivalent to matching
+// SCHEMA_URI_REGEX, without the cost of a regular expression.
 func extractSchema(uri string) (SchemaParts, error) {
-	schema_pattern := regexp.MustCompile(SCHEMA_URI_REGEX)
-
-	match := schema_pattern.FindStringSubmatch(uri)
-	if match != nil {
-		return SchemaParts{
-			Protocol: match[1],
-			Vendor:   match[2],
-			Name:     match[3],
-			Format:   match[4],
-			Model:    match[5],
-			Revision: match[6],
-		}, nil
-	} else {
-		return SchemaParts{}, fmt.Errorf("schema '%s' does not conform to regular expression '%s'", uri, SCHEMA_URI_REGEX)
+	parts, ok := splitSchemaURI(uri)
+	if !ok {
+		return SchemaParts{}, &SchemaError{Schema: uri}
 	}
+	return parts, nil
+}
 
+func splitSchemaURI(uri string) (SchemaParts, bool) {
+	vendor, name, format, version, ok := iglu.SplitSchemaURI(uri)
+	if !ok {
+		return SchemaParts{}, false
+	}
+	modelEnd := strings.IndexByte(version, '-')
+	return SchemaParts{
+		Protocol: "iglu:",
+		Vendor:   vendor,
+		Name:     name,
+		Format:   format,
+		Model:    version[:modelEnd],
+		Revision: version[modelEnd:],
+	}, true
 }
 
 // Based on https://gist.github.com/stoewer/fbe273b711e6a06315d19552dd4d33e6#gistcomment-3673823
@@ -96,17 +105,39 @@ func fixSchema(prefix string, schemaUri string) (string, error) {
 	return strings.ToLower(strings.Join([]string{prefix, vendor, name, parts.Model}, "_")), nil
 }
 
-func shredContexts(contexts string) ([]KeyVal, error) {
+// ContextsKey returns the key under which ToMap and ToJson output contexts and derived contexts with the provided
+// schema, such as contexts_com_acme_my_context_1.
+func ContextsKey(schemaUri string) (string, error) {
+	return fixSchema("contexts", schemaUri)
+}
+
+// UnstructEventKey returns the key under which ToMap and ToJson output an unstruct event with the provided schema,
+// such as unstruct_event_com_acme_my_event_1.
+func UnstructEventKey(schemaUri string) (string, error) {
+	return fixSchema("unstruct_event", schemaUri)
+}
+
+func shredContexts(contexts string, naming NamingStrategy) ([]KeyVal, error) {
+	return shredContextsBytes("contexts", stringBytes(contexts), naming)
+}
+
+// shredContextsWithPrefix shreds contexts under keys starting with the provided prefix rather than "contexts".
+func shredContextsWithPrefix(prefix string, contexts string, naming NamingStrategy) ([]KeyVal, error) {
+	return shredContextsBytes(prefix, stringBytes(contexts), naming)
+}
+
+// shredContextsBytes shreds contexts read from a byte slice, such as a sub-slice of the line of an event, without copying it.
+func shredContextsBytes(prefix string, contexts []byte, naming NamingStrategy) ([]KeyVal, error) {
 	ctxts := Contexts{}
 
-	err := jsoniter.Unmarshal([]byte(contexts), &ctxts)
+	err := jsoniter.Unmarshal(contexts, &ctxts)
 	if err != nil {
 		return nil, fmt.Errorf("error unmarshaling context JSON: %w", err)
 	}
 
 	var distinctContexts = make(map[string][]any)
 	for _, entry := range ctxts.Data {
-		key, err := fixSchema("contexts", entry.Schema) // is key a bad var name here?
+		key, err := shreddedKey(prefix, entry.Schema, naming) // is key a bad var name here?
 		if err != nil {
 			return nil, fmt.Errorf("error parsing contexts: %w", err)
 		}
@@ -129,19 +160,58 @@ func shredContexts(contexts string) ([]KeyVal, error) {
 	return out, nil
 }
 
-func shredUnstruct(unstruct string) ([]KeyVal, error) {
+func shredUnstruct(unstruct string, naming NamingStrategy) ([]KeyVal, error) {
+	return shredUnstructBytes(stringBytes(unstruct), naming)
+}
 
+// shredUnstructBytes shreds an unstruct event read from a byte slice, such as a sub-slice of the line of an event,
+// without copying it.
+func shredUnstructBytes(unstruct []byte, naming NamingStrategy) ([]KeyVal, error) {
 	event := UnstructEvent{}
 
-	err := jsoniter.Unmarshal([]byte(unstruct), &event)
+	err := jsoniter.Unmarshal(unstruct, &event)
 	if err != nil {
 		return nil, fmt.Errorf("error unmarshaling unstruct event JSON: %w", err)
 	}
 
-	key, err := fixSchema("unstruct_event", event.Data.Schema)
+	key, err := shreddedKey("unstruct_event", event.Data.Schema, naming)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing unstruct event: %w", err)
 	}
 
 	return []KeyVal{{key, event.Data.Data}}, nil
 }
+
+// decodeContexts unmarshals a contexts or derived_contexts field, checking that every entry carries a valid schema URI.
+func decodeContexts(key string, value string) (Contexts, error) {
+	if value == "" {
+		return Contexts{}, emptyFieldError(key)
+	}
+	ctxts := Contexts{}
+	err := jsoniter.Unmarshal(stringBytes(value), &ctxts)
+	if err != nil {
+		return Contexts{}, &FieldParseError{Field: key, Index: -1, Value: value, Cause: fmt.Errorf("error unmarshaling context JSON: %w", err)}
+	}
+	for _, entry := range ctxts.Data {
+		if _, err := extractSchema(entry.Schema); err != nil {
+			return Contexts{}, &FieldParseError{Field: key, Index: -1, Value: value, Cause: fmt.Errorf("error parsing contexts: %w", err)}
+		}
+	}
+	return ctxts, nil
+}
+
+// decodeUnstruct unmarshals an unstruct_event field, checking that the event carries a valid schema URI.
+func decodeUnstruct(key string, value string) (UnstructEvent, error) {
+	if value == "" {
+		return UnstructEvent{}, emptyFieldError(key)
+	}
+	event := UnstructEvent{}
+	err := jsoniter.Unmarshal(stringBytes(value), &event)
+	if err != nil {
+		return UnstructEvent{}, &FieldParseError{Field: key, Index: -1, Value: value, Cause: fmt.Errorf("error unmarshaling unstruct event JSON: %w", err)}
+	}
+	if _, err := extractSchema(event.Data.Schema); err != nil {
+		return UnstructEvent{}, &FieldParseError{Field: key, Index: -1, Value: value, Cause: fmt.Errorf("error parsing unstruct event: %w", err)}
+	}
+	return event, nil
+}
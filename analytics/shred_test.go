@@ -16,6 +16,7 @@ package analytics
 import (
 	"testing"
 
+	"github.com/snowplow/snowplow-golang-analytics-sdk/iglu"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,6 +32,9 @@ func TestExtractSchema(t *testing.T) {
 	assert.Equal("jsonschema", schemaParts.Format)
 	assert.Equal("15", schemaParts.Model)
 	assert.Equal("-34-1", schemaParts.Revision)
+	schemaKey, err := schemaParts.Key()
+	assert.Nil(err)
+	assert.Equal(iglu.SchemaVer{Model: 15, Revision: 34, Addition: 1}, schemaKey.Version)
 
 	// invalid schema path
 	invalidSchemaParts, err := extractSchema("com.acme.notvalid/invalidschemapath/jsonschema/1.0.0")
@@ -87,6 +91,22 @@ func BenchmarkFixSchema(b *testing.B) {
 	}
 }
 
+func TestShreddedKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	contextsKey, err := ContextsKey("iglu:com.acme.data/someContext/jsonschema/2-0-1")
+	assert.Nil(err)
+	assert.Equal("contexts_com_acme_data_some_context_2", contextsKey)
+
+	unstructKey, err := UnstructEventKey("iglu:com.acme.data/some_event/jsonschema/15-34-1")
+	assert.Nil(err)
+	assert.Equal("unstruct_event_com_acme_data_some_event_15", unstructKey)
+
+	invalidKey, err := ContextsKey("iglu:com.broken.path//jsonschema/1-0-0")
+	assert.NotNil(err)
+	assert.Zero(invalidKey)
+}
+
 func TestShredContexts(t *testing.T) {
 	assert := assert.New(t)
 
@@ -95,19 +115,19 @@ func TestShredContexts(t *testing.T) {
 	map2 := map[string]any{"field1": 2.0}
 	var expected = []KeyVal{{"contexts_com_acme_test_context_1", []any{map1, map2}}}
 
-	shreddedContexts, err := shredContexts(ctxt)
+	shreddedContexts, err := shredContexts(ctxt, nil)
 	assert.Nil(err)
 	assert.Equal(expected, shreddedContexts)
 
 	// invalid input
-	failedShred, err := shredContexts(invalidCtxt)
+	failedShred, err := shredContexts(invalidCtxt, nil)
 	assert.NotNil(err)
 	assert.Nil(failedShred)
 }
 
 func BenchmarkShredContexts(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		shredContexts(ctxt)
+		shredContexts(ctxt, nil)
 	}
 }
 
@@ -118,17 +138,17 @@ func TestShredUnstruct(t *testing.T) {
 	map1 := map[string]any{"key": "value"}
 	expected := []KeyVal{{"unstruct_event_com_snowplowanalytics_snowplow_link_click_1", map1}}
 
-	shreddedUnstruct, err := shredUnstruct(unstruct)
+	shreddedUnstruct, err := shredUnstruct(unstruct, nil)
 	assert.Nil(err)
 	assert.Equal(expected, shreddedUnstruct)
 
-	failedShred, err := shredUnstruct(invalidUnstruct)
+	failedShred, err := shredUnstruct(invalidUnstruct, nil)
 	assert.NotNil(err)
 	assert.Nil(failedShred)
 }
 
 func BenchmarkShredUnstruct(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		shredUnstruct(unstruct)
+		shredUnstruct(unstruct, nil)
 	}
 }
@@ -0,0 +1,111 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// isoTimeLayout is timeLayout with the T separator of ISO 8601 timestamps
+	isoTimeLayout string = "2006-01-02T15:04:05.999"
+	// rfc3339MillisLayout is RFC 3339 with millisecond precision
+	rfc3339MillisLayout string = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// parseTimestamp parses a timestamp column. Besides the layout of the enrich process, it accepts any fractional
+// second precision, a T separator between date and time, and a trailing Z or UTC offset. Timestamps are returned in UTC.
+func parseTimestamp(value string) (time.Time, error) {
+	layout := timeLayout
+	if len(value) > 10 && value[10] == 'T' {
+		layout = isoTimeLayout
+	}
+	if len(value) > 19 && strings.ContainsAny(value[19:], "Z+-") {
+		layout += "Z07:00"
+	}
+	out, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return out.UTC(), nil
+}
+
+// TimestampFormat selects how timestamp fields are output by a Transformer.
+type TimestampFormat int
+
+const (
+	// TimestampTime outputs timestamps as time.Time values in maps, and RFC 3339 strings with nanosecond precision in JSON.
+	TimestampTime TimestampFormat = iota
+	// TimestampRFC3339Millis outputs timestamps as RFC 3339 strings with millisecond precision, such as 2013-11-26T00:03:57.885Z.
+	TimestampRFC3339Millis
+	// TimestampUnixMillis outputs timestamps as the number of milliseconds since the Unix epoch.
+	TimestampUnixMillis
+	// TimestampUnixMicros outputs timestamps as the number of microseconds since the Unix epoch.
+	TimestampUnixMicros
+	// timestampLayout outputs timestamps as strings formatted with the layout of WithTimestampLayout.
+	timestampLayout
+)
+
+// WithTimestampFormat sets how every timestamp field is output, TimestampTime by default.
+func WithTimestampFormat(format TimestampFormat) TransformOption {
+	return func(c *transformConfig) {
+		c.timeFormat = format
+	}
+}
+
+// formatTimestamp returns a timestamp as output in maps.
+func (c transformConfig) formatTimestamp(value time.Time) any {
+	switch c.timeFormat {
+	case TimestampRFC3339Millis:
+		return value.UTC().Format(rfc3339MillisLayout)
+	case TimestampUnixMillis:
+		return value.UnixMilli()
+	case TimestampUnixMicros:
+		return value.UnixMicro()
+	case timestampLayout:
+		return value.UTC().Format(c.timeLayout)
+	default:
+		return value
+	}
+}
+
+// appendTimestamp appends a timestamp as output in JSON.
+func (c transformConfig) appendTimestamp(dst []byte, value time.Time) []byte {
+	switch c.timeFormat {
+	case TimestampRFC3339Millis:
+		dst = append(dst, '"')
+		dst = value.UTC().AppendFormat(dst, rfc3339MillisLayout)
+		return append(dst, '"')
+	case TimestampUnixMillis:
+		return strconv.AppendInt(dst, value.UnixMilli(), 10)
+	case TimestampUnixMicros:
+		return strconv.AppendInt(dst, value.UnixMicro(), 10)
+	case timestampLayout:
+		return appendString(dst, value.UTC().Format(c.timeLayout))
+	default:
+		dst = append(dst, '"')
+		dst = value.AppendFormat(dst, time.RFC3339Nano)
+		return append(dst, '"')
+	}
+}
+
+func (f TimestampFormat) valid() error {
+	if f < TimestampTime || f > timestampLayout {
+		return fmt.Errorf("unknown timestamp format %d", int(f))
+	}
+	return nil
+}
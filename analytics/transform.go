@@ -14,17 +14,23 @@
 package analytics
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/iglu"
 )
 
 const (
 	eventLength int = 131
-	// EmptyFieldErr is returned when a field value is empty
+	// timeLayout is the layout of timestamp columns in the enriched tsv format
+	timeLayout string = "2006-01-02 15:04:05.999"
+	// EmptyFieldErr is the message of ErrEmptyField.
+	//
+	// Deprecated: use errors.Is with ErrEmptyField.
 	EmptyFieldErr string = `field is empty`
 )
 
@@ -40,166 +46,241 @@ type ValueParser func(string, string) ([]KeyVal, error)
 type KeyFunctionPair struct {
 	Key           string
 	ParseFunction ValueParser
+	// Type is the type of the values of the field, which lets encoders convert its columns without going through
+	// ParseFunction. It must be FieldCustom, its zero value, unless ParseFunction parses values as the built-in parser
+	// of the type does.
+	Type FieldType
 }
 
 type ParsedEvent []string
 
-func parseTime(key string, value string) ([]KeyVal, error) {
+func decodeTime(key string, value string) (time.Time, error) {
 	if value == "" {
-		return nil, fmt.Errorf("error parsing key %s: null string found", key)
+		return time.Time{}, emptyFieldError(key)
 	}
-	timeLayout := "2006-01-02 15:04:05.999"
-	out, err := time.Parse(timeLayout, value)
+	out, err := parseTimestamp(value)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing field '%s', with value '%s' to timestamp: %w", key, value, err)
+		return time.Time{}, &FieldParseError{Field: key, Index: -1, Value: value, Cause: err}
 	}
-	return []KeyVal{{key, out}}, err
+	return out, nil
 }
 
-func parseString(key string, value string) ([]KeyVal, error) {
+func decodeString(key string, value string) (string, error) {
 	if value == "" {
-		return nil, fmt.Errorf("error parsing key %s: null string found", key)
+		return "", emptyFieldError(key)
 	}
-	return []KeyVal{{key, value}}, nil
+	return value, nil
 }
 
-func parseInt(key string, value string) ([]KeyVal, error) {
+func decodeInt(key string, value string) (int, error) {
 	if value == "" {
-		return nil, fmt.Errorf("error parsing key %s: null string found", key)
+		return 0, emptyFieldError(key)
 	}
 	intValue, err := strconv.Atoi(value)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing key '%s' to integer: %w", key, err)
+		return 0, &FieldParseError{Field: key, Index: -1, Value: value, Cause: err}
 	}
-	return []KeyVal{{key, intValue}}, err
+	return intValue, nil
 }
 
-func parseBool(key string, value string) ([]KeyVal, error) {
+func decodeBool(key string, value string) (bool, error) {
 	if value == "" {
-		return nil, fmt.Errorf("error parsing key %s: null string found", key)
+		return false, emptyFieldError(key)
 	}
 	boolValue, err := strconv.ParseBool(value)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing key '%s' to boolean: %w", key, err)
+		return false, &FieldParseError{Field: key, Index: -1, Value: value, Cause: err}
 	}
-	return []KeyVal{{key, boolValue}}, err
+	return boolValue, nil
 }
 
-func parseDouble(key string, value string) ([]KeyVal, error) {
+func decodeDouble(key string, value string) (float64, error) {
 	if value == "" {
-		return nil, fmt.Errorf("error parsing key %s: null string found", key)
+		return 0, emptyFieldError(key)
 	}
 	doubleValue, err := strconv.ParseFloat(value, 64)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing key '%s' to double: %w", key, err)
+		return 0, &FieldParseError{Field: key, Index: -1, Value: value, Cause: err}
+	}
+	return doubleValue, nil
+}
+
+func parseTime(key string, value string) ([]KeyVal, error) {
+	out, err := decodeTime(key, value)
+	if err != nil {
+		return nil, err
+	}
+	return []KeyVal{{key, out}}, nil
+}
+
+func parseString(key string, value string) ([]KeyVal, error) {
+	out, err := decodeString(key, value)
+	if err != nil {
+		return nil, err
+	}
+	return []KeyVal{{key, out}}, nil
+}
+
+func parseInt(key string, value string) ([]KeyVal, error) {
+	out, err := decodeInt(key, value)
+	if err != nil {
+		return nil, err
+	}
+	return []KeyVal{{key, out}}, nil
+}
+
+func parseBool(key string, value string) ([]KeyVal, error) {
+	out, err := decodeBool(key, value)
+	if err != nil {
+		return nil, err
+	}
+	return []KeyVal{{key, out}}, nil
+}
+
+func parseDouble(key string, value string) ([]KeyVal, error) {
+	out, err := decodeDouble(key, value)
+	if err != nil {
+		return nil, err
 	}
-	return []KeyVal{{key, doubleValue}}, err
+	return []KeyVal{{key, out}}, nil
 }
 
 func parseContexts(key string, value string) ([]KeyVal, error) {
 	if value == "" {
-		return nil, fmt.Errorf("error parsing key %s: null string found", key)
+		return nil, emptyFieldError(key)
 	}
-	return shredContexts(value)
+	out, err := shredContexts(value, nil)
+	if err != nil {
+		return nil, &FieldParseError{Field: key, Index: -1, Value: value, Cause: err}
+	}
+	return out, nil
 }
 
 func parseUnstruct(key string, value string) ([]KeyVal, error) {
 	if value == "" {
-		return nil, fmt.Errorf("error parsing key %s: null string found", key)
+		return nil, emptyFieldError(key)
+	}
+	out, err := shredUnstruct(value, nil)
+	if err != nil {
+		return nil, &FieldParseError{Field: key, Index: -1, Value: value, Cause: err}
 	}
-	return shredUnstruct(value)
+	return out, nil
 }
 
 // ParseEvent takes a Snowplow Enriched event tsv string as input, and returns a 'ParsedEvent' typed slice of strings.
 // Methods may then be called on the resulting ParsedEvent type to transform the event, or a subset of the event to Map or Json.
+// The layout of the event is detected from its number of fields, which must match one of the registered layouts.
 func ParseEvent(event string) (ParsedEvent, error) {
 	record := strings.Split(event, "\t")
-	if len(record) != eventLength {
-		return nil, fmt.Errorf("cannot parse tsv event - wrong number of fields provided: %v", len(record))
+	if _, ok := LayoutFor(len(record)); !ok {
+		return nil, fmt.Errorf("cannot parse tsv event - %w", &LengthError{Got: len(record)})
 	}
 	return record, nil
 }
 
-func (event ParsedEvent) mapifyGoodEvent(knownFields [131]KeyFunctionPair, addGeolocationData bool) (map[string]any, error) {
-	if len(event) != eventLength {
-		return nil, fmt.Errorf("cannot transform event - wrong number of fields provided: %v", len(event))
-	} else {
-		output := make(map[string]any)
-		if addGeolocationData && event[latitudeIndex] != "" && event[longitudeIndex] != "" {
-			output["geo_location"] = event[latitudeIndex] + "," + event[longitudeIndex]
-		}
-		for index, value := range event {
-			// skip if empty
-			if event[index] != "" {
-				// apply function if not empty
-				kvPairs, err := knownFields[index].ParseFunction(knownFields[index].Key, value)
-				if err != nil {
-					return nil, err
-				}
-				// append all results
-				for _, pair := range kvPairs {
-					output[pair.Key] = pair.Value
-				}
+// layoutOrErr returns the layout of the event, or an error describing the failed action if it has none.
+func (event ParsedEvent) layoutOrErr(action string) (*Layout, error) {
+	layout, ok := LayoutFor(len(event))
+	if !ok {
+		return nil, fmt.Errorf("cannot %s - %w", action, &LengthError{Got: len(event)})
+	}
+	return layout, nil
+}
+
+func (event ParsedEvent) mapifyGoodEvent(addGeolocationData bool) (map[string]any, error) {
+	layout, err := event.layoutOrErr("transform event")
+	if err != nil {
+		return nil, err
+	}
+	output := make(map[string]any)
+	if addGeolocationData && layout.latitude >= 0 && layout.longitude >= 0 && event[layout.latitude] != "" && event[layout.longitude] != "" {
+		output["geo_location"] = event[layout.latitude] + "," + event[layout.longitude]
+	}
+	for index, value := range event {
+		// skip if empty
+		if value != "" {
+			// apply function if not empty
+			kvPairs, err := layout.fields[index].ParseFunction(layout.fields[index].Key, value)
+			if err != nil {
+				return nil, columnError(err, layout.fields[index].Key, index, value)
+			}
+			// append all results
+			for _, pair := range kvPairs {
+				output[pair.Key] = pair.Value
 			}
 		}
-		return output, nil
 	}
+	return output, nil
 }
 
 // ToMap transforms a valid Snowplow ParsedEvent to a Go map.
 func (event ParsedEvent) ToMap() (map[string]any, error) {
-	return event.mapifyGoodEvent(enrichedEventFieldTypes, false)
+	return event.mapifyGoodEvent(false)
 }
 
 // ToMapWithGeo adds the geo_location field, and transforms a valid Snowplow ParsedEvent to a Go map.
 func (event ParsedEvent) ToMapWithGeo() (map[string]any, error) {
-	return event.mapifyGoodEvent(enrichedEventFieldTypes, true)
+	return event.mapifyGoodEvent(true)
 }
 
 // ToJson transforms a valid Snowplow ParsedEvent to a JSON object.
 func (event ParsedEvent) ToJson() ([]byte, error) {
-
-	mapified, err := event.ToMap()
+	jsonified, err := event.AppendJson(make([]byte, 0, event.jsonSizeHint()))
 	if err != nil {
 		return nil, err
 	}
-
-	jsonified, err := json.Marshal(mapified)
-	if err != nil {
-		return nil, fmt.Errorf("error marshaling to JSON: %w", err)
-	}
 	return jsonified, nil
 }
 
 // ToJsonWithGeo adds the geo_location field, and transforms a valid Snowplow ParsedEvent to a JSON object.
 func (event ParsedEvent) ToJsonWithGeo() ([]byte, error) {
-	mapified, err := event.ToMapWithGeo()
+	jsonified, err := event.AppendJsonWithGeo(make([]byte, 0, event.jsonSizeHint()))
 	if err != nil {
 		return nil, err
 	}
+	return jsonified, nil
+}
+
+// jsonSizeHint estimates the size of the JSON object for an event, to size its buffer in a single allocation.
+func (event ParsedEvent) jsonSizeHint() int {
+	size := 64
+	for _, value := range event {
+		if value != "" {
+			size += len(value) + 32
+		}
+	}
+	return size
+}
 
-	jsonified, err := json.Marshal(mapified)
-	if err != nil {
-		return nil, fmt.Errorf("error marshaling to JSON: %w", err)
+// fieldIndex returns the column of a field in the layout, reporting whether the field is known to any layout at all.
+// Fields known to the current layout but absent from an older one have no column.
+func (l *Layout) fieldIndex(field string) (index int, present bool, err error) {
+	if index, ok := l.index[field]; ok {
+		return index, true, nil
 	}
-	return jsonified, nil
+	if _, ok := indexMap[field]; ok {
+		return -1, false, nil
+	}
+	return -1, false, fmt.Errorf("key %s %w", field, ErrUnknownField)
 }
 
-// getParsedValue gets a field's value from an event after parsing it with its specific ParseFunction
+// getParsedValue gets a field's value from an event after parsing it with its specific ParseFunction.
+// It returns no value and no error for fields absent from the layout of the event.
 func (event ParsedEvent) getParsedValue(field string) ([]KeyVal, error) {
-	if len(event) != eventLength {
-		return nil, fmt.Errorf("cannot get value - wrong number of fields provided: %v", len(event))
+	layout, err := event.layoutOrErr("get value")
+	if err != nil {
+		return nil, err
 	}
-	index, ok := indexMap[field]
-	if !ok {
-		return nil, fmt.Errorf("key %s not a valid atomic field", field)
+	index, present, err := layout.fieldIndex(field)
+	if err != nil || !present {
+		return nil, err
 	}
 	if event[index] == "" {
-		return nil, fmt.Errorf("%s", EmptyFieldErr)
+		return nil, ErrEmptyField
 	}
-	kvPairs, err := enrichedEventFieldTypes[index].ParseFunction(enrichedEventFieldTypes[index].Key, event[index])
+	kvPairs, err := layout.fields[index].ParseFunction(layout.fields[index].Key, event[index])
 	if err != nil {
-		return nil, err
+		return nil, columnError(err, field, index, event[index])
 	}
 
 	return kvPairs, nil
@@ -207,9 +288,10 @@ func (event ParsedEvent) getParsedValue(field string) ([]KeyVal, error) {
 
 // GetValue returns the value for a provided atomic field, without processing the rest of the event.
 // For unstruct_event, it returns a map of only the data for the unstruct event.
+// For fields absent from the layout of the event, it returns nil.
 func (event ParsedEvent) GetValue(field string) (any, error) {
 	kvPairs, err := event.getParsedValue(field)
-	if err != nil {
+	if err != nil || kvPairs == nil {
 		return nil, err
 	}
 
@@ -227,9 +309,17 @@ func (event ParsedEvent) GetValue(field string) (any, error) {
 
 // GetUnstructEventValue returns the value for a provided atomic field inside an event's unstruct_event field
 func (event ParsedEvent) GetUnstructEventValue(path ...any) (any, error) {
+	layout, err := event.layoutOrErr("get value")
+	if err != nil {
+		return nil, err
+	}
+	index, ok := layout.index["unstruct_event"]
+	if !ok {
+		return nil, ErrEmptyField
+	}
 	fullPath := append([]any{`data`, `data`}, path...)
 
-	el := json.Get([]byte(event[indexMap["unstruct_event"]]), fullPath...)
+	el := json.Get([]byte(event[index]), fullPath...)
 	return el.GetInterface(), el.LastError()
 }
 
@@ -239,7 +329,7 @@ func (event ParsedEvent) GetContextValue(contextName string, path ...any) (any,
 	var contexts []any
 	for _, c := range contextNames {
 		kvPairs, err := event.getParsedValue(c)
-		if err != nil && err.Error() != EmptyFieldErr {
+		if err != nil && !errors.Is(err, ErrEmptyField) {
 			return nil, err
 		}
 		// extract the key/value pairs of the event path into a map
@@ -280,40 +370,64 @@ func (event ParsedEvent) GetContextValue(contextName string, path ...any) (any,
 	return output, nil
 }
 
-// GetSubsetMap returns a map of a subset of the event, containing only the atomic fields provided, without processing the rest of the event.
-// For custom events and contexts, only "unstruct_event", "contexts", or "derived_contexts" may be provided, which will produce the entire data object for that field.
-// For contexts, the resultant map will contain all occurrences of all contexts within the provided field.
-func (event ParsedEvent) GetSubsetMap(fields ...string) (map[string]any, error) {
-
-	if len(event) != eventLength {
-		return nil, fmt.Errorf("cannot get values - wrong number of fields provided: %v", len(event))
+// GetContexts returns the contexts and derived contexts of an event whose schema matches the criterion, such as
+// iglu:com.acme/*/jsonschema/1-*-*, in the order they appear in the event.
+func (event ParsedEvent) GetContexts(criterion iglu.SchemaCriterion) ([]SelfDescribingData, error) {
+	layout, err := event.layoutOrErr("get values")
+	if err != nil {
+		return nil, err
 	}
-	output := make(map[string]any)
-	for _, field := range fields {
-		index, ok := indexMap[field]
-		if !ok {
-			return nil, fmt.Errorf("key %s not a valid atomic field", field)
+	var output []SelfDescribingData
+	for index, value := range event {
+		if value == "" || layout.types[index] != FieldContexts {
+			continue
 		}
-		if event[index] != "" {
-			kvPairs, err := enrichedEventFieldTypes[index].ParseFunction(enrichedEventFieldTypes[index].Key, event[index])
-			if err != nil {
-				return nil, err
-			}
-			for _, pair := range kvPairs {
-				output[pair.Key] = pair.Value
+		contexts, err := decodeContexts(layout.fields[index].Key, value)
+		if err != nil {
+			return nil, columnError(err, layout.fields[index].Key, index, value)
+		}
+		for _, entity := range contexts.Data {
+			if criterion.MatchesURI(entity.Schema) {
+				output = append(output, entity)
 			}
 		}
 	}
 	return output, nil
 }
 
+// GetUnstructEvent returns the unstruct event of an event if its schema matches the criterion, and nil otherwise.
+func (event ParsedEvent) GetUnstructEvent(criterion iglu.SchemaCriterion) (*SelfDescribingData, error) {
+	layout, err := event.layoutOrErr("get value")
+	if err != nil {
+		return nil, err
+	}
+	index, ok := layout.index["unstruct_event"]
+	if !ok || event[index] == "" {
+		return nil, nil
+	}
+	unstruct, err := decodeUnstruct("unstruct_event", event[index])
+	if err != nil {
+		return nil, columnError(err, "unstruct_event", index, event[index])
+	}
+	if !criterion.MatchesURI(unstruct.Data.Schema) {
+		return nil, nil
+	}
+	return &unstruct.Data, nil
+}
+
+// GetSubsetMap returns a map of a subset of the event, containing only the atomic fields provided, without processing the rest of the event.
+// For custom events and contexts, only "unstruct_event", "contexts", or "derived_contexts" may be provided, which will produce the entire data object for that field.
+// For contexts, the resultant map will contain all occurrences of all contexts within the provided field.
+func (event ParsedEvent) GetSubsetMap(fields ...string) (map[string]any, error) {
+	return defaultTransformer.GetSubsetMap(event, fields...)
+}
+
 // GetSubsetJson returns a JSON object containing a subset of the event, containing only the atomic fields provided, without processing the rest of the event.
 // For custom events and contexts, only "unstruct_event", "contexts", or "derived_contexts" may be provided, which will produce the entire data object for that field.
 // For contexts, the resultant map will contain all occurrences of all contexts within the provided field.
 func (event ParsedEvent) GetSubsetJson(fields ...string) ([]byte, error) {
-
-	if len(event) != eventLength {
-		return nil, fmt.Errorf("cannot get values - wrong number of fields provided: %v", len(event))
+	if _, err := event.layoutOrErr("get values"); err != nil {
+		return nil, err
 	}
 	subsetMap, err := event.GetSubsetMap(fields...)
 	if err != nil {
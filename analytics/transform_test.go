@@ -15,8 +15,10 @@ package analytics
 
 import (
 	"testing"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/iglu"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -29,6 +31,23 @@ func TestParseTime(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal([]KeyVal{{"tstampKey", tstampValue}}, tstamp)
 
+	// other precisions, separators and time zones
+	for _, value := range []string{
+		"2013-11-26 00:03:57.885000",
+		"2013-11-26T00:03:57.885",
+		"2013-11-26T00:03:57.885Z",
+		"2013-11-26 00:03:57.885Z",
+		"2013-11-26T01:03:57.885+01:00",
+		"2013-11-25T23:03:57.885-01:00",
+	} {
+		tstamp, err = parseTime("tstampKey", value)
+		assert.Nil(err, value)
+		assert.Equal([]KeyVal{{"tstampKey", tstampValue}}, tstamp, value)
+	}
+	tstamp, err = parseTime("tstampKey", "2013-11-26 00:03:57.123456")
+	assert.Nil(err)
+	assert.Equal(123456000, tstamp[0].Value.(time.Time).Nanosecond())
+
 	// incorrect format
 	notTstamp, err := parseTime("tstampKey", "not a tstamp")
 
@@ -48,6 +67,12 @@ func BenchmarkParseTime(b *testing.B) {
 	}
 }
 
+func BenchmarkParseTimeWithOffset(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		parseTime("tstampKey", "2021-04-07T12:01:01.999999+02:00")
+	}
+}
+
 func TestParseString(t *testing.T) {
 	assert := assert.New(t)
 
@@ -177,24 +202,24 @@ func TestMapifyGoodEvent(t *testing.T) {
 	assert := assert.New(t)
 
 	// correct value with geo
-	mapifiedEventWithGeo, err := fullEvent.mapifyGoodEvent(enrichedEventFieldTypes, true)
+	mapifiedEventWithGeo, err := fullEvent.mapifyGoodEvent(true)
 	assert.Nil(err)
 	assert.Equal(eventMapWithGeo, mapifiedEventWithGeo)
 
 	// correct value without geo
-	mapifiedEventWithoutGeo, err := fullEvent.mapifyGoodEvent(enrichedEventFieldTypes, false)
+	mapifiedEventWithoutGeo, err := fullEvent.mapifyGoodEvent(false)
 	assert.Nil(err)
 	assert.Equal(eventMapWithoutGeo, mapifiedEventWithoutGeo)
 
 	// incorrect input length
-	failedMapify, err := ParsedEvent([]string{"one", "two"}).mapifyGoodEvent(enrichedEventFieldTypes, true)
+	failedMapify, err := ParsedEvent([]string{"one", "two"}).mapifyGoodEvent(true)
 	assert.NotNil(err)
 	assert.Nil(failedMapify)
 }
 
 func BenchmarkMapifyGoodEvent(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		fullEvent.mapifyGoodEvent(enrichedEventFieldTypes, true)
+		fullEvent.mapifyGoodEvent(true)
 	}
 }
 
@@ -347,6 +372,12 @@ func TestGetUnstructEventValue(t *testing.T) {
 	assert.Equal(`<>angry_birds`, unstructValue)
 }
 
+func BenchmarkGetUnstructEventValue(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		fullEvent.GetUnstructEventValue("elementClasses", 0)
+	}
+}
+
 func TestGetContextValue(t *testing.T) {
 	assert := assert.New(t)
 
@@ -366,6 +397,57 @@ func TestGetContextValue(t *testing.T) {
 	assert.Equal([]any(nil), contextsValue)
 }
 
+func BenchmarkGetContextValue(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		fullEvent.GetContextValue("contexts_org_schema_web_page_1", "breadcrumb", 0)
+	}
+}
+
+func TestGetContexts(t *testing.T) {
+	assert := assert.New(t)
+
+	// contexts and derived contexts are both matched
+	all, _ := iglu.ParseSchemaCriterion("iglu:*/*/jsonschema/1-*-*")
+	contexts, err := fullEvent.GetContexts(all)
+	assert.Nil(err)
+	assert.Len(contexts, 3)
+	assert.Equal("iglu:org.schema/WebPage/jsonschema/1-0-0", contexts[0].Schema)
+	assert.Equal("iglu:com.snowplowanalytics.snowplow/ua_parser_context/jsonschema/1-0-0", contexts[2].Schema)
+
+	w3, _ := iglu.ParseSchemaCriterion("iglu:org.w3/*/jsonschema/1-0-*")
+	contexts, err = fullEvent.GetContexts(w3)
+	assert.Nil(err)
+	assert.Len(contexts, 1)
+	assert.Equal(1.415358089861e+12, contexts[0].Data["navigationStart"])
+
+	newer, _ := iglu.ParseSchemaCriterion("iglu:org.w3/*/jsonschema/2-*-*")
+	contexts, err = fullEvent.GetContexts(newer)
+	assert.Nil(err)
+	assert.Nil(contexts)
+
+	failed, err := withField(fullEvent, "contexts", invalidCtxt).GetContexts(all)
+	assert.NotNil(err)
+	assert.Nil(failed)
+}
+
+func TestGetUnstructEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	linkClick, _ := iglu.ParseSchemaCriterion("iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-*-*")
+	unstructEvent, err := fullEvent.GetUnstructEvent(linkClick)
+	assert.Nil(err)
+	assert.Equal("exampleLink", unstructEvent.Data["elementId"])
+
+	other, _ := iglu.ParseSchemaCriterion("iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/2-*-*")
+	unstructEvent, err = fullEvent.GetUnstructEvent(other)
+	assert.Nil(err)
+	assert.Nil(unstructEvent)
+
+	unstructEvent, err = withField(fullEvent, "unstruct_event", "").GetUnstructEvent(linkClick)
+	assert.Nil(err)
+	assert.Nil(unstructEvent)
+}
+
 func BenchmarkGetValue(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		fullEvent.GetValue("app_id")
@@ -0,0 +1,394 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ContextMergeMode selects how contexts and derived contexts sharing a key are output.
+type ContextMergeMode int
+
+const (
+	// ContextsLastWins outputs the derived contexts of a key in place of its contexts, as ToMap does.
+	ContextsLastWins ContextMergeMode = iota
+	// ContextsAppend outputs the derived contexts of a key after its contexts, in a single array.
+	ContextsAppend
+	// ContextsSeparate outputs derived contexts under their own keys, named with the derived_contexts prefix, such as
+	// derived_contexts_com_acme_my_context_1.
+	ContextsSeparate
+)
+
+// TransformOption configures the transformation of events to maps and JSON by a Transformer.
+type TransformOption func(*transformConfig)
+
+type transformConfig struct {
+	// naming is nil for the default ElasticsearchNaming, which has faster paths
+	naming      NamingStrategy
+	geolocation bool
+	nullFields  bool
+	fields      []string
+	excluded    []string
+	timeFormat  TimestampFormat
+	fieldErrors FieldErrorMode
+	timeLayout  string
+	merge       ContextMergeMode
+}
+
+// WithGeoLocation adds the geo_location field, as ToMapWithGeo and ToJsonWithGeo do.
+func WithGeoLocation() TransformOption {
+	return func(c *transformConfig) {
+		c.geolocation = true
+	}
+}
+
+// WithNullFields outputs empty atomic fields as nulls, rather than leaving them out, so that every event has the same
+// shape whatever its values. Fields of LayoutCurrent absent from the layout of an event are output as nulls too, as
+// is geo_location with WithGeoLocation. Empty contexts, derived_contexts and unstruct_event fields are left out, as
+// they are output under keys of their own.
+func WithNullFields() TransformOption {
+	return func(c *transformConfig) {
+		c.nullFields = true
+	}
+}
+
+// WithFields only outputs the provided atomic fields. As for GetSubsetMap, "contexts", "derived_contexts" and
+// "unstruct_event" select the shredded data of these fields.
+func WithFields(fields ...string) TransformOption {
+	return func(c *transformConfig) {
+		c.fields = append(c.fields, fields...)
+	}
+}
+
+// WithoutFields leaves the provided atomic fields out of the output.
+func WithoutFields(fields ...string) TransformOption {
+	return func(c *transformConfig) {
+		c.excluded = append(c.excluded, fields...)
+	}
+}
+
+// WithTimestampLayout outputs timestamp fields as strings formatted with the provided time layout, in UTC, rather
+// than as time.Time values in maps and RFC 3339 strings in JSON.
+func WithTimestampLayout(layout string) TransformOption {
+	return func(c *transformConfig) {
+		c.timeFormat = timestampLayout
+		c.timeLayout = layout
+	}
+}
+
+// WithContextMergeMode sets how contexts and derived contexts sharing a key are output, ContextsLastWins by default.
+func WithContextMergeMode(mode ContextMergeMode) TransformOption {
+	return func(c *transformConfig) {
+		c.merge = mode
+	}
+}
+
+// FieldErrorMode selects how a Transformer handles fields which fail to parse.
+type FieldErrorMode int
+
+const (
+	// FieldErrorsFail fails the transformation of an event at its first field which fails to parse.
+	FieldErrorsFail FieldErrorMode = iota
+	// FieldErrorsOmit leaves out fields which fail to parse, returning the rest of the event along with FieldErrors.
+	FieldErrorsOmit
+	// FieldErrorsRaw outputs fields which fail to parse as their raw string, under the name of their column, returning
+	// the event along with FieldErrors.
+	FieldErrorsRaw
+)
+
+// handle returns the error of a column which failed to parse if the transformation fails at it, or collects the error
+// in fieldErrs and returns nil with a lenient mode.
+func (mode FieldErrorMode) handle(fieldErrs *FieldErrors, err error, field string, index int, value string) error {
+	parseErr := columnError(err, field, index, value)
+	if mode == FieldErrorsFail {
+		return parseErr
+	}
+	*fieldErrs = append(*fieldErrs, parseErr)
+	return nil
+}
+
+// valid returns an error for unknown modes.
+func (mode FieldErrorMode) valid() error {
+	if mode < FieldErrorsFail || mode > FieldErrorsRaw {
+		return fmt.Errorf("unknown field error mode %v", mode)
+	}
+	return nil
+}
+
+// WithFieldErrorMode sets how fields which fail to parse are handled, FieldErrorsFail by default. The lenient modes
+// return the output of an event along with a FieldErrors listing the fields which failed to parse.
+func WithFieldErrorMode(mode FieldErrorMode) TransformOption {
+	return func(c *transformConfig) {
+		c.fieldErrors = mode
+	}
+}
+
+// defaultTransformer transforms events with the default options, for the methods of ParsedEvent and for batches
+// configured without a Transformer.
+var defaultTransformer = &Transformer{}
+
+// Transformer transforms events to maps and JSON with a fixed set of options. The columns to output are planned once
+// per layout, so a Transformer should be built once and reused. A Transformer is safe for concurrent use.
+type Transformer struct {
+	config transformConfig
+	// plans holds the *transformPlan of each layout
+	plans sync.Map
+}
+
+// transformPlan holds the columns of a layout which a Transformer outputs.
+type transformPlan struct {
+	// include holds whether each column is output, and is nil if all of them are
+	include []bool
+	// geolocation is true if the geo_location field is output
+	geolocation bool
+	// missing holds the fields of LayoutCurrent absent from the layout, which are output as nulls with WithNullFields
+	missing []string
+}
+
+// NewTransformer returns a Transformer for the provided options. It fails if WithFields or WithoutFields are provided
+// fields unknown to every registered layout.
+func NewTransformer(opts ...TransformOption) (*Transformer, error) {
+	t := &Transformer{}
+	for _, opt := range opts {
+		opt(&t.config)
+	}
+	for _, field := range append(append([]string(nil), t.config.fields...), t.config.excluded...) {
+		if !knownField(field) {
+			return nil, fmt.Errorf("cannot create transformer: key %s %w", field, ErrUnknownField)
+		}
+	}
+	if t.config.merge < ContextsLastWins || t.config.merge > ContextsSeparate {
+		return nil, fmt.Errorf("cannot create transformer: unknown context merge mode %v", t.config.merge)
+	}
+	if err := t.config.fieldErrors.valid(); err != nil {
+		return nil, fmt.Errorf("cannot create transformer: %w", err)
+	}
+	if err := t.config.timeFormat.valid(); err != nil {
+		return nil, fmt.Errorf("cannot create transformer: %w", err)
+	}
+	t.plans.Store(LayoutCurrent, t.newPlan(LayoutCurrent))
+	return t, nil
+}
+
+// knownField reports whether a field is a column of enrichedEventFieldTypes or of a registered layout.
+func knownField(field string) bool {
+	if _, ok := indexMap[field]; ok {
+		return true
+	}
+	for _, layout := range *layouts.Load() {
+		if layout.Has(field) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Transformer) newPlan(layout *Layout) *transformPlan {
+	plan := &transformPlan{}
+	if len(t.config.fields) > 0 || len(t.config.excluded) > 0 {
+		plan.include = make([]bool, layout.Len())
+		for index := range plan.include {
+			plan.include[index] = len(t.config.fields) == 0
+		}
+		for _, field := range t.config.fields {
+			if index, ok := layout.index[field]; ok {
+				plan.include[index] = true
+			}
+		}
+		for _, field := range t.config.excluded {
+			if index, ok := layout.index[field]; ok {
+				plan.include[index] = false
+			}
+		}
+	}
+	plan.geolocation = t.config.geolocation && layout.latitude >= 0 && layout.longitude >= 0 &&
+		plan.included(layout.latitude) && plan.included(layout.longitude)
+	if t.config.nullFields {
+		for _, column := range LayoutCurrent.Columns() {
+			if column.Type != FieldContexts && column.Type != FieldUnstruct && !layout.Has(column.Name) && t.selects(column.Name) {
+				plan.missing = append(plan.missing, column.Name)
+			}
+		}
+	}
+	return plan
+}
+
+// selects reports whether a field is output according to WithFields and WithoutFields.
+func (t *Transformer) selects(field string) bool {
+	selected := len(t.config.fields) == 0
+	for _, allowed := range t.config.fields {
+		if allowed == field {
+			selected = true
+		}
+	}
+	for _, excluded := range t.config.excluded {
+		if excluded == field {
+			selected = false
+		}
+	}
+	return selected
+}
+
+func (p *transformPlan) included(index int) bool {
+	return p.include == nil || p.include[index]
+}
+
+// plan returns the plan of a layout, computing it on first use.
+func (t *Transformer) plan(layout *Layout) *transformPlan {
+	if plan, ok := t.plans.Load(layout); ok {
+		return plan.(*transformPlan)
+	}
+	plan, _ := t.plans.LoadOrStore(layout, t.newPlan(layout))
+	return plan.(*transformPlan)
+}
+
+// contextsPrefix returns the prefix of the keys of a contexts column.
+func (t *Transformer) contextsPrefix(field string) string {
+	if t.config.merge == ContextsSeparate {
+		return field
+	}
+	return "contexts"
+}
+
+// ToMap transforms a valid Snowplow ParsedEvent to a Go map. With a lenient FieldErrorMode, the map is returned along
+// with FieldErrors if fields fail to parse.
+func (t *Transformer) ToMap(event ParsedEvent) (map[string]any, error) {
+	layout, err := event.layoutOrErr("transform event")
+	if err != nil {
+		return nil, err
+	}
+	plan := t.plan(layout)
+	output := make(map[string]any)
+	if plan.geolocation && event[layout.latitude] != "" && event[layout.longitude] != "" {
+		output["geo_location"] = event[layout.latitude] + "," + event[layout.longitude]
+	} else if plan.geolocation && t.config.nullFields {
+		output["geo_location"] = nil
+	}
+	for _, field := range plan.missing {
+		output[field] = nil
+	}
+	var fieldErrs FieldErrors
+	for index, value := range event {
+		if !plan.included(index) {
+			continue
+		}
+		field := layout.fields[index]
+		kind := layout.types[index]
+		if value == "" {
+			if t.config.nullFields && kind != FieldContexts && kind != FieldUnstruct {
+				output[field.Key] = nil
+			}
+			continue
+		}
+		var kvPairs []KeyVal
+		switch {
+		case kind == FieldContexts:
+			kvPairs, err = shredContextsWithPrefix(t.contextsPrefix(field.Key), value, t.config.naming)
+		case kind == FieldUnstruct:
+			kvPairs, err = shredUnstruct(value, t.config.naming)
+		case kind == FieldTime && t.config.timeFormat != TimestampTime:
+			var timeValue time.Time
+			if timeValue, err = decodeTime(field.Key, value); err == nil {
+				kvPairs = []KeyVal{{field.Key, t.config.formatTimestamp(timeValue)}}
+			}
+		default:
+			kvPairs, err = field.ParseFunction(field.Key, value)
+		}
+		if err != nil {
+			if t.config.fieldErrors == FieldErrorsFail {
+				return nil, columnError(err, field.Key, index, value)
+			}
+			fieldErrs = append(fieldErrs, columnError(err, field.Key, index, value))
+			if t.config.fieldErrors == FieldErrorsRaw {
+				output[field.Key] = value
+			}
+			continue
+		}
+		for _, pair := range kvPairs {
+			if existing, ok := output[pair.Key].([]any); ok && kind == FieldContexts && t.config.merge == ContextsAppend {
+				output[pair.Key] = append(existing, pair.Value.([]any)...)
+				continue
+			}
+			output[pair.Key] = pair.Value
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return output, fieldErrs
+	}
+	return output, nil
+}
+
+// ToJson transforms a valid Snowplow ParsedEvent to a JSON object.
+func (t *Transformer) ToJson(event ParsedEvent) ([]byte, error) {
+	jsonified, err := t.AppendJson(make([]byte, 0, event.jsonSizeHint()), event)
+	if err != nil && len(jsonified) == 0 {
+		return nil, err
+	}
+	return jsonified, err
+}
+
+// AppendJson transforms a valid Snowplow ParsedEvent to a JSON object, appending it to dst and returning the extended
+// buffer. On error, dst is returned unchanged, unless the object is returned along with FieldErrors.
+func (t *Transformer) AppendJson(dst []byte, event ParsedEvent) ([]byte, error) {
+	layout, err := event.layoutOrErr("transform event")
+	if err != nil {
+		return dst, err
+	}
+	enc := jsonEncoderPool.Get().(*jsonEncoder)
+	defer jsonEncoderPool.Put(enc)
+	enc.reset(layout, t)
+
+	out, err := enc.encode(dst, event)
+	if out == nil {
+		return dst, err
+	}
+	return out, err
+}
+
+// GetSubsetMap returns a map of a subset of the event, as ParsedEvent.GetSubsetMap does. With a lenient FieldErrorMode,
+// the map is returned along with FieldErrors if fields fail to parse. Other options do not apply.
+func (t *Transformer) GetSubsetMap(event ParsedEvent, fields ...string) (map[string]any, error) {
+	layout, err := event.layoutOrErr("get values")
+	if err != nil {
+		return nil, err
+	}
+	output := make(map[string]any)
+	var fieldErrs FieldErrors
+	for _, field := range fields {
+		index, present, err := layout.fieldIndex(field)
+		if err != nil {
+			return nil, err
+		}
+		if !present || event[index] == "" {
+			continue
+		}
+		column := layout.fields[index]
+		kvPairs, err := column.ParseFunction(column.Key, event[index])
+		if err != nil {
+			if err := t.config.fieldErrors.handle(&fieldErrs, err, column.Key, index, event[index]); err != nil {
+				return nil, err
+			}
+			if t.config.fieldErrors == FieldErrorsRaw {
+				output[column.Key] = event[index]
+			}
+			continue
+		}
+		for _, pair := range kvPairs {
+			output[pair.Key] = pair.Value
+		}
+	}
+	return output, fieldErrs.orNil()
+}
@@ -0,0 +1,431 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+var derivedWebPageContexts = `{"schema":"iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-1","data":[{"schema":"iglu:org.schema/WebPage/jsonschema/1-0-0","data":{"genre":"derived"}}]}`
+
+// mustTransformer returns a Transformer for the provided options, which must be valid.
+func mustTransformer(opts ...TransformOption) *Transformer {
+	transformer, err := NewTransformer(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return transformer
+}
+
+func TestNewTransformer(t *testing.T) {
+	assert := assert.New(t)
+
+	transformer, err := NewTransformer(WithFields("app_id", "contexts"), WithoutFields("true_tstamp"))
+	assert.Nil(err)
+	assert.NotNil(transformer)
+
+	transformer, err = NewTransformer(WithFields("app_id", "not_a_field"))
+	assert.EqualError(err, "cannot create transformer: key not_a_field not a valid atomic field")
+	assert.Nil(transformer)
+
+	_, err = NewTransformer(WithoutFields("not_a_field"))
+	assert.NotNil(err)
+
+	_, err = NewTransformer(WithContextMergeMode(ContextMergeMode(5)))
+	assert.EqualError(err, "cannot create transformer: unknown context merge mode 5")
+}
+
+func TestTransformerToMap(t *testing.T) {
+	assert := assert.New(t)
+
+	// defaults match ToMap
+	transformer, err := NewTransformer()
+	assert.Nil(err)
+	mapped, err := transformer.ToMap(fullEvent)
+	assert.Nil(err)
+	assert.Equal(eventMapWithoutGeo, mapped)
+
+	transformer, err = NewTransformer(WithGeoLocation())
+	assert.Nil(err)
+	mapped, err = transformer.ToMap(fullEvent)
+	assert.Nil(err)
+	assert.Equal(eventMapWithGeo, mapped)
+
+	// null fields
+	transformer, err = NewTransformer(WithNullFields())
+	assert.Nil(err)
+	mapped, err = transformer.ToMap(fullEvent)
+	assert.Nil(err)
+	assert.Contains(mapped, "se_category")
+	assert.Nil(mapped["se_category"])
+	assert.NotContains(mapped, "contexts")
+
+	// allowed and denied fields
+	transformer, err = NewTransformer(WithFields("app_id", "collector_tstamp", "unstruct_event", "geo_latitude"), WithoutFields("collector_tstamp"), WithGeoLocation())
+	assert.Nil(err)
+	mapped, err = transformer.ToMap(fullEvent)
+	assert.Nil(err)
+	assert.Equal(map[string]any{
+		"app_id":       "<>angry-birds",
+		"geo_latitude": 37.443604,
+		"unstruct_event_com_snowplowanalytics_snowplow_link_click_1": eventMapWithGeo["unstruct_event_com_snowplowanalytics_snowplow_link_click_1"],
+	}, mapped)
+
+	transformer, err = NewTransformer(WithoutFields("contexts", "derived_contexts", "unstruct_event"))
+	assert.Nil(err)
+	mapped, err = transformer.ToMap(fullEvent)
+	assert.Nil(err)
+	assert.NotContains(mapped, "contexts_org_schema_web_page_1")
+	assert.NotContains(mapped, "unstruct_event_com_snowplowanalytics_snowplow_link_click_1")
+	assert.Contains(mapped, "app_id")
+
+	// timestamp layout
+	transformer, err = NewTransformer(WithTimestampLayout(time.DateTime))
+	assert.Nil(err)
+	mapped, err = transformer.ToMap(fullEvent)
+	assert.Nil(err)
+	assert.Equal("2013-11-26 00:03:57", mapped["collector_tstamp"])
+
+	// timestamp formats
+	for _, test := range []struct {
+		format   TimestampFormat
+		expected any
+	}{
+		{TimestampTime, tstampValue},
+		{TimestampRFC3339Millis, "2013-11-26T00:03:57.885Z"},
+		{TimestampUnixMillis, int64(1385424237885)},
+		{TimestampUnixMicros, int64(1385424237885000)},
+	} {
+		transformer, err := NewTransformer(WithTimestampFormat(test.format))
+		assert.Nil(err)
+		mapped, err := transformer.ToMap(fullEvent)
+		assert.Nil(err)
+		assert.Equal(test.expected, mapped["collector_tstamp"], test.format)
+	}
+	_, err = NewTransformer(WithTimestampFormat(TimestampFormat(42)))
+	assert.NotNil(err)
+
+	// errors
+	_, err = transformer.ToMap(ParsedEvent{"one", "two"})
+	assert.NotNil(err)
+	invalid := append(ParsedEvent{}, fullEvent...)
+	invalid[indexMap["collector_tstamp"]] = "not a tstamp"
+	_, err = transformer.ToMap(invalid)
+	assert.NotNil(err)
+}
+
+func TestTransformerContextMergeMode(t *testing.T) {
+	assert := assert.New(t)
+
+	event := append(ParsedEvent{}, fullEvent...)
+	event[indexMap["derived_contexts"]] = derivedWebPageContexts
+	webPage := eventMapWithoutGeo["contexts_org_schema_web_page_1"].([]any)
+	derived := []any{map[string]any{"genre": "derived"}}
+
+	for _, test := range []struct {
+		mode     ContextMergeMode
+		expected map[string]any
+	}{
+		{ContextsLastWins, map[string]any{"contexts_org_schema_web_page_1": derived}},
+		{ContextsAppend, map[string]any{"contexts_org_schema_web_page_1": append(append([]any{}, webPage...), derived...)}},
+		{ContextsSeparate, map[string]any{"contexts_org_schema_web_page_1": webPage, "derived_contexts_org_schema_web_page_1": derived}},
+	} {
+		transformer, err := NewTransformer(WithContextMergeMode(test.mode), WithFields("contexts", "derived_contexts"))
+		assert.Nil(err)
+		mapped, err := transformer.ToMap(event)
+		assert.Nil(err)
+		delete(mapped, "contexts_org_w3_performance_timing_1")
+		assert.Equal(test.expected, mapped)
+
+		jsonified, err := transformer.ToJson(event)
+		assert.Nil(err)
+		expected, err := jsoniter.Marshal(test.expected)
+		assert.Nil(err)
+		decoded := make(map[string]any)
+		assert.Nil(jsoniter.Unmarshal(jsonified, &decoded))
+		delete(decoded, "contexts_org_w3_performance_timing_1")
+		actual, err := jsoniter.Marshal(decoded)
+		assert.Nil(err)
+		assert.JSONEq(string(expected), string(actual))
+	}
+}
+
+func TestTransformerToJson(t *testing.T) {
+	assert := assert.New(t)
+
+	// JSON matches the map for every option
+	for _, opts := range [][]TransformOption{
+		nil,
+		{WithGeoLocation()},
+		{WithNullFields(), WithGeoLocation()},
+		{WithFields("app_id", "contexts", "geo_latitude", "geo_longitude"), WithGeoLocation()},
+		{WithoutFields("unstruct_event", "geo_longitude"), WithGeoLocation()},
+		{WithTimestampLayout(time.RFC1123)},
+		{WithTimestampFormat(TimestampRFC3339Millis)},
+		{WithTimestampFormat(TimestampUnixMillis), WithNullFields()},
+		{WithTimestampFormat(TimestampUnixMicros)},
+		{WithNamingStrategy(BigQueryNaming), WithContextMergeMode(ContextsSeparate)},
+	} {
+		transformer, err := NewTransformer(opts...)
+		assert.Nil(err)
+		mapped, err := transformer.ToMap(fullEvent)
+		assert.Nil(err)
+		expected, err := jsoniter.Marshal(mapped)
+		assert.Nil(err)
+		jsonified, err := transformer.ToJson(fullEvent)
+		assert.Nil(err)
+		assert.JSONEq(string(expected), string(jsonified))
+		appended, err := transformer.AppendJson([]byte("prefix"), fullEvent)
+		assert.Nil(err)
+		assert.Equal("prefix"+string(jsonified), string(appended))
+	}
+
+	transformer, err := NewTransformer(WithNullFields())
+	assert.Nil(err)
+	out, err := transformer.AppendJson([]byte("prefix"), ParsedEvent{"one", "two"})
+	assert.NotNil(err)
+	assert.Equal("prefix", string(out))
+
+	// older layouts are planned on first use, and output the fields they lack as nulls
+	short := append(ParsedEvent{}, fullEvent[:LayoutNoDerivedFields.Len()]...)
+	jsonified, err := transformer.ToJson(short)
+	assert.Nil(err)
+	assert.Contains(string(jsonified), `"se_category":null`)
+	assert.Contains(string(jsonified), `"true_tstamp":null`)
+}
+
+func TestTransformerFieldErrorMode(t *testing.T) {
+	assert := assert.New(t)
+
+	invalid := withField(withField(withField(fullEvent, "page_urlport", "eighty"), "br_cookies", "maybe"), "contexts", invalidCtxt)
+
+	// strict by default
+	transformer, err := NewTransformer(WithFieldErrorMode(FieldErrorsFail))
+	assert.Nil(err)
+	mapped, err := transformer.ToMap(invalid)
+	assert.Nil(mapped)
+	assert.Equal("page_urlport", err.(*FieldParseError).Field)
+	jsonified, err := transformer.ToJson(invalid)
+	assert.Nil(jsonified)
+	assert.NotNil(err)
+
+	for _, mode := range []FieldErrorMode{FieldErrorsOmit, FieldErrorsRaw} {
+		transformer, err := NewTransformer(WithFieldErrorMode(mode))
+		assert.Nil(err)
+		mapped, err := transformer.ToMap(invalid)
+		var fieldErrs FieldErrors
+		assert.True(errors.As(err, &fieldErrs))
+		assert.Len(fieldErrs, 3)
+		assert.Equal([]string{"page_urlport", "contexts", "br_cookies"}, []string{fieldErrs[0].Field, fieldErrs[1].Field, fieldErrs[2].Field})
+		assert.True(errors.Is(err, strconv.ErrSyntax))
+		assert.True(errors.As(err, new(*SchemaError)))
+		assert.Equal(eventMapWithoutGeo["app_id"], mapped["app_id"])
+		assert.Equal(eventMapWithoutGeo["unstruct_event_com_snowplowanalytics_snowplow_link_click_1"], mapped["unstruct_event_com_snowplowanalytics_snowplow_link_click_1"])
+		assert.NotContains(mapped, "contexts_org_schema_web_page_1")
+		if mode == FieldErrorsOmit {
+			assert.NotContains(mapped, "page_urlport")
+			assert.NotContains(mapped, "br_cookies")
+			assert.NotContains(mapped, "contexts")
+		} else {
+			assert.Equal("eighty", mapped["page_urlport"])
+			assert.Equal("maybe", mapped["br_cookies"])
+			assert.Equal(invalidCtxt, mapped["contexts"])
+		}
+
+		// JSON matches the map
+		jsonified, err := transformer.ToJson(invalid)
+		assert.Equal(fieldErrs, err)
+		expected, _ := jsoniter.Marshal(mapped)
+		assert.JSONEq(string(expected), string(jsonified))
+		appended, err := transformer.AppendJson([]byte("prefix"), invalid)
+		assert.NotNil(err)
+		assert.Equal("prefix"+string(jsonified), string(appended))
+		jsonified, err = mustTransformer(WithGeoLocation(), WithFieldErrorMode(mode)).ToJson(invalid)
+		assert.NotNil(err)
+		assert.NotNil(jsonified)
+
+		// valid events have no errors
+		_, err = transformer.ToMap(fullEvent)
+		assert.Nil(err)
+	}
+
+	_, err = NewTransformer(WithFieldErrorMode(FieldErrorMode(3)))
+	assert.NotNil(err)
+}
+
+func TestTransformerFieldErrorModeEntryPoints(t *testing.T) {
+	assert := assert.New(t)
+
+	invalid := withField(fullEvent, "page_urlport", "eighty")
+	omit := mustTransformer(WithFieldErrorMode(FieldErrorsOmit))
+	raw := mustTransformer(WithFieldErrorMode(FieldErrorsRaw))
+
+	// structs leave failed fields unset
+	structured, err := omit.ToStruct(invalid)
+	var fieldErrs FieldErrors
+	assert.True(errors.As(err, &fieldErrs))
+	assert.Len(fieldErrs, 1)
+	assert.Nil(structured.PageUrlport)
+	assert.Equal(fullEvent[0], *structured.AppID)
+	structured, err = raw.ToStruct(invalid)
+	assert.NotNil(err)
+	assert.Nil(structured.PageUrlport)
+	_, err = defaultTransformer.ToStruct(invalid)
+	assert.False(errors.As(err, &fieldErrs))
+
+	// loader rows
+	row, err := omit.ToLoaderRow(invalid, LoaderBigQuery)
+	assert.True(errors.As(err, &fieldErrs))
+	assert.Contains(row, "page_urlport")
+	assert.Nil(row["page_urlport"])
+	row, err = raw.ToLoaderRow(invalid, LoaderBigQuery)
+	assert.NotNil(err)
+	assert.Equal("eighty", row["page_urlport"])
+	_, err = omit.ToLoaderRow(invalid, LoaderFormat(9))
+	assert.False(errors.As(err, &fieldErrs))
+
+	// subsets
+	subset, err := omit.GetSubsetMap(invalid, "app_id", "page_urlport")
+	assert.True(errors.As(err, &fieldErrs))
+	assert.Equal(map[string]any{"app_id": fullEvent[0]}, subset)
+	subset, err = raw.GetSubsetMap(invalid, "app_id", "page_urlport")
+	assert.NotNil(err)
+	assert.Equal(map[string]any{"app_id": fullEvent[0], "page_urlport": "eighty"}, subset)
+	_, err = omit.GetSubsetMap(invalid, "not_a_field")
+	assert.True(errors.Is(err, ErrUnknownField))
+
+	// valid events have no errors
+	_, err = omit.ToStruct(fullEvent)
+	assert.Nil(err)
+	_, err = omit.ToLoaderRow(fullEvent, LoaderSnowflake)
+	assert.Nil(err)
+	_, err = omit.GetSubsetMap(fullEvent, "app_id", "page_urlport")
+	assert.Nil(err)
+}
+
+func BenchmarkTransformerFieldErrorMode(b *testing.B) {
+	b.ReportAllocs()
+	transformer, _ := NewTransformer(WithFieldErrorMode(FieldErrorsOmit))
+	invalid := withField(fullEvent, "page_urlport", "eighty")
+	var buf []byte
+	for i := 0; i < b.N; i++ {
+		buf, _ = transformer.AppendJson(buf[:0], invalid)
+	}
+}
+
+func TestTransformerNullFields(t *testing.T) {
+	assert := assert.New(t)
+
+	// every event has the same keys, other than those of its contexts and unstruct event
+	transformer, err := NewTransformer(WithNullFields(), WithGeoLocation())
+	assert.Nil(err)
+	atomicKeys := func(mapped map[string]any) []string {
+		var keys []string
+		for key := range mapped {
+			if !strings.HasPrefix(key, "contexts_") && !strings.HasPrefix(key, "unstruct_event_") {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	full, err := transformer.ToMap(fullEvent)
+	assert.Nil(err)
+	assert.Len(atomicKeys(full), LayoutCurrent.Len()-3+1)
+
+	empty := make(ParsedEvent, LayoutCurrent.Len())
+	for _, event := range []ParsedEvent{empty, empty[:LayoutNoTrueTstamp.Len()], fullEvent[:LayoutNoDerivedFields.Len()]} {
+		mapped, err := transformer.ToMap(event)
+		assert.Nil(err)
+		assert.Equal(atomicKeys(full), atomicKeys(mapped))
+
+		jsonified, err := transformer.ToJson(event)
+		assert.Nil(err)
+		decoded := make(map[string]any)
+		assert.Nil(jsoniter.Unmarshal(jsonified, &decoded))
+		assert.Equal(atomicKeys(full), atomicKeys(decoded))
+	}
+	mapped, err := transformer.ToMap(empty)
+	assert.Nil(err)
+	assert.Contains(mapped, "geo_location")
+	assert.Nil(mapped["geo_location"])
+
+	// fields left out are not output as nulls
+	transformer, err = NewTransformer(WithNullFields(), WithFields("app_id", "true_tstamp"), WithoutFields("app_id"))
+	assert.Nil(err)
+	mapped, err = transformer.ToMap(empty[:LayoutNoTrueTstamp.Len()])
+	assert.Nil(err)
+	assert.Equal(map[string]any{"true_tstamp": nil}, mapped)
+
+	// the option applies to ToMap and ToJson
+	transformer, err = NewTransformer(WithNullFields())
+	assert.Nil(err)
+	mapped, err = transformer.ToMap(empty)
+	assert.Nil(err)
+	assert.Len(mapped, LayoutCurrent.Len()-3)
+	jsonified, err := mustTransformer(WithNullFields(), WithGeoLocation()).ToJson(empty)
+	assert.Nil(err)
+	assert.Contains(string(jsonified), `"geo_location":null`)
+}
+
+func TestTransformerConcurrentUse(t *testing.T) {
+	assert := assert.New(t)
+
+	transformer, err := NewTransformer(WithNullFields(), WithoutFields("user_ipaddress"))
+	assert.Nil(err)
+	expected, err := transformer.ToJson(fullEvent)
+	assert.Nil(err)
+
+	events := []ParsedEvent{fullEvent, fullEvent[:LayoutNoTrueTstamp.Len()], fullEvent[:LayoutNoEventFingerprint.Len()]}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				jsonified, err := transformer.ToJson(events[j%len(events)])
+				assert.Nil(err)
+				if j%len(events) == 0 {
+					assert.Equal(expected, jsonified)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkTransformerToJson(b *testing.B) {
+	transformer, _ := NewTransformer(WithNullFields(), WithGeoLocation())
+	var buf []byte
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		buf, _ = transformer.AppendJson(buf[:0], fullEvent)
+	}
+}
+
+func BenchmarkTransformerToMap(b *testing.B) {
+	transformer, _ := NewTransformer(WithNullFields(), WithGeoLocation())
+	for n := 0; n < b.N; n++ {
+		transformer.ToMap(fullEvent)
+	}
+}
@@ -0,0 +1,238 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// tsvTimeLayout is the layout used by the enrich process when writing timestamp columns
+const tsvTimeLayout string = "2006-01-02 15:04:05.000"
+
+// tsvMicroTimeLayout and tsvNanoTimeLayout write timestamps more precise than milliseconds, which are parsed back as
+// any fractional second precision is
+const (
+	tsvMicroTimeLayout string = "2006-01-02 15:04:05.000000"
+	tsvNanoTimeLayout  string = "2006-01-02 15:04:05.000000000"
+)
+
+// contextsSchema and unstructEventSchema are the schemas of the self-describing wrappers of contexts and unstruct
+// events, which are set when the wrappers have none
+const (
+	contextsSchema      string = "iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-0"
+	unstructEventSchema string = "iglu:com.snowplowanalytics.snowplow/unstruct_event/jsonschema/1-0-0"
+)
+
+// tsvJson sorts the keys of maps, so that the JSON columns of events written from the same values are identical
+var tsvJson = jsoniter.Config{SortMapKeys: true}.Froze()
+
+// ToTSV transforms a valid Snowplow ParsedEvent back to an enriched event tsv string.
+func (event ParsedEvent) ToTSV() (string, error) {
+	layout, err := event.layoutOrErr("serialize event")
+	if err != nil {
+		return "", err
+	}
+	for index, value := range event {
+		if strings.ContainsAny(value, "\t\n") {
+			return "", fmt.Errorf("cannot serialize field %s: value contains a tab or newline", layout.fields[index].Key)
+		}
+	}
+	return strings.Join(event, "\t"), nil
+}
+
+// FromMap builds a ParsedEvent from a map of atomic field names to values. Values may be provided as strings in their
+// tsv representation, or as the Go types produced by GetValue and ToStruct, either directly or as pointers.
+// The contexts and derived_contexts fields accept Contexts, and unstruct_event accepts UnstructEvent; these are
+// re-encoded as self-describing JSON. Shredded keys as produced by ToMap, such as contexts_com_acme_my_context_1, are
+// rejected, as the full schema URI of the data is not recoverable from them: the output of ToMap for events with
+// contexts or unstruct events cannot be converted back with FromMap. The derived geo_location field is ignored.
+func FromMap(fields map[string]any) (ParsedEvent, error) {
+	event := make(ParsedEvent, eventLength)
+	for key, value := range fields {
+		if key == "geo_location" {
+			continue
+		}
+		index, ok := indexMap[key]
+		if !ok && (strings.HasPrefix(key, "contexts_") || strings.HasPrefix(key, "unstruct_event_")) {
+			return nil, fmt.Errorf("key %s %w: shredded keys cannot be converted back to self-describing data", key, ErrUnknownField)
+		}
+		if !ok {
+			return nil, fmt.Errorf("key %s %w", key, ErrUnknownField)
+		}
+		formatted, err := formatField(int(index), value)
+		if err != nil {
+			return nil, err
+		}
+		event[index] = formatted
+	}
+	return event, nil
+}
+
+// FromStruct builds a ParsedEvent from an EnrichedEvent. Zero timestamps are written as empty columns, and others with
+// milliseconds as the enrich process does, or with as many digits as needed to keep their precision.
+func FromStruct(enriched *EnrichedEvent) (ParsedEvent, error) {
+	event := make(ParsedEvent, eventLength)
+	for index := range event {
+		formatted, err := formatField(index, enriched.fieldValue(index))
+		if err != nil {
+			return nil, err
+		}
+		event[index] = formatted
+	}
+	return event, nil
+}
+
+// formatField converts a value to its tsv representation, and checks that it can be parsed back as the column at the given index.
+func formatField(index int, value any) (string, error) {
+	key := enrichedEventFieldTypes[index].Key
+	formatted, err := formatValue(value)
+	if err != nil {
+		return "", fmt.Errorf("cannot serialize field %s: %w", key, err)
+	}
+	if formatted == "" {
+		return "", nil
+	}
+	if strings.ContainsAny(formatted, "\t\n") {
+		return "", fmt.Errorf("cannot serialize field %s: value contains a tab or newline", key)
+	}
+	if _, err := enrichedEventFieldTypes[index].ParseFunction(key, formatted); err != nil {
+		return "", fmt.Errorf("cannot serialize field %s: %w", key, err)
+	}
+	return formatted, nil
+}
+
+func formatValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case *string:
+		if v == nil {
+			return "", nil
+		}
+		return *v, nil
+	case int:
+		return strconv.Itoa(v), nil
+	case *int:
+		if v == nil {
+			return "", nil
+		}
+		return strconv.Itoa(*v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case *float64:
+		if v == nil {
+			return "", nil
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64), nil
+	case bool:
+		return formatBool(v), nil
+	case *bool:
+		if v == nil {
+			return "", nil
+		}
+		return formatBool(*v), nil
+	case time.Time:
+		return formatTime(v), nil
+	case *time.Time:
+		if v == nil {
+			return "", nil
+		}
+		return formatTime(*v), nil
+	case Contexts:
+		return formatContexts(v)
+	case *Contexts:
+		if v == nil {
+			return "", nil
+		}
+		return formatContexts(*v)
+	case UnstructEvent:
+		return formatUnstructEvent(v)
+	case *UnstructEvent:
+		if v == nil {
+			return "", nil
+		}
+		return formatUnstructEvent(*v)
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+func formatBool(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
+
+func formatTime(value time.Time) string {
+	if value.IsZero() {
+		return ""
+	}
+	value = value.UTC()
+	switch {
+	case value.Nanosecond()%int(time.Millisecond) == 0:
+		return value.Format(tsvTimeLayout)
+	case value.Nanosecond()%int(time.Microsecond) == 0:
+		return value.Format(tsvMicroTimeLayout)
+	default:
+		return value.Format(tsvNanoTimeLayout)
+	}
+}
+
+func formatContexts(value Contexts) (string, error) {
+	schema, err := wrapperSchema(value.Schema, contextsSchema)
+	if err != nil {
+		return "", err
+	}
+	value.Schema = schema
+	return formatJson(value)
+}
+
+func formatUnstructEvent(value UnstructEvent) (string, error) {
+	schema, err := wrapperSchema(value.Schema, unstructEventSchema)
+	if err != nil {
+		return "", err
+	}
+	value.Schema = schema
+	return formatJson(value)
+}
+
+// wrapperSchema returns the schema of a self-describing wrapper, defaulting to the provided schema, and fails for
+// schemas of another model than the default one.
+func wrapperSchema(schema string, defaultSchema string) (string, error) {
+	if schema == "" {
+		return defaultSchema, nil
+	}
+	if !strings.HasPrefix(schema, strings.TrimSuffix(defaultSchema, "0-0")) {
+		return "", fmt.Errorf("invalid wrapper schema %s, expected %s", schema, defaultSchema)
+	}
+	return schema, nil
+}
+
+func formatJson(value any) (string, error) {
+	out, err := tsvJson.MarshalToString(value)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling to JSON: %w", err)
+	}
+	return out, nil
+}
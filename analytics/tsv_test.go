@@ -0,0 +1,202 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToTSV(t *testing.T) {
+	assert := assert.New(t)
+
+	// correct value
+	tsv, err := fullEvent.ToTSV()
+	assert.Nil(err)
+	assert.Equal(tsvEvent, tsv)
+
+	// round trip
+	parsedEvent, err := ParseEvent(tsv)
+	assert.Nil(err)
+	assert.Equal(fullEvent, parsedEvent)
+
+	// incorrect input length
+	failedTsv, err := ParsedEvent([]string{"one", "two"}).ToTSV()
+	assert.NotNil(err)
+	assert.Zero(failedTsv)
+
+	// field containing a tab
+	tabEvent := append(ParsedEvent{}, fullEvent...)
+	tabEvent[indexMap["page_title"]] = "On\tAnalytics"
+	failedTsv, err = tabEvent.ToTSV()
+	assert.NotNil(err)
+	assert.Zero(failedTsv)
+}
+
+func BenchmarkToTSV(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		fullEvent.ToTSV()
+	}
+}
+
+func TestFromMap(t *testing.T) {
+	assert := assert.New(t)
+
+	port := 80
+	contexts, _ := fullEvent.ToStruct()
+
+	// correct values
+	event, err := FromMap(map[string]any{
+		"app_id":           "<>angry-birds",
+		"collector_tstamp": tstampValue,
+		"page_urlport":     &port,
+		"geo_latitude":     37.443604,
+		"br_features_pdf":  true,
+		"br_features_java": (*bool)(nil),
+		"txn_id":           "41828",
+		"contexts":         contexts.Contexts,
+		"unstruct_event":   *contexts.UnstructEvent,
+		"geo_location":     "37.443604,-122.4124",
+	})
+	assert.Nil(err)
+	assert.Len(event, eventLength)
+	assert.Equal("<>angry-birds", event[indexMap["app_id"]])
+	assert.Equal("2013-11-26 00:03:57.885", event[indexMap["collector_tstamp"]])
+	assert.Equal("80", event[indexMap["page_urlport"]])
+	assert.Equal("37.443604", event[indexMap["geo_latitude"]])
+	assert.Equal("1", event[indexMap["br_features_pdf"]])
+	assert.Equal("", event[indexMap["br_features_java"]])
+	assert.Equal("41828", event[indexMap["txn_id"]])
+
+	contextsValue, err := event.GetValue("contexts")
+	assert.Nil(err)
+	assert.Equal(multipleContextsMap, contextsValue)
+	unstructValue, err := event.GetValue("unstruct_event")
+	assert.Nil(err)
+	assert.Equal(unstructMap, unstructValue)
+
+	// unknown field, and shredded keys of ToMap
+	failedEvent, err := FromMap(map[string]any{"not_a_field": "value"})
+	assert.True(errors.Is(err, ErrUnknownField))
+	assert.Nil(failedEvent)
+	failedEvent, err = FromMap(map[string]any{"contexts_org_schema_web_page_1": []any{}})
+	assert.True(errors.Is(err, ErrUnknownField))
+	assert.Contains(err.Error(), "shredded keys")
+	assert.Nil(failedEvent)
+
+	// value of the wrong type
+	failedEvent, err = FromMap(map[string]any{"page_urlport": "eighty"})
+	assert.NotNil(err)
+	assert.Nil(failedEvent)
+
+	failedEvent, err = FromMap(map[string]any{"page_urlport": []string{"80"}})
+	assert.NotNil(err)
+	assert.Nil(failedEvent)
+
+	// invalid contexts
+	failedEvent, err = FromMap(map[string]any{"contexts": invalidCtxt})
+	assert.NotNil(err)
+	assert.Nil(failedEvent)
+
+	// wrappers without a schema, and with the schema of another model
+	unschemed := contexts.Contexts
+	unschemed.Schema = ""
+	event, err = FromMap(map[string]any{"contexts": unschemed, "unstruct_event": UnstructEvent{Data: contexts.UnstructEvent.Data}})
+	assert.Nil(err)
+	assert.True(strings.HasPrefix(event[indexMap["contexts"]], `{"schema":"`+contextsSchema+`"`))
+	assert.True(strings.HasPrefix(event[indexMap["unstruct_event"]], `{"schema":"`+unstructEventSchema+`"`))
+	unschemed.Schema = "iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/2-0-0"
+	failedEvent, err = FromMap(map[string]any{"contexts": unschemed})
+	assert.NotNil(err)
+	assert.Nil(failedEvent)
+	failedEvent, err = FromMap(map[string]any{"unstruct_event": UnstructEvent{Schema: contextsSchema}})
+	assert.NotNil(err)
+	assert.Nil(failedEvent)
+
+	// keys of data maps are sorted
+	sorted, err := FromMap(map[string]any{"unstruct_event": UnstructEvent{Data: SelfDescribingData{
+		Schema: "iglu:com.acme/event/jsonschema/1-0-0",
+		Data:   map[string]any{"c": 1, "a": 2, "b": 3},
+	}}})
+	assert.Nil(err)
+	assert.Contains(sorted[indexMap["unstruct_event"]], `"data":{"a":2,"b":3,"c":1}`)
+
+	// value containing a newline
+	failedEvent, err = FromMap(map[string]any{"page_title": "On\nAnalytics"})
+	assert.NotNil(err)
+	assert.Nil(failedEvent)
+}
+
+func TestFromStruct(t *testing.T) {
+	assert := assert.New(t)
+
+	enriched, err := fullEvent.ToStruct()
+	assert.Nil(err)
+
+	// redact a field and serialize
+	enriched.UserIpaddress = nil
+	localTime := tstampValue.In(time.FixedZone("CET", 3600))
+	enriched.DerivedTstamp = &localTime
+
+	event, err := FromStruct(enriched)
+	assert.Nil(err)
+	for index, value := range event {
+		switch enrichedEventFieldTypes[index].Key {
+		case "user_ipaddress":
+			assert.Equal("", value)
+		case "contexts", "derived_contexts", "unstruct_event":
+			// JSON is re-encoded, so only compare the decoded values
+			expected, _ := fullEvent.GetValue(enrichedEventFieldTypes[index].Key)
+			actual, err := event.GetValue(enrichedEventFieldTypes[index].Key)
+			assert.Nil(err)
+			assert.Equal(expected, actual)
+		default:
+			assert.Equal(fullEvent[index], value)
+		}
+	}
+
+	// round trip through the struct
+	roundTripped, err := event.ToStruct()
+	assert.Nil(err)
+	assert.Equal(enriched.EventID, roundTripped.EventID)
+	assert.True(enriched.DerivedTstamp.Equal(*roundTripped.DerivedTstamp))
+	assert.Equal(enriched.Contexts, roundTripped.Contexts)
+
+	// timestamps more precise than milliseconds keep their precision
+	for _, value := range []string{"2013-11-26 00:03:57.885123", "2013-11-26 00:03:57.885123456"} {
+		precise := withField(fullEvent, "derived_tstamp", value)
+		structured, err := precise.ToStruct()
+		assert.Nil(err)
+		event, err = FromStruct(structured)
+		assert.Nil(err)
+		assert.Equal(value, event[indexMap["derived_tstamp"]])
+	}
+
+	// invalid contexts
+	enriched.Contexts.Data[0].Schema = "fail"
+	failedEvent, err := FromStruct(enriched)
+	assert.NotNil(err)
+	assert.Nil(failedEvent)
+}
+
+func BenchmarkFromStruct(b *testing.B) {
+	enriched, _ := fullEvent.ToStruct()
+	for i := 0; i < b.N; i++ {
+		FromStruct(enriched)
+	}
+}
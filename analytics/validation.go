@@ -0,0 +1,246 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/iglu"
+)
+
+// Violation describes a self-describing entity of an event whose data does not validate against its schema.
+type Violation struct {
+	// Field is the atomic field holding the entity: contexts, derived_contexts or unstruct_event.
+	Field string
+	// Index is the position of the entity in its contexts, and 0 for unstruct events.
+	Index int
+	// Schema is the schema URI of the entity.
+	Schema string
+	// Path is the JSON pointer to the invalid value within the entity's data.
+	Path    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s[%v] %s at '%s': %s", v.Field, v.Index, v.Schema, v.Path, v.Message)
+}
+
+// ValidationError is returned when self-describing entities of an event do not validate against their schemas.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.String()
+	}
+	return fmt.Sprintf("event failed schema validation: %s", strings.Join(messages, "; "))
+}
+
+// maxCachedSchemas is the number of compiled schemas a SchemaValidator caches. Once it is reached, the cache is
+// cleared along with the compiler holding the schema documents, and schemas are compiled again on their next use.
+const maxCachedSchemas = 1024
+
+// SchemaValidator validates the contexts, derived contexts and unstruct event of events against their JSON Schemas,
+// resolved from Iglu repositories. Schemas are compiled once and cached, up to maxCachedSchemas of them, and a
+// SchemaValidator is safe for concurrent use. Schemas which are not found are not cached, so the cache only grows with
+// the schemas of the repositories.
+type SchemaValidator struct {
+	resolver *iglu.Resolver
+	mu       sync.Mutex
+	compiler *jsonschema.Compiler
+	schemas  map[string]*jsonschema.Schema
+}
+
+// NewSchemaValidator returns a validator resolving schemas from a file system laid out as an Iglu static repository,
+// such as an embed.FS or os.DirFS of a local repository, with each schema stored at
+// schemas/<vendor>/<name>/<format>/<version>, for example schemas/com.acme/my_context/jsonschema/1-0-0.
+func NewSchemaValidator(fsys fs.FS) *SchemaValidator {
+	return NewResolverValidator(iglu.NewResolver([]iglu.Repository{iglu.NewFSRepository(iglu.RepositoryConfig{Name: "fs"}, fsys)}))
+}
+
+// NewResolverValidator returns a validator resolving schemas with the provided Iglu resolver.
+func NewResolverValidator(resolver *iglu.Resolver) *SchemaValidator {
+	return &SchemaValidator{
+		resolver: resolver,
+		compiler: newSchemaCompiler(),
+		schemas:  make(map[string]*jsonschema.Schema),
+	}
+}
+
+func newSchemaCompiler() *jsonschema.Compiler {
+	compiler := jsonschema.NewCompiler()
+	// Iglu schemas are JSON Schema draft 4, and their $schema refers to the Iglu meta-schema, which is not resolvable.
+	compiler.DefaultDraft(jsonschema.Draft4)
+	return compiler
+}
+
+// schema returns the compiled schema for a schema URI, or nil if no repository holds it.
+func (v *SchemaValidator) schema(ctx context.Context, uri string) (*jsonschema.Schema, error) {
+	v.mu.Lock()
+	compiled, ok := v.schemas[uri]
+	v.mu.Unlock()
+	if ok {
+		return compiled, nil
+	}
+
+	key, err := iglu.ParseSchemaKey(uri)
+	if err != nil {
+		return nil, err
+	}
+	// the lookup may go over the network, so it is done without holding the lock
+	file, err := v.resolver.Lookup(ctx, key)
+	if errors.Is(err, iglu.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(file, &doc); err != nil {
+		return nil, fmt.Errorf("cannot parse schema %s: %w", uri, err)
+	}
+	delete(doc, "$schema")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if compiled, ok := v.schemas[uri]; ok {
+		return compiled, nil
+	}
+	if len(v.schemas) >= maxCachedSchemas {
+		v.compiler = newSchemaCompiler()
+		clear(v.schemas)
+	}
+	if err := v.compiler.AddResource(uri, doc); err != nil {
+		return nil, fmt.Errorf("cannot compile schema %s: %w", uri, err)
+	}
+	compiled, err = v.compiler.Compile(uri)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compile schema %s: %w", uri, err)
+	}
+	v.schemas[uri] = compiled
+	return compiled, nil
+}
+
+// Validate validates every self-describing entity of the event against its schema.
+// It returns a *ValidationError listing all violations, including entities whose schema cannot be found.
+// The context bounds the lookups of schemas which are not compiled yet.
+func (v *SchemaValidator) Validate(ctx context.Context, event ParsedEvent) error {
+	layout, err := event.layoutOrErr("validate event")
+	if err != nil {
+		return err
+	}
+	var violations []Violation
+	for index, value := range event {
+		if value == "" {
+			continue
+		}
+		key := layout.fields[index].Key
+		switch layout.types[index] {
+		case FieldContexts:
+			contexts, err := decodeContexts(key, value)
+			if err != nil {
+				return columnError(err, key, index, value)
+			}
+			for i, entity := range contexts.Data {
+				if violations, err = v.validateEntity(ctx, violations, key, i, entity); err != nil {
+					return err
+				}
+			}
+		case FieldUnstruct:
+			unstruct, err := decodeUnstruct(key, value)
+			if err != nil {
+				return columnError(err, key, index, value)
+			}
+			if violations, err = v.validateEntity(ctx, violations, key, 0, unstruct.Data); err != nil {
+				return err
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+func (v *SchemaValidator) validateEntity(ctx context.Context, violations []Violation, field string, index int, entity SelfDescribingData) ([]Violation, error) {
+	compiled, err := v.schema(ctx, entity.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if compiled == nil {
+		return append(violations, Violation{Field: field, Index: index, Schema: entity.Schema, Message: "schema not found"}), nil
+	}
+	var data any
+	if entity.Data != nil {
+		data = entity.Data
+	}
+	err = compiled.Validate(data)
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		for _, unit := range validationErr.BasicOutput().Errors {
+			if unit.Error == nil {
+				continue
+			}
+			violations = append(violations, Violation{
+				Field:   field,
+				Index:   index,
+				Schema:  entity.Schema,
+				Path:    unit.InstanceLocation,
+				Message: unit.Error.String(),
+			})
+		}
+		return violations, nil
+	}
+	return violations, err
+}
+
+// ToMap validates the self-describing entities of a ParsedEvent, and transforms it to a Go map.
+func (v *SchemaValidator) ToMap(ctx context.Context, event ParsedEvent) (map[string]any, error) {
+	if err := v.Validate(ctx, event); err != nil {
+		return nil, err
+	}
+	return event.ToMap()
+}
+
+// ToMapWithGeo validates the self-describing entities of a ParsedEvent, and transforms it to a Go map with the geo_location field.
+func (v *SchemaValidator) ToMapWithGeo(ctx context.Context, event ParsedEvent) (map[string]any, error) {
+	if err := v.Validate(ctx, event); err != nil {
+		return nil, err
+	}
+	return event.ToMapWithGeo()
+}
+
+// ToJson validates the self-describing entities of a ParsedEvent, and transforms it to a JSON object.
+func (v *SchemaValidator) ToJson(ctx context.Context, event ParsedEvent) ([]byte, error) {
+	if err := v.Validate(ctx, event); err != nil {
+		return nil, err
+	}
+	return event.ToJson()
+}
+
+// ToJsonWithGeo validates the self-describing entities of a ParsedEvent, and transforms it to a JSON object with the geo_location field.
+func (v *SchemaValidator) ToJsonWithGeo(ctx context.Context, event ParsedEvent) ([]byte, error) {
+	if err := v.Validate(ctx, event); err != nil {
+		return nil, err
+	}
+	return event.ToJsonWithGeo()
+}
@@ -0,0 +1,148 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/snowplow/snowplow-golang-analytics-sdk/iglu"
+	"github.com/stretchr/testify/assert"
+)
+
+const selfDescribingSchema = `"$schema":"http://iglu.snowplowanalytics.com/schemas/com.snowplowanalytics.self-desc/schema/jsonschema/1-0-0#"`
+
+var testSchemas = fstest.MapFS{
+	"schemas/com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1": {Data: []byte(`{` + selfDescribingSchema + `,
+		"self":{"vendor":"com.snowplowanalytics.snowplow","name":"link_click","format":"jsonschema","version":"1-0-1"},
+		"type":"object","properties":{"targetUrl":{"type":"string","minLength":1},"elementId":{"type":"string"},
+		"elementClasses":{"type":"array","items":{"type":"string"}}},"required":["targetUrl"]}`)},
+	"schemas/org.schema/WebPage/jsonschema/1-0-0": {Data: []byte(`{` + selfDescribingSchema + `,
+		"type":"object","properties":{"genre":{"type":"string"},"keywords":{"type":"array"}}}`)},
+	"schemas/org.w3/PerformanceTiming/jsonschema/1-0-0": {Data: []byte(`{` + selfDescribingSchema + `,
+		"type":"object","properties":{"navigationStart":{"type":"integer","minimum":0}}}`)},
+	"schemas/com.snowplowanalytics.snowplow/ua_parser_context/jsonschema/1-0-0": {Data: []byte(`{` + selfDescribingSchema + `,
+		"type":"object","properties":{"useragentFamily":{"type":"string"},"useragentPatch":{"type":["string","null"]}},
+		"required":["useragentFamily"],"additionalProperties":true}`)},
+}
+
+func TestSchemaValidatorValidate(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	validator := NewSchemaValidator(testSchemas)
+
+	// valid event
+	assert.Nil(validator.Validate(ctx, fullEvent))
+
+	// invalid unstruct event
+	invalidUnstruct := withField(fullEvent, "unstruct_event", `{"schema":"iglu:com.snowplowanalytics.snowplow/unstruct_event/jsonschema/1-0-0","data":{"schema":"iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1","data":{"targetUrl":"","elementClasses":[1]}}}`)
+	err := validator.Validate(ctx, invalidUnstruct)
+	var validationErr *ValidationError
+	assert.ErrorAs(err, &validationErr)
+	assert.ElementsMatch([]Violation{
+		{Field: "unstruct_event", Index: 0, Schema: "iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1", Path: "/targetUrl", Message: "minLength: got 0, want 1"},
+		{Field: "unstruct_event", Index: 0, Schema: "iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1", Path: "/elementClasses/0", Message: "got number, want string"},
+	}, validationErr.Violations)
+
+	// invalid second context, and missing schema
+	invalidContexts := withField(fullEvent, "contexts", `{"schema":"iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-0","data":[{"schema":"iglu:com.acme/missing/jsonschema/1-0-0","data":{}},{"schema":"iglu:org.w3/PerformanceTiming/jsonschema/1-0-0","data":{"navigationStart":-1}}]}`)
+	err = validator.Validate(ctx, invalidContexts)
+	assert.ErrorAs(err, &validationErr)
+	assert.Equal([]Violation{
+		{Field: "contexts", Index: 0, Schema: "iglu:com.acme/missing/jsonschema/1-0-0", Message: "schema not found"},
+		{Field: "contexts", Index: 1, Schema: "iglu:org.w3/PerformanceTiming/jsonschema/1-0-0", Path: "/navigationStart", Message: "minimum: got -1, want 0"},
+	}, validationErr.Violations)
+
+	// schemas which are not found are not cached, and the cache is cleared once full
+	assert.NotContains(validator.schemas, "iglu:com.acme/missing/jsonschema/1-0-0")
+	fullValidator := NewSchemaValidator(testSchemas)
+	assert.Nil(fullValidator.Validate(ctx, fullEvent))
+	cached := len(fullValidator.schemas)
+	clear(fullValidator.schemas)
+	for i := 0; i < maxCachedSchemas; i++ {
+		fullValidator.schemas[fmt.Sprintf("iglu:com.acme/filler/jsonschema/1-0-%v", i)] = nil
+	}
+	assert.Nil(fullValidator.Validate(ctx, fullEvent))
+	assert.Len(fullValidator.schemas, cached)
+
+	// invalid schema file
+	brokenValidator := NewSchemaValidator(fstest.MapFS{"schemas/org.w3/PerformanceTiming/jsonschema/1-0-0": {Data: []byte(`{"type":`)}})
+	err = brokenValidator.Validate(ctx, fullEvent)
+	assert.NotNil(err)
+	assert.NotErrorAs(err, &validationErr)
+
+	// malformed event
+	assert.NotNil(validator.Validate(ctx, ParsedEvent([]string{"one", "two"})))
+}
+
+func BenchmarkSchemaValidatorValidate(b *testing.B) {
+	ctx := context.Background()
+	validator := NewSchemaValidator(testSchemas)
+	for i := 0; i < b.N; i++ {
+		validator.Validate(ctx, fullEvent)
+	}
+}
+
+func TestSchemaValidatorToJson(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	validator := NewSchemaValidator(testSchemas)
+
+	jsonified, err := validator.ToJsonWithGeo(ctx, fullEvent)
+	assert.Nil(err)
+	assert.JSONEq(string(eventMapWithGeoJSON), string(jsonified))
+
+	mapified, err := validator.ToMap(ctx, fullEvent)
+	assert.Nil(err)
+	assert.Equal(eventMapWithoutGeo, mapified)
+
+	invalid := withField(fullEvent, "derived_contexts", `{"schema":"iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-1","data":[{"schema":"iglu:com.snowplowanalytics.snowplow/ua_parser_context/jsonschema/1-0-0","data":{}}]}`)
+	failedJson, err := validator.ToJson(ctx, invalid)
+	assert.NotNil(err)
+	assert.Nil(failedJson)
+
+	failedMap, err := validator.ToMapWithGeo(ctx, invalid)
+	assert.NotNil(err)
+	assert.Nil(failedMap)
+}
+
+func TestNewResolverValidator(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	server := httptest.NewServer(http.FileServer(http.FS(testSchemas)))
+	defer server.Close()
+
+	validator := NewResolverValidator(iglu.NewResolver([]iglu.Repository{
+		iglu.NewHTTPRepository(iglu.RepositoryConfig{Name: "static"}, server.URL, server.Client()),
+	}))
+	// lookups are bound to the context of the validation
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(validator.Validate(cancelled, fullEvent), context.Canceled)
+	assert.Nil(validator.Validate(ctx, fullEvent))
+
+	server.Close()
+	// schemas are compiled once, and no longer need to be resolved
+	assert.Nil(validator.Validate(ctx, fullEvent))
+	// failing to resolve a schema is an error rather than a violation
+	unresolvable := withField(fullEvent, "contexts", `{"schema":"iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-0","data":[{"schema":"iglu:com.acme/missing/jsonschema/1-0-0","data":{}}]}`)
+	var validationErr *ValidationError
+	err := validator.Validate(ctx, unresolvable)
+	assert.NotNil(err)
+	assert.NotErrorAs(err, &validationErr)
+}
@@ -0,0 +1,280 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+// Package arrow converts enriched events to Apache Arrow record batches, with typed atomic columns and struct or JSON
+// columns for the shredded contexts and unstruct events of selected schemas, for in-process analytics with engines
+// such as DuckDB or Arrow compute.
+package arrow
+
+import (
+	"fmt"
+	"iter"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/analytics"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/internal/ddl"
+)
+
+const defaultBatchSize int = 10000
+
+type shreddedSchema struct {
+	uri      string
+	schema   []byte
+	repeated bool
+}
+
+type config struct {
+	batchSize int
+	layout    *analytics.Layout
+	allocator memory.Allocator
+	shredded  []shreddedSchema
+}
+
+// Option configures a Builder.
+type Option func(*config)
+
+// WithBatchSize sets the number of events of each record batch, 10000 by default.
+func WithBatchSize(rows int) Option {
+	return func(c *config) {
+		c.batchSize = rows
+	}
+}
+
+// WithLayout sets the layout whose atomic fields are built as columns, analytics.LayoutCurrent by default.
+// Events of layouts lacking some of these fields are built with null values for them.
+func WithLayout(layout *analytics.Layout) Option {
+	return func(c *config) {
+		c.layout = layout
+	}
+}
+
+// WithAllocator sets the allocator of the record batches, memory.DefaultAllocator by default.
+func WithAllocator(allocator memory.Allocator) Option {
+	return func(c *config) {
+		c.allocator = allocator
+	}
+}
+
+// WithContextSchema adds a column for the contexts and derived contexts of the schema's model, named as by
+// analytics.ContextsKey, such as contexts_com_acme_my_context_1. The column is a list of structs with the properties
+// of the JSON Schema, which should be the latest schema of the model. As in ToMap, a context found in both contexts
+// and derived_contexts takes its value from derived_contexts.
+func WithContextSchema(uri string, schema []byte) Option {
+	return func(c *config) {
+		c.shredded = append(c.shredded, shreddedSchema{uri: uri, schema: schema, repeated: true})
+	}
+}
+
+// WithUnstructEventSchema adds a column for the unstruct events of the schema's model, named as by
+// analytics.UnstructEventKey, such as unstruct_event_com_acme_my_event_1. The column is a struct with the properties
+// of the JSON Schema, which should be the latest schema of the model.
+func WithUnstructEventSchema(uri string, schema []byte) Option {
+	return func(c *config) {
+		c.shredded = append(c.shredded, shreddedSchema{uri: uri, schema: schema})
+	}
+}
+
+// WithContextJSON adds a column for the contexts and derived contexts of the schema's model, holding the list of
+// their data as JSON text. It suits schemas whose JSON Schema is not available, or whose data is queried with JSON functions.
+func WithContextJSON(uri string) Option {
+	return func(c *config) {
+		c.shredded = append(c.shredded, shreddedSchema{uri: uri, repeated: true})
+	}
+}
+
+// WithUnstructEventJSON adds a column for the unstruct events of the schema's model, holding their data as JSON text.
+func WithUnstructEventJSON(uri string) Option {
+	return func(c *config) {
+		c.shredded = append(c.shredded, shreddedSchema{uri: uri})
+	}
+}
+
+// Builder builds record batches of enriched events. A Builder is not safe for concurrent use.
+type Builder struct {
+	schema    *arrow.Schema
+	columns   []column
+	batchSize int
+	builder   *array.RecordBuilder
+	rows      int
+}
+
+// NewBuilder returns a Builder for the provided options. Release must be called once it is no longer used.
+func NewBuilder(opts ...Option) (*Builder, error) {
+	c := config{batchSize: defaultBatchSize, layout: analytics.LayoutCurrent, allocator: memory.DefaultAllocator}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.batchSize <= 0 {
+		return nil, fmt.Errorf("cannot create record builder: batch size must be positive, got %v", c.batchSize)
+	}
+
+	columns, err := buildColumns(c)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create record builder: %w", err)
+	}
+	fields := make([]arrow.Field, len(columns))
+	for i, col := range columns {
+		fields[i] = arrow.Field{Name: col.name, Type: col.dataType(), Nullable: true}
+	}
+	schema := arrow.NewSchema(fields, nil)
+	return &Builder{
+		schema:    schema,
+		columns:   columns,
+		batchSize: c.batchSize,
+		builder:   array.NewRecordBuilder(c.allocator, schema),
+	}, nil
+}
+
+// buildColumns returns the atomic columns of the layout, other than contexts and unstruct events, followed by the
+// shredded columns.
+func buildColumns(c config) ([]column, error) {
+	var columns []column
+	names := make(map[string]bool)
+	for _, atomic := range c.layout.Columns() {
+		if atomic.Type == analytics.FieldContexts || atomic.Type == analytics.FieldUnstruct {
+			continue
+		}
+		columns = append(columns, column{name: atomic.Name, fieldType: atomic.Type})
+		names[atomic.Name] = true
+	}
+	for _, shredded := range c.shredded {
+		keyOf := analytics.UnstructEventKey
+		if shredded.repeated {
+			keyOf = analytics.ContextsKey
+		}
+		name, err := keyOf(shredded.uri)
+		if err != nil {
+			return nil, err
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate column %s for schema %s", name, shredded.uri)
+		}
+		names[name] = true
+		if shredded.schema == nil {
+			columns = append(columns, column{name: name, entity: &ddl.Type{Kind: ddl.JSON}})
+			continue
+		}
+		entity, err := ddl.FromJSONSchema(shredded.schema)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert schema %s: %w", shredded.uri, err)
+		}
+		columns = append(columns, column{name: name, entity: entity, repeated: shredded.repeated})
+	}
+	return columns, nil
+}
+
+// Schema returns the schema of the record batches.
+func (b *Builder) Schema() *arrow.Schema {
+	return b.schema
+}
+
+// Len returns the number of events of the current batch.
+func (b *Builder) Len() int {
+	return b.rows
+}
+
+// Append converts an event and appends it to the current batch. Once the batch holds the configured number of events,
+// it is returned and a new batch is started; otherwise Append returns nil. The caller must release returned batches.
+// If the event cannot be converted, the error is returned and the event is not appended.
+func (b *Builder) Append(event analytics.ParsedEvent) (arrow.RecordBatch, error) {
+	mapped, err := event.ToMap()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(b.columns))
+	for i, col := range b.columns {
+		if values[i], err = col.value(mapped); err != nil {
+			return nil, err
+		}
+	}
+	for i, value := range values {
+		appendValue(b.builder.Field(i), value)
+	}
+	b.rows++
+	if b.rows >= b.batchSize {
+		return b.Flush(), nil
+	}
+	return nil, nil
+}
+
+// Flush returns the current batch and starts a new one, or returns nil if the batch is empty. The caller must release returned batches.
+func (b *Builder) Flush() arrow.RecordBatch {
+	if b.rows == 0 {
+		return nil
+	}
+	b.rows = 0
+	return b.builder.NewRecordBatch()
+}
+
+// Release releases the memory of the events appended since the last batch was returned.
+func (b *Builder) Release() {
+	b.builder.Release()
+}
+
+// Records converts a stream of events, such as the events of an analytics.Reader, to record batches of the configured
+// size, the last batch holding the remaining events. Iteration stops at the first error, either of the stream or of
+// the conversion of an event. The caller must release the yielded batches.
+func Records(events iter.Seq2[analytics.ParsedEvent, error], opts ...Option) iter.Seq2[arrow.RecordBatch, error] {
+	return func(yield func(arrow.RecordBatch, error) bool) {
+		builder, err := NewBuilder(opts...)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer builder.Release()
+		for event, err := range events {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			record, err := builder.Append(event)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if record != nil && !yield(record, nil) {
+				return
+			}
+		}
+		if record := builder.Flush(); record != nil {
+			yield(record, nil)
+		}
+	}
+}
+
+// FromEvents converts a slice of events to record batches of the configured size. The caller must release the batches.
+func FromEvents(events []analytics.ParsedEvent, opts ...Option) ([]arrow.RecordBatch, error) {
+	var records []arrow.RecordBatch
+	for record, err := range Records(sliceEvents(events), opts...) {
+		if err != nil {
+			for _, r := range records {
+				r.Release()
+			}
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func sliceEvents(events []analytics.ParsedEvent) iter.Seq2[analytics.ParsedEvent, error] {
+	return func(yield func(analytics.ParsedEvent, error) bool) {
+		for _, event := range events {
+			if !yield(event, nil) {
+				return
+			}
+		}
+	}
+}
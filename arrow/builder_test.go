@@ -0,0 +1,231 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package arrow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/analytics"
+	"github.com/stretchr/testify/assert"
+)
+
+var collectorTstamp = time.Date(2013, 11, 26, 0, 3, 57, 885000000, time.UTC)
+
+var linkClickSchema = []byte(`{"type":"object","properties":{"targetUrl":{"type":"string"},"elementClasses":{"type":["array","null"],"items":{"type":"string"}}},"required":["targetUrl"]}`)
+
+var webPageSchema = []byte(`{"type":"object","properties":{"id":{"type":"string"},"position":{"type":"integer"}},"required":["id"]}`)
+
+var linkClickEvent = `{"schema":"iglu:com.snowplowanalytics.snowplow/unstruct_event/jsonschema/1-0-0","data":{"schema":"iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1","data":{"targetUrl":"http://www.example.com","elementClasses":["foreground"]}}}`
+
+var webPageContexts = `{"schema":"iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-0","data":[{"schema":"iglu:com.snowplowanalytics.snowplow/web_page/jsonschema/1-0-0","data":{"id":"first","position":1}},{"schema":"iglu:com.snowplowanalytics.snowplow/web_page/jsonschema/1-0-0","data":{"id":"second"}},{"schema":"iglu:com.acme/ignored/jsonschema/1-0-0","data":{}}]}`
+
+func newTestEvent(t testing.TB, fields map[string]any) analytics.ParsedEvent {
+	base := map[string]any{
+		"app_id":           "angry-birds",
+		"collector_tstamp": collectorTstamp,
+		"event_id":         "c6ef3124-b53a-4b13-a233-0088f79dcbcb",
+		"v_collector":      "clj-tomcat-0.1.0",
+		"v_etl":            "serde-0.5.2",
+		"txn_id":           41828,
+		"geo_latitude":     37.443604,
+		"br_cookies":       true,
+	}
+	for key, value := range fields {
+		base[key] = value
+	}
+	event, err := analytics.FromMap(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func columnOf(record arrow.RecordBatch, name string) arrow.Array {
+	return record.Column(record.Schema().FieldIndices(name)[0])
+}
+
+func TestNewBuilder(t *testing.T) {
+	assert := assert.New(t)
+
+	builder, err := NewBuilder(
+		WithContextSchema("iglu:com.snowplowanalytics.snowplow/web_page/jsonschema/1-0-0", webPageSchema),
+		WithUnstructEventJSON("iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1"),
+	)
+	assert.Nil(err)
+	defer builder.Release()
+	schema := builder.Schema()
+	assert.Equal(analytics.LayoutCurrent.Len()-3+2, schema.NumFields())
+	assert.False(schema.HasField("contexts"))
+	assert.False(schema.HasField("derived_contexts"))
+	assert.False(schema.HasField("unstruct_event"))
+	field, _ := schema.FieldsByName("collector_tstamp")
+	assert.Equal(timestampType, field[0].Type)
+	field, _ = schema.FieldsByName("unstruct_event_com_snowplowanalytics_snowplow_link_click_1")
+	assert.Equal(arrow.BinaryTypes.String, field[0].Type)
+	field, _ = schema.FieldsByName("contexts_com_snowplowanalytics_snowplow_web_page_1")
+	assert.Equal(arrow.LIST, field[0].Type.ID())
+
+	older, err := NewBuilder(WithLayout(analytics.LayoutNoDerivedFields))
+	assert.Nil(err)
+	defer older.Release()
+	assert.Equal(analytics.LayoutNoDerivedFields.Len()-2, older.Schema().NumFields())
+
+	for _, opts := range [][]Option{
+		{WithBatchSize(0)},
+		{WithContextSchema("not a schema", webPageSchema)},
+		{WithContextSchema("iglu:com.acme/context/jsonschema/1-0-0", []byte(`{"type":`))},
+		{WithContextSchema("iglu:com.acme/context/jsonschema/1-0-0", webPageSchema), WithContextJSON("iglu:com.acme/context/jsonschema/1-0-1")},
+	} {
+		failed, err := NewBuilder(opts...)
+		assert.NotNil(err)
+		assert.Nil(failed)
+	}
+}
+
+func TestBuilderAppend(t *testing.T) {
+	assert := assert.New(t)
+
+	allocator := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer allocator.AssertSize(t, 0)
+	builder, err := NewBuilder(
+		WithBatchSize(2),
+		WithAllocator(allocator),
+		WithContextJSON("iglu:com.snowplowanalytics.snowplow/web_page/jsonschema/1-0-0"),
+		WithUnstructEventSchema("iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1", linkClickSchema),
+	)
+	assert.Nil(err)
+	defer builder.Release()
+
+	record, err := builder.Append(newTestEvent(t, map[string]any{"unstruct_event": linkClickEvent, "contexts": webPageContexts}))
+	assert.Nil(err)
+	assert.Nil(record)
+	// events failing to convert are not appended
+	invalid := newTestEvent(t, map[string]any{"unstruct_event": strings.Replace(linkClickEvent, `"http://www.example.com"`, "1", 1)})
+	record, err = builder.Append(invalid)
+	assert.NotNil(err)
+	assert.Nil(record)
+	assert.Equal(1, builder.Len())
+
+	record, err = builder.Append(newTestEvent(t, map[string]any{"app_id": ""}))
+	assert.Nil(err)
+	assert.NotNil(record)
+	defer record.Release()
+	assert.Equal(0, builder.Len())
+	assert.Equal(int64(2), record.NumRows())
+
+	appID := columnOf(record, "app_id").(*array.String)
+	assert.Equal("angry-birds", appID.Value(0))
+	assert.True(appID.IsNull(1))
+	assert.Equal(int64(41828), columnOf(record, "txn_id").(*array.Int64).Value(0))
+	assert.Equal(37.443604, columnOf(record, "geo_latitude").(*array.Float64).Value(0))
+	assert.True(columnOf(record, "br_cookies").(*array.Boolean).Value(0))
+	assert.True(columnOf(record, "true_tstamp").IsNull(0))
+	assert.Equal(collectorTstamp, columnOf(record, "collector_tstamp").(*array.Timestamp).Value(0).ToTime(arrow.Microsecond))
+
+	contexts := columnOf(record, "contexts_com_snowplowanalytics_snowplow_web_page_1").(*array.String)
+	assert.JSONEq(`[{"id":"first","position":1},{"id":"second"}]`, contexts.Value(0))
+	assert.True(contexts.IsNull(1))
+	unstructJson, err := columnOf(record, "unstruct_event_com_snowplowanalytics_snowplow_link_click_1").(*array.Struct).MarshalJSON()
+	assert.Nil(err)
+	assert.JSONEq(`[{"elementClasses":["foreground"],"targetUrl":"http://www.example.com"},null]`, string(unstructJson))
+
+	assert.Nil(builder.Flush())
+}
+
+func TestFromEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	events := []analytics.ParsedEvent{newTestEvent(t, nil), newTestEvent(t, nil), newTestEvent(t, nil)}
+	records, err := FromEvents(events, WithBatchSize(2))
+	assert.Nil(err)
+	assert.Len(records, 2)
+	assert.Equal(int64(2), records[0].NumRows())
+	assert.Equal(int64(1), records[1].NumRows())
+	for _, record := range records {
+		record.Release()
+	}
+
+	records, err = FromEvents(append(events, analytics.ParsedEvent{"one", "two"}), WithBatchSize(2))
+	assert.NotNil(err)
+	assert.Nil(records)
+
+	records, err = FromEvents(nil)
+	assert.Nil(err)
+	assert.Empty(records)
+}
+
+func TestRecords(t *testing.T) {
+	assert := assert.New(t)
+
+	var lines []string
+	for i := 0; i < 5; i++ {
+		line, err := newTestEvent(t, map[string]any{"txn_id": i}).ToTSV()
+		assert.Nil(err)
+		lines = append(lines, line)
+	}
+	reader := analytics.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+
+	var rows []int64
+	for record, err := range Records(reader.All(), WithBatchSize(2)) {
+		assert.Nil(err)
+		txnIDs := columnOf(record, "txn_id").(*array.Int64)
+		rows = append(rows, txnIDs.Int64Values()...)
+		record.Release()
+	}
+	assert.Equal([]int64{0, 1, 2, 3, 4}, rows)
+
+	failure := errors.New("failure")
+	failing := func(yield func(analytics.ParsedEvent, error) bool) {
+		if yield(newTestEvent(t, nil), nil) {
+			yield(nil, failure)
+		}
+	}
+	var errs []error
+	for record, err := range Records(failing) {
+		assert.Nil(record)
+		errs = append(errs, err)
+	}
+	assert.Equal([]error{failure}, errs)
+
+	// iteration may stop early
+	batches := 0
+	reader = analytics.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+	for record := range Records(reader.All(), WithBatchSize(1)) {
+		record.Release()
+		batches++
+		break
+	}
+	assert.Equal(1, batches)
+}
+
+func BenchmarkBuilderAppend(b *testing.B) {
+	builder, _ := NewBuilder(
+		WithContextSchema("iglu:com.snowplowanalytics.snowplow/web_page/jsonschema/1-0-0", webPageSchema),
+		WithUnstructEventSchema("iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1", linkClickSchema),
+	)
+	defer builder.Release()
+	event := newTestEvent(b, map[string]any{"unstruct_event": linkClickEvent, "contexts": webPageContexts})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if record, _ := builder.Append(event); record != nil {
+			record.Release()
+		}
+	}
+}
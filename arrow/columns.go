@@ -0,0 +1,150 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package arrow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/analytics"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/internal/ddl"
+)
+
+// timestampType stores timestamps as microseconds in UTC, written to Parquet files as TIMESTAMP_MICROS.
+var timestampType = &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+
+// column is a column of the output, either an atomic field or the shredded data of a schema.
+type column struct {
+	name      string
+	fieldType analytics.FieldType
+	// entity holds the type of the data of shredded columns, and is nil for atomic columns.
+	entity *ddl.Type
+	// repeated is true for shredded contexts, which hold a list of entities.
+	repeated bool
+}
+
+// dataType returns the arrow type of the column's values.
+func (c column) dataType() arrow.DataType {
+	if c.entity != nil {
+		if c.repeated {
+			return arrow.ListOf(arrowType(c.entity))
+		}
+		return arrowType(c.entity)
+	}
+	switch c.fieldType {
+	case analytics.FieldString:
+		return arrow.BinaryTypes.String
+	case analytics.FieldInt:
+		return arrow.PrimitiveTypes.Int64
+	case analytics.FieldDouble:
+		return arrow.PrimitiveTypes.Float64
+	case analytics.FieldBool:
+		return arrow.FixedWidthTypes.Boolean
+	case analytics.FieldTime:
+		return timestampType
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+// value converts the value of the column from the map of an event to the representation expected by appendValue.
+func (c column) value(mapped map[string]any) (any, error) {
+	value, ok := mapped[c.name]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	if c.entity != nil {
+		entityType := c.entity
+		if c.repeated {
+			entityType = &ddl.Type{Kind: ddl.Array, Items: c.entity}
+		}
+		normalized, err := ddl.Normalize(entityType, value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert column %s: %w", c.name, err)
+		}
+		return normalized, nil
+	}
+	switch c.fieldType {
+	case analytics.FieldInt:
+		if intValue, ok := value.(int); ok {
+			return int64(intValue), nil
+		}
+	case analytics.FieldString, analytics.FieldDouble, analytics.FieldBool, analytics.FieldTime:
+		return value, nil
+	default:
+		normalized, err := ddl.Normalize(&ddl.Type{Kind: ddl.JSON}, value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert column %s: %w", c.name, err)
+		}
+		return normalized, nil
+	}
+	return nil, fmt.Errorf("cannot convert column %s: unexpected value %v", c.name, value)
+}
+
+// arrowType returns the arrow type of values of a ddl type. JSON values are stored as strings.
+func arrowType(t *ddl.Type) arrow.DataType {
+	switch t.Kind {
+	case ddl.String, ddl.JSON:
+		return arrow.BinaryTypes.String
+	case ddl.Integer:
+		return arrow.PrimitiveTypes.Int64
+	case ddl.Number:
+		return arrow.PrimitiveTypes.Float64
+	case ddl.Boolean:
+		return arrow.FixedWidthTypes.Boolean
+	case ddl.Array:
+		return arrow.ListOf(arrowType(t.Items))
+	default:
+		fields := make([]arrow.Field, len(t.Fields))
+		for i, field := range t.Fields {
+			fields[i] = arrow.Field{Name: field.Name, Type: arrowType(field.Type), Nullable: field.Nullable}
+		}
+		return arrow.StructOf(fields...)
+	}
+}
+
+// appendValue appends a value converted by column.value or ddl.Normalize to a builder of its arrow type.
+func appendValue(builder array.Builder, value any) {
+	if value == nil {
+		builder.AppendNull()
+		return
+	}
+	switch b := builder.(type) {
+	case *array.StringBuilder:
+		b.Append(value.(string))
+	case *array.Int64Builder:
+		b.Append(value.(int64))
+	case *array.Float64Builder:
+		b.Append(value.(float64))
+	case *array.BooleanBuilder:
+		b.Append(value.(bool))
+	case *array.TimestampBuilder:
+		b.Append(arrow.Timestamp(value.(time.Time).UnixMicro()))
+	case *array.ListBuilder:
+		b.Append(true)
+		for _, item := range value.([]any) {
+			appendValue(b.ValueBuilder(), item)
+		}
+	case *array.StructBuilder:
+		b.Append(true)
+		fields := value.(map[string]any)
+		for i, field := range b.Type().(*arrow.StructType).Fields() {
+			appendValue(b.FieldBuilder(i), fields[field.Name])
+		}
+	default:
+		panic(fmt.Sprintf("unexpected builder %T", builder))
+	}
+}
@@ -0,0 +1,61 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package arrow
+
+import (
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/analytics"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/internal/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnDataType(t *testing.T) {
+	assert := assert.New(t)
+
+	entity, err := ddl.FromJSONSchema(linkClickSchema)
+	assert.Nil(err)
+	entityType := arrow.StructOf(
+		arrow.Field{Name: "elementClasses", Type: arrow.ListOf(arrow.BinaryTypes.String), Nullable: true},
+		arrow.Field{Name: "targetUrl", Type: arrow.BinaryTypes.String},
+	)
+	assert.True(arrow.TypeEqual(entityType, column{entity: entity}.dataType()))
+	assert.True(arrow.TypeEqual(arrow.ListOf(entityType), column{entity: entity, repeated: true}.dataType()))
+
+	assert.Equal(timestampType, column{fieldType: analytics.FieldTime}.dataType())
+	assert.Equal(arrow.PrimitiveTypes.Int64, column{fieldType: analytics.FieldInt}.dataType())
+	assert.Equal(arrow.BinaryTypes.String, column{fieldType: analytics.FieldCustom}.dataType())
+}
+
+func TestColumnValue(t *testing.T) {
+	assert := assert.New(t)
+
+	intValue, err := column{name: "txn_id", fieldType: analytics.FieldInt}.value(map[string]any{"txn_id": 3})
+	assert.Nil(err)
+	assert.Equal(int64(3), intValue)
+
+	missing, err := column{name: "txn_id", fieldType: analytics.FieldInt}.value(map[string]any{})
+	assert.Nil(err)
+	assert.Nil(missing)
+
+	custom, err := column{name: "custom", fieldType: analytics.FieldCustom}.value(map[string]any{"custom": []any{1.0}})
+	assert.Nil(err)
+	assert.Equal("[1]", custom)
+
+	entity, _ := ddl.FromJSONSchema(linkClickSchema)
+	invalid, err := column{name: "unstruct", entity: entity}.value(map[string]any{"unstruct": map[string]any{"targetUrl": 1.0}})
+	assert.NotNil(err)
+	assert.Nil(invalid)
+}
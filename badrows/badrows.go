@@ -0,0 +1,587 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+// Package badrows parses Snowplow bad rows, the self-describing JSON documents emitted by the pipeline for events
+// which failed collection, enrichment or loading.
+package badrows
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/analytics"
+)
+
+// SchemaPrefix is the prefix of the schema URI of every bad row.
+const SchemaPrefix string = "iglu:com.snowplowanalytics.snowplow.badrows/"
+
+var json = jsoniter.Config{}.Froze()
+
+// BadRow is a parsed bad row of any type. Use a type switch to access the fields specific to each type.
+type BadRow interface {
+	// Schema returns the schema URI of the bad row.
+	Schema() string
+	// ProcessorInfo returns the application which emitted the bad row.
+	ProcessorInfo() Processor
+	// FailureMessages returns a description of each failure reported by the bad row.
+	FailureMessages() []string
+	// RawPayload returns the original payload carried by the bad row, as JSON.
+	RawPayload() []byte
+
+	header() *Header
+}
+
+// Header holds the fields common to all bad rows.
+type Header struct {
+	Processor Processor `json:"processor"`
+
+	schema     string
+	rawPayload []byte
+}
+
+// Schema returns the schema URI of the bad row.
+func (h *Header) Schema() string {
+	return h.schema
+}
+
+// ProcessorInfo returns the application which emitted the bad row.
+func (h *Header) ProcessorInfo() Processor {
+	return h.Processor
+}
+
+// RawPayload returns the original payload carried by the bad row, as JSON.
+func (h *Header) RawPayload() []byte {
+	return h.rawPayload
+}
+
+func (h *Header) header() *Header {
+	return h
+}
+
+// Processor identifies the application which emitted a bad row.
+type Processor struct {
+	Artifact string `json:"artifact"`
+	Version  string `json:"version"`
+}
+
+var badRowTypes = map[string]func() BadRow{
+	"adapter_failures":                   func() BadRow { return &AdapterFailures{} },
+	"tracker_protocol_violations":        func() BadRow { return &TrackerProtocolViolations{} },
+	"schema_violations":                  func() BadRow { return &SchemaViolations{} },
+	"enrichment_failures":                func() BadRow { return &EnrichmentFailures{} },
+	"size_violation":                     func() BadRow { return &SizeViolation{} },
+	"collector_payload_format_violation": func() BadRow { return &CollectorPayloadFormatViolation{} },
+	"loader_parsing_error":               func() BadRow { return &LoaderParsingError{} },
+	"loader_iglu_error":                  func() BadRow { return &LoaderIgluError{} },
+	"loader_recovery_error":              func() BadRow { return &LoaderRecoveryError{} },
+	"loader_runtime_error":               func() BadRow { return &LoaderRuntimeError{} },
+	"relay_failure":                      func() BadRow { return &RelayFailure{} },
+	"generic_error":                      func() BadRow { return &GenericError{} },
+	"recovery_error":                     func() BadRow { return &RecoveryError{} },
+}
+
+// ParseBadRow parses a bad row, dispatching on its schema to the matching type.
+func ParseBadRow(data []byte) (BadRow, error) {
+	envelope := struct {
+		Schema string              `json:"schema"`
+		Data   jsoniter.RawMessage `json:"data"`
+	}{}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("error unmarshaling bad row JSON: %w", err)
+	}
+	name, err := badRowName(envelope.Schema)
+	if err != nil {
+		return nil, err
+	}
+	newBadRow, ok := badRowTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown bad row type '%s'", name)
+	}
+
+	row := newBadRow()
+	if err := json.Unmarshal(envelope.Data, row); err != nil {
+		return nil, fmt.Errorf("error unmarshaling %s bad row: %w", name, err)
+	}
+	payload := struct {
+		Payload jsoniter.RawMessage `json:"payload"`
+	}{}
+	if err := json.Unmarshal(envelope.Data, &payload); err != nil {
+		return nil, fmt.Errorf("error unmarshaling %s bad row payload: %w", name, err)
+	}
+	h := row.header()
+	h.schema = envelope.Schema
+	h.rawPayload = payload.Payload
+	return row, nil
+}
+
+// badRowName returns the bad row type named by a schema URI, such as schema_violations.
+func badRowName(schema string) (string, error) {
+	if !strings.HasPrefix(schema, SchemaPrefix) {
+		return "", fmt.Errorf("schema '%s' is not a bad row schema", schema)
+	}
+	rest := schema[len(SchemaPrefix):]
+	end := strings.IndexByte(rest, '/')
+	if end <= 0 {
+		return "", fmt.Errorf("schema '%s' is not a bad row schema", schema)
+	}
+	return rest[:end], nil
+}
+
+// NameValue is a name and optional value pair, such as a querystring parameter.
+type NameValue struct {
+	Name  string  `json:"name"`
+	Value *string `json:"value"`
+}
+
+// CollectorPayload is a request as received by the collector.
+type CollectorPayload struct {
+	Vendor        string      `json:"vendor"`
+	Version       string      `json:"version"`
+	Querystring   []NameValue `json:"querystring"`
+	ContentType   *string     `json:"contentType"`
+	Body          *string     `json:"body"`
+	Collector     string      `json:"collector"`
+	Encoding      string      `json:"encoding"`
+	Hostname      *string     `json:"hostname"`
+	Timestamp     *time.Time  `json:"timestamp"`
+	IPAddress     *string     `json:"ipAddress"`
+	Useragent     *string     `json:"useragent"`
+	RefererURI    *string     `json:"refererUri"`
+	Headers       []string    `json:"headers"`
+	NetworkUserID *string     `json:"networkUserId"`
+}
+
+// RawEvent is a single event extracted from a collector payload, before enrichment.
+type RawEvent struct {
+	Vendor      string      `json:"vendor"`
+	Version     string      `json:"version"`
+	Parameters  []NameValue `json:"parameters"`
+	ContentType *string     `json:"contentType"`
+	LoaderName  string      `json:"loaderName"`
+	Encoding    string      `json:"encoding"`
+	Hostname    *string     `json:"hostname"`
+	Timestamp   *time.Time  `json:"timestamp"`
+	IPAddress   *string     `json:"ipAddress"`
+	Useragent   *string     `json:"useragent"`
+	RefererURI  *string     `json:"refererUri"`
+	Headers     []string    `json:"headers"`
+	UserID      *string     `json:"userId"`
+}
+
+// ParameterMap returns the parameters of the event, such as e, aid or ue_px, keyed by name.
+func (e RawEvent) ParameterMap() map[string]string {
+	return nameValueMap(e.Parameters)
+}
+
+// QuerystringMap returns the querystring parameters of the request keyed by name.
+func (p CollectorPayload) QuerystringMap() map[string]string {
+	return nameValueMap(p.Querystring)
+}
+
+func nameValueMap(pairs []NameValue) map[string]string {
+	output := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		if pair.Value != nil {
+			output[pair.Name] = *pair.Value
+		} else {
+			output[pair.Name] = ""
+		}
+	}
+	return output
+}
+
+// EnrichmentPayload is the payload of bad rows emitted during enrichment: the event as enriched up to the failure,
+// and the raw event it was enriched from.
+type EnrichmentPayload struct {
+	Enriched map[string]any `json:"enriched"`
+	Raw      RawEvent       `json:"raw"`
+}
+
+// ClientError is an error reported by an Iglu client, either while resolving a schema or validating data against it.
+type ClientError struct {
+	Error         string            `json:"error"`
+	DataReports   []ValidatorReport `json:"dataReports,omitempty"`
+	SchemaIssues  []SchemaIssue     `json:"schemaIssues,omitempty"`
+	LookupHistory []LookupHistory   `json:"lookupHistory,omitempty"`
+}
+
+// ValidatorReport describes a single reason for data failing validation against its schema.
+type ValidatorReport struct {
+	Message string   `json:"message"`
+	Path    *string  `json:"path"`
+	Keyword *string  `json:"keyword"`
+	Targets []string `json:"targets"`
+}
+
+// SchemaIssue describes a problem with a schema itself.
+type SchemaIssue struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// LookupHistory describes the attempts made to resolve a schema from a repository.
+type LookupHistory struct {
+	Repository  string           `json:"repository"`
+	Errors      []map[string]any `json:"errors"`
+	Attempts    int              `json:"attempts"`
+	LastAttempt *time.Time       `json:"lastAttempt"`
+}
+
+func (e ClientError) messages() []string {
+	var output []string
+	for _, report := range e.DataReports {
+		output = append(output, report.Message)
+	}
+	for _, issue := range e.SchemaIssues {
+		output = append(output, fmt.Sprintf("%s: %s", issue.Path, issue.Message))
+	}
+	for _, lookup := range e.LookupHistory {
+		for _, lookupErr := range lookup.Errors {
+			output = append(output, fmt.Sprintf("%s: lookup failed in repository %s: %v", e.Error, lookup.Repository, describe(lookupErr)))
+		}
+	}
+	if len(output) == 0 {
+		output = append(output, e.Error)
+	}
+	return output
+}
+
+// InputFailure describes a field of the input which could not be processed.
+// Depending on the failure, either Expectation or Error describes the problem.
+type InputFailure struct {
+	Field       string `json:"field"`
+	Value       any    `json:"value"`
+	Expectation string `json:"expectation"`
+	Error       any    `json:"error"`
+	SchemaKey   string `json:"schemaKey"`
+}
+
+func (f InputFailure) message() string {
+	var b strings.Builder
+	if f.Field != "" {
+		b.WriteString(f.Field)
+	} else if f.SchemaKey != "" {
+		b.WriteString(f.SchemaKey)
+	}
+	if b.Len() > 0 {
+		b.WriteString(": ")
+	}
+	if f.Error != nil {
+		b.WriteString(describe(f.Error))
+	} else {
+		b.WriteString(f.Expectation)
+	}
+	if f.Value != nil {
+		fmt.Fprintf(&b, " (value: %v)", describe(f.Value))
+	}
+	return b.String()
+}
+
+// describe renders a polymorphic failure field, which may be a string or an arbitrary JSON value.
+func describe(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	if m, ok := value.(map[string]any); ok {
+		if message, ok := m["message"].(string); ok {
+			return message
+		}
+	}
+	out, err := json.MarshalToString(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return out
+}
+
+// AdapterFailures is emitted when a collector payload cannot be converted to raw events by the adapter for its vendor.
+type AdapterFailures struct {
+	Header
+	Failure struct {
+		Timestamp time.Time      `json:"timestamp"`
+		Vendor    string         `json:"vendor"`
+		Version   string         `json:"version"`
+		Messages  []InputFailure `json:"messages"`
+	} `json:"failure"`
+	Payload CollectorPayload `json:"payload"`
+}
+
+func (r *AdapterFailures) FailureMessages() []string {
+	return inputFailureMessages(r.Failure.Messages)
+}
+
+// TrackerProtocolViolations is emitted when a collector payload does not follow the Snowplow tracker protocol.
+type TrackerProtocolViolations struct {
+	Header
+	Failure struct {
+		Timestamp time.Time      `json:"timestamp"`
+		Vendor    string         `json:"vendor"`
+		Version   string         `json:"version"`
+		Messages  []InputFailure `json:"messages"`
+	} `json:"failure"`
+	Payload CollectorPayload `json:"payload"`
+}
+
+func (r *TrackerProtocolViolations) FailureMessages() []string {
+	return inputFailureMessages(r.Failure.Messages)
+}
+
+func inputFailureMessages(failures []InputFailure) []string {
+	output := make([]string, 0, len(failures))
+	for _, failure := range failures {
+		output = append(output, failure.message())
+	}
+	return output
+}
+
+// SchemaViolation is a self-describing entity of an event which could not be validated against its schema.
+type SchemaViolation struct {
+	SchemaKey string      `json:"schemaKey"`
+	Error     ClientError `json:"error"`
+}
+
+// SchemaViolations is emitted when the contexts or unstruct event of an event fail validation.
+type SchemaViolations struct {
+	Header
+	Failure struct {
+		Timestamp time.Time         `json:"timestamp"`
+		Messages  []SchemaViolation `json:"messages"`
+	} `json:"failure"`
+	Payload EnrichmentPayload `json:"payload"`
+}
+
+func (r *SchemaViolations) FailureMessages() []string {
+	var output []string
+	for _, violation := range r.Failure.Messages {
+		for _, message := range violation.Error.messages() {
+			output = append(output, fmt.Sprintf("%s: %s", violation.SchemaKey, message))
+		}
+	}
+	return output
+}
+
+// Enrichment identifies an enrichment.
+type Enrichment struct {
+	SchemaKey  string `json:"schemaKey"`
+	Identifier string `json:"identifier"`
+}
+
+// EnrichmentFailure is a failure of a single enrichment. Enrichment is nil for failures outside of a configurable enrichment.
+type EnrichmentFailure struct {
+	Enrichment *Enrichment  `json:"enrichment"`
+	Message    InputFailure `json:"message"`
+}
+
+// EnrichmentFailures is emitted when one or more enrichments fail for an event.
+type EnrichmentFailures struct {
+	Header
+	Failure struct {
+		Timestamp time.Time           `json:"timestamp"`
+		Messages  []EnrichmentFailure `json:"messages"`
+	} `json:"failure"`
+	Payload EnrichmentPayload `json:"payload"`
+}
+
+func (r *EnrichmentFailures) FailureMessages() []string {
+	output := make([]string, 0, len(r.Failure.Messages))
+	for _, failure := range r.Failure.Messages {
+		message := failure.Message.message()
+		if failure.Enrichment != nil {
+			message = failure.Enrichment.Identifier + ": " + message
+		}
+		output = append(output, message)
+	}
+	return output
+}
+
+// SizeViolation is emitted when a payload exceeds the maximum size allowed by a component of the pipeline.
+type SizeViolation struct {
+	Header
+	Failure struct {
+		Timestamp               time.Time `json:"timestamp"`
+		MaximumAllowedSizeBytes int       `json:"maximumAllowedSizeBytes"`
+		ActualSizeBytes         int       `json:"actualSizeBytes"`
+		Expectation             string    `json:"expectation"`
+	} `json:"failure"`
+	Payload struct {
+		// Event holds the beginning of the oversized payload.
+		Event string `json:"event"`
+	} `json:"payload"`
+}
+
+func (r *SizeViolation) FailureMessages() []string {
+	return []string{fmt.Sprintf("%s (size %d bytes, maximum %d bytes)", r.Failure.Expectation, r.Failure.ActualSizeBytes, r.Failure.MaximumAllowedSizeBytes)}
+}
+
+// CollectorPayloadFormatViolation is emitted when a collector payload cannot be deserialized.
+type CollectorPayloadFormatViolation struct {
+	Header
+	Failure struct {
+		Timestamp time.Time    `json:"timestamp"`
+		Loader    string       `json:"loader"`
+		Message   InputFailure `json:"message"`
+	} `json:"failure"`
+	Payload struct {
+		Line string `json:"line"`
+	} `json:"payload"`
+}
+
+func (r *CollectorPayloadFormatViolation) FailureMessages() []string {
+	return []string{r.Failure.Message.message()}
+}
+
+// RowDecodingError describes a column of an enriched event which could not be decoded.
+type RowDecodingError struct {
+	Type    string `json:"type"`
+	Key     string `json:"key"`
+	Value   any    `json:"value"`
+	Message string `json:"message"`
+}
+
+// LoaderParsingError is emitted by loaders when an enriched event cannot be parsed.
+type LoaderParsingError struct {
+	Header
+	Failure struct {
+		Type   string             `json:"type"`
+		Errors []RowDecodingError `json:"errors"`
+	} `json:"failure"`
+	// Payload is the enriched event tsv line which could not be parsed.
+	Payload string `json:"payload"`
+}
+
+func (r *LoaderParsingError) FailureMessages() []string {
+	if len(r.Failure.Errors) == 0 {
+		return []string{r.Failure.Type}
+	}
+	output := make([]string, 0, len(r.Failure.Errors))
+	for _, failure := range r.Failure.Errors {
+		message := failure.Type + ": " + failure.Message
+		if failure.Key != "" {
+			message = failure.Key + ": " + message
+		}
+		output = append(output, message)
+	}
+	return output
+}
+
+// Event parses the enriched event carried by the bad row. It fails if the line is still invalid.
+func (r *LoaderParsingError) Event() (analytics.ParsedEvent, error) {
+	return analytics.ParseEvent(r.Payload)
+}
+
+// LoaderIgluFailure is a failure to resolve or validate a schema during loading.
+type LoaderIgluFailure struct {
+	SchemaKey       string      `json:"schemaKey"`
+	SchemaCriterion string      `json:"schemaCriterion"`
+	Error           ClientError `json:"error"`
+}
+
+// LoaderIgluError is emitted by loaders when the schemas of an event cannot be resolved or used.
+type LoaderIgluError struct {
+	Header
+	Failure []LoaderIgluFailure `json:"failure"`
+	// Payload is the event, in the JSON shape of the analytics SDKs.
+	Payload map[string]any `json:"payload"`
+}
+
+func (r *LoaderIgluError) FailureMessages() []string {
+	var output []string
+	for _, failure := range r.Failure {
+		key := failure.SchemaKey
+		if key == "" {
+			key = failure.SchemaCriterion
+		}
+		for _, message := range failure.Error.messages() {
+			output = append(output, fmt.Sprintf("%s: %s", key, message))
+		}
+	}
+	return output
+}
+
+// LoaderRecoveryError is emitted by loaders when a failed load cannot be recovered.
+type LoaderRecoveryError struct {
+	Header
+	Failure struct {
+		Error    string         `json:"error"`
+		Location *string        `json:"location"`
+		Query    *string        `json:"query"`
+		Details  map[string]any `json:"details"`
+	} `json:"failure"`
+	Payload any `json:"payload"`
+}
+
+func (r *LoaderRecoveryError) FailureMessages() []string {
+	return []string{r.Failure.Error}
+}
+
+// LoaderRuntimeError is emitted by loaders when an unexpected error occurs while loading an event.
+type LoaderRuntimeError struct {
+	Header
+	Failure struct {
+		Error string `json:"error"`
+	} `json:"failure"`
+	Payload map[string]any `json:"payload"`
+}
+
+func (r *LoaderRuntimeError) FailureMessages() []string {
+	return []string{r.Failure.Error}
+}
+
+// RelayFailure is emitted when an event cannot be relayed to a third party.
+type RelayFailure struct {
+	Header
+	Failure struct {
+		Error string `json:"error"`
+	} `json:"failure"`
+	Payload map[string]any `json:"payload"`
+}
+
+func (r *RelayFailure) FailureMessages() []string {
+	return []string{r.Failure.Error}
+}
+
+// GenericError is emitted for failures which do not fit any other bad row type.
+type GenericError struct {
+	Header
+	Failure struct {
+		Timestamp time.Time `json:"timestamp"`
+		Errors    []string  `json:"errors"`
+	} `json:"failure"`
+	Payload any `json:"payload"`
+}
+
+func (r *GenericError) FailureMessages() []string {
+	return r.Failure.Errors
+}
+
+// RecoveryError is emitted when a bad row cannot be recovered.
+type RecoveryError struct {
+	Header
+	Failure struct {
+		Error      string  `json:"error"`
+		ConfigName *string `json:"configName"`
+	} `json:"failure"`
+	// Payload is the bad row which could not be recovered.
+	Payload string `json:"payload"`
+}
+
+func (r *RecoveryError) FailureMessages() []string {
+	return []string{r.Failure.Error}
+}
+
+// Original parses the bad row which could not be recovered.
+func (r *RecoveryError) Original() (BadRow, error) {
+	return ParseBadRow([]byte(r.Payload))
+}
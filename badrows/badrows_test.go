@@ -0,0 +1,177 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package badrows
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBadRow(t *testing.T) {
+	assert := assert.New(t)
+
+	expectedTypes := map[string]BadRow{
+		schemaViolationsRow:                &SchemaViolations{},
+		enrichmentFailuresRow:              &EnrichmentFailures{},
+		adapterFailuresRow:                 &AdapterFailures{},
+		trackerProtocolViolationsRow:       &TrackerProtocolViolations{},
+		sizeViolationRow:                   &SizeViolation{},
+		collectorPayloadFormatViolationRow: &CollectorPayloadFormatViolation{},
+		loaderParsingErrorRow:              &LoaderParsingError{},
+		loaderRuntimeErrorRow:              &LoaderRuntimeError{},
+		genericErrorRow:                    &GenericError{},
+		recoveryErrorRow:                   &RecoveryError{},
+	}
+	for row, expectedType := range expectedTypes {
+		badRow, err := ParseBadRow([]byte(row))
+		assert.Nil(err)
+		assert.IsType(expectedType, badRow)
+		assert.NotEmpty(badRow.FailureMessages())
+		assert.NotEmpty(badRow.RawPayload())
+	}
+
+	// unknown bad row type
+	unknown, err := ParseBadRow([]byte(`{"schema":"iglu:com.snowplowanalytics.snowplow.badrows/not_a_type/jsonschema/1-0-0","data":{}}`))
+	assert.NotNil(err)
+	assert.Nil(unknown)
+
+	// not a bad row
+	notBadRow, err := ParseBadRow([]byte(`{"schema":"iglu:com.acme/event/jsonschema/1-0-0","data":{}}`))
+	assert.NotNil(err)
+	assert.Nil(notBadRow)
+
+	// malformed JSON
+	malformed, err := ParseBadRow([]byte(`{"schema":`))
+	assert.NotNil(err)
+	assert.Nil(malformed)
+
+	// data of the wrong shape
+	wrongShape, err := ParseBadRow([]byte(`{"schema":"iglu:com.snowplowanalytics.snowplow.badrows/generic_error/jsonschema/1-0-0","data":{"failure":{"errors":"not an array"}}}`))
+	assert.NotNil(err)
+	assert.Nil(wrongShape)
+}
+
+func BenchmarkParseBadRow(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ParseBadRow([]byte(schemaViolationsRow))
+	}
+}
+
+func TestSchemaViolations(t *testing.T) {
+	assert := assert.New(t)
+
+	badRow, err := ParseBadRow([]byte(schemaViolationsRow))
+	assert.Nil(err)
+	row := badRow.(*SchemaViolations)
+
+	assert.Equal("iglu:com.snowplowanalytics.snowplow.badrows/schema_violations/jsonschema/2-0-0", row.Schema())
+	assert.Equal(Processor{"snowplow-enrich-kinesis", "3.2.0"}, row.ProcessorInfo())
+	assert.Equal(time.Date(2021, 5, 10, 14, 40, 37, 436000000, time.UTC), row.Failure.Timestamp.UTC())
+	assert.Len(row.Failure.Messages, 2)
+	assert.Equal("ValidationError", row.Failure.Messages[0].Error.Error)
+	assert.Equal([]string{"basket"}, row.Failure.Messages[0].Error.DataReports[0].Targets)
+	assert.Equal(1, row.Failure.Messages[1].Error.LookupHistory[0].Attempts)
+
+	assert.Equal([]string{
+		"iglu:com.acme/checkout/jsonschema/1-0-0: $.basket: is missing but it is required",
+		"iglu:com.acme/checkout/jsonschema/1-0-0: $.total: string found, number expected",
+		"iglu:com.acme/missing/jsonschema/1-0-0: ResolutionError: lookup failed in repository Iglu Central: {\"error\":\"NotFound\"}",
+	}, row.FailureMessages())
+
+	// payload
+	assert.Equal("shop", row.Payload.Enriched["app_id"])
+	assert.Equal(map[string]string{"e": "ue", "aid": "shop", "empty": ""}, row.Payload.Raw.ParameterMap())
+	assert.Equal("18.194.133.57", *row.Payload.Raw.IPAddress)
+	assert.Nil(row.Payload.Raw.RefererURI)
+
+	var rawPayload map[string]any
+	assert.Nil(json.Unmarshal(row.RawPayload(), &rawPayload))
+	assert.Contains(rawPayload, "enriched")
+	assert.Contains(rawPayload, "raw")
+}
+
+func TestEnrichmentFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	badRow, err := ParseBadRow([]byte(enrichmentFailuresRow))
+	assert.Nil(err)
+	row := badRow.(*EnrichmentFailures)
+
+	assert.Equal("campaign-attribution", row.Failure.Messages[0].Enrichment.Identifier)
+	assert.Nil(row.Failure.Messages[1].Enrichment)
+	assert.Equal([]string{
+		"campaign-attribution: page_urlquery: a valid querystring (value: utm_source=%%)",
+		"unexpected error",
+	}, row.FailureMessages())
+}
+
+func TestCollectorPayloadFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	badRow, err := ParseBadRow([]byte(adapterFailuresRow))
+	assert.Nil(err)
+	adapterRow := badRow.(*AdapterFailures)
+	assert.Equal("com.mailchimp", adapterRow.Failure.Vendor)
+	assert.Equal("a=b", *adapterRow.Payload.Body)
+	assert.Equal(map[string]string{"type": "unknown"}, adapterRow.Payload.QuerystringMap())
+	assert.Equal([]string{"type: no schema associated with the provided type parameter (value: unknown)"}, adapterRow.FailureMessages())
+
+	badRow, err = ParseBadRow([]byte(trackerProtocolViolationsRow))
+	assert.Nil(err)
+	assert.Equal([]string{
+		"ue_px: invalid base 64 (value: not base64)",
+		"e: event type must be provided",
+	}, badRow.FailureMessages())
+
+	badRow, err = ParseBadRow([]byte(sizeViolationRow))
+	assert.Nil(err)
+	sizeRow := badRow.(*SizeViolation)
+	assert.Equal("GET /i?e=pv", sizeRow.Payload.Event)
+	assert.Equal([]string{"oversized collector payload (size 1500000 bytes, maximum 1000000 bytes)"}, sizeRow.FailureMessages())
+
+	badRow, err = ParseBadRow([]byte(collectorPayloadFormatViolationRow))
+	assert.Nil(err)
+	assert.Equal("AAAA", badRow.(*CollectorPayloadFormatViolation).Payload.Line)
+	assert.Equal([]string{"error deserializing raw event: cannot read a TMap"}, badRow.FailureMessages())
+}
+
+func TestLoaderParsingError(t *testing.T) {
+	assert := assert.New(t)
+
+	badRow, err := ParseBadRow([]byte(loaderParsingErrorRow))
+	assert.Nil(err)
+	row := badRow.(*LoaderParsingError)
+	assert.Equal("shop\tweb", row.Payload)
+	assert.Equal([]string{"collector_tstamp: InvalidValue: Cannot parse key collector_tstamp into datetime"}, row.FailureMessages())
+
+	// the payload is still an invalid event
+	event, err := row.Event()
+	assert.NotNil(err)
+	assert.Nil(event)
+}
+
+func TestRecoveryError(t *testing.T) {
+	assert := assert.New(t)
+
+	badRow, err := ParseBadRow([]byte(recoveryErrorRow))
+	assert.Nil(err)
+	row := badRow.(*RecoveryError)
+	assert.Equal([]string{"no matching config"}, row.FailureMessages())
+
+	original, err := row.Original()
+	assert.Nil(err)
+	assert.IsType(&SizeViolation{}, original)
+}
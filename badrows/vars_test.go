@@ -0,0 +1,39 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package badrows
+
+var schemaViolationsRow = `{"schema":"iglu:com.snowplowanalytics.snowplow.badrows/schema_violations/jsonschema/2-0-0","data":{"processor":{"artifact":"snowplow-enrich-kinesis","version":"3.2.0"},"failure":{"timestamp":"2021-05-10T14:40:37.436Z","messages":[{"schemaKey":"iglu:com.acme/checkout/jsonschema/1-0-0","error":{"error":"ValidationError","dataReports":[{"message":"$.basket: is missing but it is required","path":"$","keyword":"required","targets":["basket"]},{"message":"$.total: string found, number expected","path":"$.total","keyword":"type","targets":["string","number"]}]}},{"schemaKey":"iglu:com.acme/missing/jsonschema/1-0-0","error":{"error":"ResolutionError","lookupHistory":[{"repository":"Iglu Central","errors":[{"error":"NotFound"}],"attempts":1,"lastAttempt":"2021-05-10T14:40:37.400Z"}]}}]},"payload":{"enriched":{"app_id":"shop","event_id":"e9234345-f042-46ad-b1aa-424464066a33","txn_id":41828,"collector_tstamp":"2021-05-10 14:40:35.972"},"raw":{"vendor":"com.snowplowanalytics.snowplow","version":"tp2","parameters":[{"name":"e","value":"ue"},{"name":"aid","value":"shop"},{"name":"empty","value":null}],"contentType":"application/json","loaderName":"ssc-2.3.0-kinesis","encoding":"UTF-8","hostname":"collector.acme.com","timestamp":"2021-05-10T14:40:35.972Z","ipAddress":"18.194.133.57","useragent":"python-requests/2.21.0","refererUri":null,"headers":["Host: collector.acme.com"],"userId":"d26822f5-52cc-4292-8f77-14ef6b7a27e2"}}}}`
+
+var enrichmentFailuresRow = `{"schema":"iglu:com.snowplowanalytics.snowplow.badrows/enrichment_failures/jsonschema/2-0-0","data":{"processor":{"artifact":"beam-enrich","version":"1.2.0"},"failure":{"timestamp":"2021-05-10T14:40:37.436Z","messages":[{"enrichment":{"schemaKey":"iglu:com.snowplowanalytics.snowplow/campaign_attribution/jsonschema/1-0-1","identifier":"campaign-attribution"},"message":{"field":"page_urlquery","value":"utm_source=%%","expectation":"a valid querystring"}},{"enrichment":null,"message":{"error":"unexpected error"}}]},"payload":{"enriched":{"app_id":"shop"},"raw":{"vendor":"com.snowplowanalytics.snowplow","version":"tp2","parameters":[{"name":"e","value":"pv"}],"loaderName":"ssc","encoding":"UTF-8"}}}}`
+
+var adapterFailuresRow = `{"schema":"iglu:com.snowplowanalytics.snowplow.badrows/adapter_failures/jsonschema/1-0-0","data":{"processor":{"artifact":"snowplow-enrich","version":"1.0.0"},"failure":{"timestamp":"2021-05-10T14:40:37.436Z","vendor":"com.mailchimp","version":"v1","messages":[{"field":"type","value":"unknown","expectation":"no schema associated with the provided type parameter"}]},"payload":{"vendor":"com.mailchimp","version":"v1","querystring":[{"name":"type","value":"unknown"}],"contentType":"application/x-www-form-urlencoded","body":"a=b","collector":"ssc","encoding":"UTF-8","hostname":null,"timestamp":"2021-05-10T14:40:35.972Z","ipAddress":null,"useragent":null,"refererUri":null,"headers":[],"networkUserId":"ecdff4d0-9175-40ac-a8bb-325c49733607"}}}`
+
+var trackerProtocolViolationsRow = `{"schema":"iglu:com.snowplowanalytics.snowplow.badrows/tracker_protocol_violations/jsonschema/1-0-0","data":{"processor":{"artifact":"snowplow-enrich","version":"1.0.0"},"failure":{"timestamp":"2021-05-10T14:40:37.436Z","vendor":"com.snowplowanalytics.snowplow","version":"tp2","messages":[{"field":"ue_px","value":"not base64","error":"invalid base 64"},{"field":"e","value":null,"expectation":"event type must be provided"}]},"payload":{"vendor":"com.snowplowanalytics.snowplow","version":"tp2","querystring":[],"collector":"ssc","encoding":"UTF-8","headers":[]}}}`
+
+var sizeViolationRow = `{"schema":"iglu:com.snowplowanalytics.snowplow.badrows/size_violation/jsonschema/1-0-0","data":{"processor":{"artifact":"snowplow-stream-collector","version":"2.3.0"},"failure":{"timestamp":"2021-05-10T14:40:37.436Z","maximumAllowedSizeBytes":1000000,"actualSizeBytes":1500000,"expectation":"oversized collector payload"},"payload":{"event":"GET /i?e=pv"}}}`
+
+var collectorPayloadFormatViolationRow = `{"schema":"iglu:com.snowplowanalytics.snowplow.badrows/collector_payload_format_violation/jsonschema/1-0-0","data":{"processor":{"artifact":"snowplow-enrich","version":"1.0.0"},"failure":{"timestamp":"2021-05-10T14:40:37.436Z","loader":"thrift","message":{"error":"error deserializing raw event: cannot read a TMap"}},"payload":{"line":"AAAA"}}}`
+
+var loaderParsingErrorRow = `{"schema":"iglu:com.snowplowanalytics.snowplow.badrows/loader_parsing_error/jsonschema/2-0-0","data":{"processor":{"artifact":"snowplow-rdb-loader","version":"1.0.0"},"failure":{"type":"RowDecodingError","errors":[{"type":"InvalidValue","key":"collector_tstamp","value":"not a timestamp","message":"Cannot parse key collector_tstamp into datetime"}]},"payload":"shop\tweb"}}`
+
+var loaderRuntimeErrorRow = `{"schema":"iglu:com.snowplowanalytics.snowplow.badrows/loader_runtime_error/jsonschema/1-0-1","data":{"processor":{"artifact":"snowplow-bigquery-loader","version":"1.0.0"},"failure":{"error":"insert failed"},"payload":{"app_id":"shop"}}}`
+
+var genericErrorRow = `{"schema":"iglu:com.snowplowanalytics.snowplow.badrows/generic_error/jsonschema/1-0-0","data":{"processor":{"artifact":"custom","version":"0.1.0"},"failure":{"timestamp":"2021-05-10T14:40:37.436Z","errors":["first","second"]},"payload":{"event":"x"}}}`
+
+var recoveryErrorRow = `{"schema":"iglu:com.snowplowanalytics.snowplow.badrows/recovery_error/jsonschema/1-0-0","data":{"processor":{"artifact":"snowplow-event-recovery","version":"0.2.0"},"failure":{"error":"no matching config","configName":null},"payload":` + jsonString(sizeViolationRow) + `}}`
+
+func jsonString(s string) string {
+	out, _ := json.MarshalToString(s)
+	return out
+}
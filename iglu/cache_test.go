@@ -0,0 +1,68 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package iglu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newCache[string, int](2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.put("a", 1)
+	c.put("b", 2)
+	value, ok := c.get("a")
+	assert.True(ok)
+	assert.Equal(1, value)
+
+	// b is the least recently used entry, and is evicted
+	c.put("c", 3)
+	assert.Equal(2, c.len())
+	_, ok = c.get("b")
+	assert.False(ok)
+
+	// updating an entry replaces its value and expiry
+	now = now.Add(30 * time.Second)
+	c.put("a", 4)
+	now = now.Add(45 * time.Second)
+	value, ok = c.get("a")
+	assert.True(ok)
+	assert.Equal(4, value)
+
+	// c was put over a minute ago, and has expired
+	_, ok = c.get("c")
+	assert.False(ok)
+	assert.Equal(1, c.len())
+
+	// a size of 0 disables caching
+	disabled := newCache[string, int](0, time.Minute)
+	disabled.put("a", 1)
+	_, ok = disabled.get("a")
+	assert.False(ok)
+}
+
+func BenchmarkCache(b *testing.B) {
+	c := newCache[string, int](100, time.Minute)
+	c.put("a", 1)
+	for i := 0; i < b.N; i++ {
+		c.get("a")
+	}
+}
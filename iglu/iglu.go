@@ -0,0 +1,303 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+// Package iglu resolves the JSON Schemas of self-describing data from Iglu repositories.
+package iglu
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned when a schema is not found in any repository.
+var ErrNotFound = errors.New("schema not found")
+
+// ErrListUnsupported is returned by repositories which cannot list the schemas they hold, such as static HTTP repositories.
+var ErrListUnsupported = errors.New("listing schemas is not supported by repository")
+
+// SchemaVer is the version of a schema, as MODEL-REVISION-ADDITION. A new model is incompatible with data valid
+// against previous models, a new revision may be incompatible with some of it, and a new addition is compatible with all of it.
+type SchemaVer struct {
+	Model    int
+	Revision int
+	Addition int
+}
+
+// ParseSchemaVer parses a version such as 1-0-0.
+func ParseSchemaVer(version string) (SchemaVer, error) {
+	parsed, ok := parseSchemaVer(version)
+	if !ok {
+		return SchemaVer{}, fmt.Errorf("version '%s' is not of the form MODEL-REVISION-ADDITION", version)
+	}
+	if parsed.Model == 0 {
+		return SchemaVer{}, fmt.Errorf("version '%s' has a model of 0", version)
+	}
+	return parsed, nil
+}
+
+// parseSchemaVer parses the three numbers of a version without leading zeros, without allocating.
+func parseSchemaVer(version string) (SchemaVer, bool) {
+	var numbers [3]int
+	rest := version
+	for i := range numbers {
+		end := strings.IndexByte(rest, '-')
+		if i == len(numbers)-1 {
+			end = len(rest)
+		} else if end < 0 {
+			return SchemaVer{}, false
+		}
+		number, ok := parseNumber(rest[:end])
+		if !ok {
+			return SchemaVer{}, false
+		}
+		numbers[i] = number
+		if end < len(rest) {
+			rest = rest[end+1:]
+		}
+	}
+	return SchemaVer{Model: numbers[0], Revision: numbers[1], Addition: numbers[2]}, true
+}
+
+// parseNumber parses a non-negative decimal number without leading zeros.
+func parseNumber(s string) (int, bool) {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return 0, false
+	}
+	number := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+		digit := int(s[i] - '0')
+		if number > (math.MaxInt-digit)/10 {
+			return 0, false
+		}
+		number = number*10 + digit
+	}
+	return number, true
+}
+
+// String returns the version as MODEL-REVISION-ADDITION.
+func (v SchemaVer) String() string {
+	return strconv.Itoa(v.Model) + "-" + strconv.Itoa(v.Revision) + "-" + strconv.Itoa(v.Addition)
+}
+
+// Compare returns -1, 0 or +1 depending on whether v precedes, equals or follows other.
+func (v SchemaVer) Compare(other SchemaVer) int {
+	return cmp.Or(cmp.Compare(v.Model, other.Model), cmp.Compare(v.Revision, other.Revision), cmp.Compare(v.Addition, other.Addition))
+}
+
+// Compatible reports whether all data valid against one version is valid against the other, which is the case for
+// versions differing only by their addition.
+func (v SchemaVer) Compatible(other SchemaVer) bool {
+	return v.Model == other.Model && v.Revision == other.Revision
+}
+
+// SameModel reports whether the versions share their model. Data valid against one version may still be invalid
+// against the other if their revisions differ.
+func (v SchemaVer) SameModel(other SchemaVer) bool {
+	return v.Model == other.Model
+}
+
+// SchemaKey identifies a schema in Iglu repositories.
+type SchemaKey struct {
+	Vendor  string
+	Name    string
+	Format  string
+	Version SchemaVer
+}
+
+// ParseSchemaKey parses a schema URI such as iglu:com.acme/my_context/jsonschema/1-0-0. It does not allocate for
+// valid URIs.
+func ParseSchemaKey(uri string) (SchemaKey, error) {
+	vendor, name, format, version, ok := SplitSchemaURI(uri)
+	if !ok {
+		return SchemaKey{}, fmt.Errorf("schema '%s' is not of the form iglu:VENDOR/NAME/FORMAT/MODEL-REVISION-ADDITION", uri)
+	}
+	parsed, _ := parseSchemaVer(version)
+	return SchemaKey{Vendor: vendor, Name: name, Format: format, Version: parsed}, nil
+}
+
+// SplitSchemaURI splits a schema URI such as iglu:com.acme/my_context/jsonschema/1-0-0 into its vendor, name, format
+// and version, without allocating. The vendor may contain letters, digits, '-', '_' and '.', the name and format the
+// same characters except '.', and the version is MODEL-REVISION-ADDITION with no leading zeros and a non-zero model.
+// ok is false for URIs which are not of this form.
+func SplitSchemaURI(uri string) (vendor string, name string, format string, version string, ok bool) {
+	rest, ok := strings.CutPrefix(uri, "iglu:")
+	if !ok {
+		return "", "", "", "", false
+	}
+	var segments [4]string
+	for i := range segments {
+		end := strings.IndexByte(rest, '/')
+		if i == len(segments)-1 {
+			if end >= 0 {
+				return "", "", "", "", false
+			}
+			end = len(rest)
+		} else if end < 0 {
+			return "", "", "", "", false
+		}
+		segments[i] = rest[:end]
+		if end < len(rest) {
+			rest = rest[end+1:]
+		}
+	}
+	if !isSchemaSegment(segments[0], true) || !isSchemaSegment(segments[1], false) || !isSchemaSegment(segments[2], false) {
+		return "", "", "", "", false
+	}
+	if parsed, valid := parseSchemaVer(segments[3]); !valid || parsed.Model == 0 {
+		return "", "", "", "", false
+	}
+	return segments[0], segments[1], segments[2], segments[3], true
+}
+
+// isSchemaSegment reports whether a vendor, name or format is non-empty and only contains allowed characters, with
+// dots only allowed in vendors.
+func isSchemaSegment(segment string, allowDots bool) bool {
+	if segment == "" {
+		return false
+	}
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_':
+		case c == '.' && allowDots:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the schema URI of the key.
+func (k SchemaKey) String() string {
+	return "iglu:" + k.Path()
+}
+
+// Path returns the path of the schema relative to the schemas directory of a repository.
+func (k SchemaKey) Path() string {
+	return k.Vendor + "/" + k.Name + "/" + k.Format + "/" + k.Version.String()
+}
+
+// Compare orders keys by vendor, name, format and version, returning -1, 0 or +1 depending on whether k precedes,
+// equals or follows other.
+func (k SchemaKey) Compare(other SchemaKey) int {
+	return cmp.Or(
+		strings.Compare(k.Vendor, other.Vendor),
+		strings.Compare(k.Name, other.Name),
+		strings.Compare(k.Format, other.Format),
+		k.Version.Compare(other.Version),
+	)
+}
+
+// Compatible reports whether the keys identify versions of the same schema, with all data valid against one being
+// valid against the other.
+func (k SchemaKey) Compatible(other SchemaKey) bool {
+	return k.Vendor == other.Vendor && k.Name == other.Name && k.Format == other.Format && k.Version.Compatible(other.Version)
+}
+
+// Any matches any value in a SchemaCriterion.
+const Any = -1
+
+const anyString = "*"
+
+// SchemaCriterion matches schema keys, with "*" as the vendor, name or format, and Any as a version number, matching
+// any value. For example, iglu:com.acme/*/jsonschema/1-*-* matches all schemas of vendor com.acme with a model of 1.
+type SchemaCriterion struct {
+	Vendor   string
+	Name     string
+	Format   string
+	Model    int
+	Revision int
+	Addition int
+}
+
+// ParseSchemaCriterion parses a criterion such as iglu:com.acme/*/jsonschema/1-*-*.
+func ParseSchemaCriterion(criterion string) (SchemaCriterion, error) {
+	invalid := fmt.Errorf("criterion '%s' is not of the form iglu:VENDOR/NAME/FORMAT/MODEL-REVISION-ADDITION", criterion)
+	rest, ok := strings.CutPrefix(criterion, "iglu:")
+	if !ok {
+		return SchemaCriterion{}, invalid
+	}
+	segments := strings.Split(rest, "/")
+	if len(segments) != 4 {
+		return SchemaCriterion{}, invalid
+	}
+	for i, segment := range segments[:3] {
+		if segment != anyString && !isSchemaSegment(segment, i == 0) {
+			return SchemaCriterion{}, invalid
+		}
+	}
+	parts := strings.Split(segments[3], "-")
+	if len(parts) != 3 {
+		return SchemaCriterion{}, invalid
+	}
+	var numbers [3]int
+	for i, part := range parts {
+		if part == anyString {
+			numbers[i] = Any
+			continue
+		}
+		number, ok := parseNumber(part)
+		if !ok || (i == 0 && number == 0) {
+			return SchemaCriterion{}, invalid
+		}
+		numbers[i] = number
+	}
+	return SchemaCriterion{
+		Vendor:   segments[0],
+		Name:     segments[1],
+		Format:   segments[2],
+		Model:    numbers[0],
+		Revision: numbers[1],
+		Addition: numbers[2],
+	}, nil
+}
+
+// String returns the criterion as iglu:VENDOR/NAME/FORMAT/MODEL-REVISION-ADDITION.
+func (c SchemaCriterion) String() string {
+	number := func(n int) string {
+		if n == Any {
+			return anyString
+		}
+		return strconv.Itoa(n)
+	}
+	return "iglu:" + c.Vendor + "/" + c.Name + "/" + c.Format + "/" + number(c.Model) + "-" + number(c.Revision) + "-" + number(c.Addition)
+}
+
+// Matches reports whether a schema key matches the criterion.
+func (c SchemaCriterion) Matches(key SchemaKey) bool {
+	matchString := func(want string, got string) bool {
+		return want == anyString || want == got
+	}
+	matchNumber := func(want int, got int) bool {
+		return want == Any || want == got
+	}
+	return matchString(c.Vendor, key.Vendor) &&
+		matchString(c.Name, key.Name) &&
+		matchString(c.Format, key.Format) &&
+		matchNumber(c.Model, key.Version.Model) &&
+		matchNumber(c.Revision, key.Version.Revision) &&
+		matchNumber(c.Addition, key.Version.Addition)
+}
+
+// MatchesURI reports whether a schema URI matches the criterion, without allocating. Invalid URIs match no criterion.
+func (c SchemaCriterion) MatchesURI(uri string) bool {
+	key, err := ParseSchemaKey(uri)
+	return err == nil && c.Matches(key)
+}
@@ -0,0 +1,194 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package iglu
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSchemaKey(t *testing.T) {
+	assert := assert.New(t)
+
+	// correct value
+	key, err := ParseSchemaKey("iglu:com.acme.data/some_event/jsonschema/15-34-1")
+	assert.Nil(err)
+	assert.Equal(SchemaKey{Vendor: "com.acme.data", Name: "some_event", Format: "jsonschema", Version: SchemaVer{15, 34, 1}}, key)
+	assert.Equal("iglu:com.acme.data/some_event/jsonschema/15-34-1", key.String())
+	assert.Equal("com.acme.data/some_event/jsonschema/15-34-1", key.Path())
+
+	// invalid values
+	for _, uri := range []string{
+		"com.acme.notvalid/invalidschemapath/jsonschema/1.0.0",
+		"iglu:com.acme/name/jsonschema/0-0-0",
+		"iglu:com.acme/name/jsonschema/1-01-0",
+		"iglu:com.acme//jsonschema/1-0-0",
+		"iglu:com.acme/name/jsonschema/1-0-0/extra",
+	} {
+		invalidKey, err := ParseSchemaKey(uri)
+		assert.NotNil(err, uri)
+		assert.Zero(invalidKey)
+	}
+}
+
+func BenchmarkParseSchemaKey(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ParseSchemaKey("iglu:com.acme.data/some_event/jsonschema/15-34-1")
+	}
+}
+
+func TestSplitSchemaURI(t *testing.T) {
+	assert := assert.New(t)
+
+	vendor, name, format, version, ok := SplitSchemaURI("iglu:com.acme.data/some_event/jsonschema/15-34-1")
+	assert.True(ok)
+	assert.Equal([]string{"com.acme.data", "some_event", "jsonschema", "15-34-1"}, []string{vendor, name, format, version})
+	assert.Zero(testing.AllocsPerRun(10, func() {
+		SplitSchemaURI("iglu:com.acme.data/some_event/jsonschema/15-34-1")
+	}))
+
+	for _, uri := range []string{
+		"iglu:com.acme/event/jsonschema/0-0-0",
+		"iglu:com.acme/event/jsonschema/1-0-00",
+		"iglu:com.acme/event/jsonschema/1-0",
+		"iglu:com.acme/event/jsonschema/1-0-0-0",
+		"iglu:com.acme/event/jsonschema/1--0",
+		"iglu:com.acme/event/jsonschema/99999999999999999999-0-0",
+		"iglu:com.acme/ev.ent/jsonschema/1-0-0",
+		"iglu:com/acme/event/jsonschema/1-0-0",
+		"iglu:com.acme/event/jsonschema/1-0-0 ",
+		"IGLU:com.acme/event/jsonschema/1-0-0",
+		"iglu:",
+	} {
+		_, _, _, _, ok := SplitSchemaURI(uri)
+		assert.False(ok, uri)
+	}
+}
+
+func BenchmarkSplitSchemaURI(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		SplitSchemaURI("iglu:com.acme.data/some_event/jsonschema/15-34-1")
+	}
+}
+
+func TestParseSchemaVer(t *testing.T) {
+	assert := assert.New(t)
+
+	version, err := ParseSchemaVer("2-10-0")
+	assert.Nil(err)
+	assert.Equal(SchemaVer{Model: 2, Revision: 10, Addition: 0}, version)
+	assert.Equal("2-10-0", version.String())
+
+	for _, invalid := range []string{"", "1-0", "1-0-0-0", "0-1-0", "1-a-0", "1--1-0", "1-00-0", "+1-0-0"} {
+		invalidVersion, err := ParseSchemaVer(invalid)
+		assert.NotNil(err, invalid)
+		assert.Zero(invalidVersion)
+	}
+}
+
+func BenchmarkParseSchemaVer(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ParseSchemaVer("2-10-0")
+	}
+}
+
+func TestSchemaVerCompare(t *testing.T) {
+	assert := assert.New(t)
+
+	versions := []SchemaVer{{2, 0, 0}, {1, 0, 10}, {1, 1, 0}, {1, 0, 2}, {1, 0, 0}}
+	slices.SortFunc(versions, SchemaVer.Compare)
+	assert.Equal([]SchemaVer{{1, 0, 0}, {1, 0, 2}, {1, 0, 10}, {1, 1, 0}, {2, 0, 0}}, versions)
+	assert.Equal(0, SchemaVer{1, 2, 3}.Compare(SchemaVer{1, 2, 3}))
+
+	assert.True(SchemaVer{1, 0, 0}.Compatible(SchemaVer{1, 0, 3}))
+	assert.False(SchemaVer{1, 0, 0}.Compatible(SchemaVer{1, 1, 0}))
+	assert.True(SchemaVer{1, 0, 0}.SameModel(SchemaVer{1, 1, 0}))
+	assert.False(SchemaVer{1, 0, 0}.SameModel(SchemaVer{2, 0, 0}))
+}
+
+func TestSchemaKeyCompare(t *testing.T) {
+	assert := assert.New(t)
+
+	a := SchemaKey{"com.acme", "a", "jsonschema", SchemaVer{2, 0, 0}}
+	b := SchemaKey{"com.acme", "b", "jsonschema", SchemaVer{1, 0, 0}}
+	bNewer := SchemaKey{"com.acme", "b", "jsonschema", SchemaVer{1, 0, 1}}
+	keys := []SchemaKey{bNewer, b, a}
+	slices.SortFunc(keys, SchemaKey.Compare)
+	assert.Equal([]SchemaKey{a, b, bNewer}, keys)
+
+	assert.True(b.Compatible(bNewer))
+	assert.False(a.Compatible(SchemaKey{"com.acme", "a", "jsonschema", SchemaVer{2, 1, 0}}))
+	assert.False(b.Compatible(SchemaKey{"com.other", "b", "jsonschema", SchemaVer{1, 0, 0}}))
+}
+
+func TestParseSchemaCriterion(t *testing.T) {
+	assert := assert.New(t)
+
+	criterion, err := ParseSchemaCriterion("iglu:com.acme/*/jsonschema/1-*-*")
+	assert.Nil(err)
+	assert.Equal(SchemaCriterion{Vendor: "com.acme", Name: "*", Format: "jsonschema", Model: 1, Revision: Any, Addition: Any}, criterion)
+	assert.Equal("iglu:com.acme/*/jsonschema/1-*-*", criterion.String())
+
+	exact, err := ParseSchemaCriterion("iglu:com.acme/event/jsonschema/1-0-2")
+	assert.Nil(err)
+	assert.Equal(SchemaCriterion{Vendor: "com.acme", Name: "event", Format: "jsonschema", Model: 1, Revision: 0, Addition: 2}, exact)
+
+	for _, invalid := range []string{
+		"com.acme/*/jsonschema/1-*-*",
+		"iglu:com.acme/*/jsonschema",
+		"iglu:com.acme/*/jsonschema/1-*",
+		"iglu:com.acme/*/jsonschema/0-*-*",
+		"iglu:com.acme/na me/jsonschema/1-*-*",
+		"iglu:com.acme//jsonschema/1-*-*",
+	} {
+		invalidCriterion, err := ParseSchemaCriterion(invalid)
+		assert.NotNil(err, invalid)
+		assert.Zero(invalidCriterion)
+	}
+}
+
+func BenchmarkParseSchemaCriterion(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ParseSchemaCriterion("iglu:com.acme/*/jsonschema/1-*-*")
+	}
+}
+
+func TestSchemaCriterionMatches(t *testing.T) {
+	assert := assert.New(t)
+
+	criterion, _ := ParseSchemaCriterion("iglu:com.acme/*/jsonschema/1-*-*")
+	assert.True(criterion.MatchesURI("iglu:com.acme/event/jsonschema/1-0-0"))
+	assert.True(criterion.MatchesURI("iglu:com.acme/context/jsonschema/1-2-3"))
+	assert.False(criterion.MatchesURI("iglu:com.acme/event/jsonschema/2-0-0"))
+	assert.False(criterion.MatchesURI("iglu:com.acme.other/event/jsonschema/1-0-0"))
+	assert.False(criterion.MatchesURI("not a schema"))
+	assert.Zero(testing.AllocsPerRun(10, func() {
+		criterion.MatchesURI("iglu:com.acme/event/jsonschema/1-0-0")
+	}))
+
+	revision, _ := ParseSchemaCriterion("iglu:*/event/*/1-1-*")
+	assert.True(revision.Matches(SchemaKey{"com.other", "event", "avro", SchemaVer{1, 1, 5}}))
+	assert.False(revision.Matches(SchemaKey{"com.other", "event", "avro", SchemaVer{1, 0, 5}}))
+}
+
+func BenchmarkSchemaCriterionMatches(b *testing.B) {
+	criterion, _ := ParseSchemaCriterion("iglu:com.acme/*/jsonschema/1-*-*")
+	key := SchemaKey{"com.acme", "event", "jsonschema", SchemaVer{1, 0, 0}}
+	for i := 0; i < b.N; i++ {
+		criterion.Matches(key)
+	}
+}
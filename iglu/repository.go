@@ -0,0 +1,201 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package iglu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var json = jsoniter.Config{}.Froze()
+
+// RepositoryConfig holds the resolution settings of a repository, as in Iglu resolver configurations.
+type RepositoryConfig struct {
+	Name string
+	// Priority orders repositories, lower values being looked up first.
+	Priority int
+	// VendorPrefixes lists the vendors the repository is looked up first for, ahead of any priority.
+	VendorPrefixes []string
+}
+
+// matchesVendor reports whether the vendor starts with one of the repository's vendor prefixes.
+func (c RepositoryConfig) matchesVendor(vendor string) bool {
+	for _, prefix := range c.VendorPrefixes {
+		if strings.HasPrefix(vendor, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Repository is a source of schemas. Lookup and List return ErrNotFound when the repository does not hold the
+// requested schemas, and List returns ErrListUnsupported when the repository cannot list them.
+type Repository interface {
+	Config() RepositoryConfig
+	Lookup(ctx context.Context, key SchemaKey) ([]byte, error)
+	List(ctx context.Context, vendor string, name string, model int) ([]SchemaKey, error)
+}
+
+// fsRepository reads schemas from a file system laid out as an Iglu static repository.
+type fsRepository struct {
+	config RepositoryConfig
+	fsys   fs.FS
+}
+
+// NewFSRepository returns a repository reading schemas from fsys, such as an embed.FS, with each schema stored at
+// schemas/<vendor>/<name>/<format>/<version>.
+func NewFSRepository(config RepositoryConfig, fsys fs.FS) Repository {
+	return &fsRepository{config: config, fsys: fsys}
+}
+
+// NewDirRepository returns a repository reading schemas from a local directory laid out as an Iglu static repository.
+func NewDirRepository(config RepositoryConfig, dir string) Repository {
+	return NewFSRepository(config, os.DirFS(dir))
+}
+
+func (r *fsRepository) Config() RepositoryConfig {
+	return r.config
+}
+
+func (r *fsRepository) Lookup(ctx context.Context, key SchemaKey) ([]byte, error) {
+	schema, err := fs.ReadFile(r.fsys, path.Join("schemas", key.Path()))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s in repository %s: %w", key, r.config.Name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s from repository %s: %w", key, r.config.Name, err)
+	}
+	return schema, nil
+}
+
+func (r *fsRepository) List(ctx context.Context, vendor string, name string, model int) ([]SchemaKey, error) {
+	dir := path.Join("schemas", vendor, name, "jsonschema")
+	entries, err := fs.ReadDir(r.fsys, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("iglu:%s/%s/jsonschema/%v-*-* in repository %s: %w", vendor, name, model, r.config.Name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot list %s in repository %s: %w", dir, r.config.Name, err)
+	}
+	var keys []SchemaKey
+	for _, entry := range entries {
+		key, err := ParseSchemaKey("iglu:" + path.Join(vendor, name, "jsonschema", entry.Name()))
+		if err != nil || entry.IsDir() || key.Version.Model != model {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("iglu:%s/%s/jsonschema/%v-*-* in repository %s: %w", vendor, name, model, r.config.Name, ErrNotFound)
+	}
+	return keys, nil
+}
+
+// httpRepository reads schemas from a static repository or an Iglu Server over HTTP.
+type httpRepository struct {
+	config RepositoryConfig
+	uri    string
+	apiKey string
+	server bool
+	client *http.Client
+}
+
+// NewHTTPRepository returns a repository reading schemas from a static Iglu repository served over HTTP, fetching
+// each schema from <uri>/schemas/<vendor>/<name>/<format>/<version>. http.DefaultClient is used if client is nil.
+func NewHTTPRepository(config RepositoryConfig, uri string, client *http.Client) Repository {
+	return newHTTPRepository(config, uri, "", false, client)
+}
+
+// NewServerRepository returns a repository reading schemas from the API of an Iglu Server, fetching each schema from
+// <uri>/api/schemas/<vendor>/<name>/<format>/<version>. The API key is sent if not empty, to read private schemas.
+// http.DefaultClient is used if client is nil.
+func NewServerRepository(config RepositoryConfig, uri string, apiKey string, client *http.Client) Repository {
+	return newHTTPRepository(config, strings.TrimSuffix(uri, "/")+"/api", apiKey, true, client)
+}
+
+func newHTTPRepository(config RepositoryConfig, uri string, apiKey string, server bool, client *http.Client) *httpRepository {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpRepository{config: config, uri: strings.TrimSuffix(uri, "/"), apiKey: apiKey, server: server, client: client}
+}
+
+func (r *httpRepository) Config() RepositoryConfig {
+	return r.config
+}
+
+func (r *httpRepository) get(ctx context.Context, url string, notFound string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot request %s from repository %s: %w", url, r.config.Name, err)
+	}
+	if r.apiKey != "" {
+		request.Header.Set("apikey", r.apiKey)
+	}
+	response, err := r.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("cannot request %s from repository %s: %w", url, r.config.Name, err)
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%s in repository %s: %w", notFound, r.config.Name, ErrNotFound)
+	case response.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("cannot request %s from repository %s: unexpected status %s", url, r.config.Name, response.Status)
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s from repository %s: %w", url, r.config.Name, err)
+	}
+	return body, nil
+}
+
+func (r *httpRepository) Lookup(ctx context.Context, key SchemaKey) ([]byte, error) {
+	return r.get(ctx, r.uri+"/schemas/"+key.Path(), key.String())
+}
+
+func (r *httpRepository) List(ctx context.Context, vendor string, name string, model int) ([]SchemaKey, error) {
+	if !r.server {
+		return nil, fmt.Errorf("repository %s: %w", r.config.Name, ErrListUnsupported)
+	}
+	criterion := fmt.Sprintf("iglu:%s/%s/jsonschema/%v-*-*", vendor, name, model)
+	body, err := r.get(ctx, r.uri+"/schemas/"+path.Join(vendor, name, "jsonschema", strconv.Itoa(model)), criterion)
+	if err != nil {
+		return nil, err
+	}
+	var uris []string
+	if err := json.Unmarshal(body, &uris); err != nil {
+		return nil, fmt.Errorf("cannot parse schema list from repository %s: %w", r.config.Name, err)
+	}
+	keys := make([]SchemaKey, 0, len(uris))
+	for _, uri := range uris {
+		key, err := ParseSchemaKey(uri)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse schema list from repository %s: %w", r.config.Name, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
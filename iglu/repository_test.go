@@ -0,0 +1,133 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package iglu
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var linkClickKey = SchemaKey{Vendor: "com.snowplowanalytics.snowplow", Name: "link_click", Format: "jsonschema", Version: SchemaVer{1, 0, 1}}
+
+var linkClickSchema = []byte(`{"self":{"vendor":"com.snowplowanalytics.snowplow","name":"link_click","format":"jsonschema","version":SchemaVer{1, 0, 1}},"type":"object"}`)
+
+var testRepository = fstest.MapFS{
+	"schemas/com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1":  {Data: linkClickSchema},
+	"schemas/com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-0":  {Data: []byte(`{}`)},
+	"schemas/com.snowplowanalytics.snowplow/link_click/jsonschema/2-0-0":  {Data: []byte(`{}`)},
+	"schemas/com.snowplowanalytics.snowplow/link_click/jsonschema/README": {Data: []byte(`not a schema`)},
+}
+
+// newTestServer serves testRepository as a static repository, and as an Iglu Server API under /api.
+// It counts the requests it receives, and only serves the API to requests with the provided API key.
+func newTestServer(apiKey string, requests *atomic.Int32) *httptest.Server {
+	static := http.FileServer(http.FS(testRepository))
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			static.ServeHTTP(w, r)
+			return
+		}
+		if r.Header.Get("apikey") != apiKey {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/schemas/com.snowplowanalytics.snowplow/link_click/jsonschema/1":
+			w.Write([]byte(`["iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-0","iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1"]`))
+		case "/api/schemas/com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1":
+			w.Write(linkClickSchema)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestFSRepository(t *testing.T) {
+	assert := assert.New(t)
+	repository := NewFSRepository(RepositoryConfig{Name: "embedded"}, testRepository)
+	ctx := context.Background()
+
+	schema, err := repository.Lookup(ctx, linkClickKey)
+	assert.Nil(err)
+	assert.Equal(linkClickSchema, schema)
+
+	missing, err := repository.Lookup(ctx, SchemaKey{Vendor: "com.acme", Name: "missing", Format: "jsonschema", Version: SchemaVer{1, 0, 0}})
+	assert.ErrorIs(err, ErrNotFound)
+	assert.Nil(missing)
+
+	keys, err := repository.List(ctx, "com.snowplowanalytics.snowplow", "link_click", 1)
+	assert.Nil(err)
+	assert.ElementsMatch([]SchemaKey{{"com.snowplowanalytics.snowplow", "link_click", "jsonschema", SchemaVer{1, 0, 0}}, linkClickKey}, keys)
+
+	missingKeys, err := repository.List(ctx, "com.snowplowanalytics.snowplow", "link_click", 3)
+	assert.ErrorIs(err, ErrNotFound)
+	assert.Nil(missingKeys)
+}
+
+func TestHTTPRepository(t *testing.T) {
+	assert := assert.New(t)
+	var requests atomic.Int32
+	server := newTestServer("", &requests)
+	defer server.Close()
+	repository := NewHTTPRepository(RepositoryConfig{Name: "static"}, server.URL+"/", server.Client())
+	ctx := context.Background()
+
+	schema, err := repository.Lookup(ctx, linkClickKey)
+	assert.Nil(err)
+	assert.Equal(linkClickSchema, schema)
+
+	missing, err := repository.Lookup(ctx, SchemaKey{Vendor: "com.acme", Name: "missing", Format: "jsonschema", Version: SchemaVer{1, 0, 0}})
+	assert.ErrorIs(err, ErrNotFound)
+	assert.Nil(missing)
+
+	keys, err := repository.List(ctx, "com.snowplowanalytics.snowplow", "link_click", 1)
+	assert.ErrorIs(err, ErrListUnsupported)
+	assert.Nil(keys)
+}
+
+func TestServerRepository(t *testing.T) {
+	assert := assert.New(t)
+	var requests atomic.Int32
+	server := newTestServer("secret", &requests)
+	defer server.Close()
+	repository := NewServerRepository(RepositoryConfig{Name: "server"}, server.URL, "secret", server.Client())
+	ctx := context.Background()
+
+	schema, err := repository.Lookup(ctx, linkClickKey)
+	assert.Nil(err)
+	assert.Equal(linkClickSchema, schema)
+
+	keys, err := repository.List(ctx, "com.snowplowanalytics.snowplow", "link_click", 1)
+	assert.Nil(err)
+	assert.Equal([]SchemaKey{{"com.snowplowanalytics.snowplow", "link_click", "jsonschema", SchemaVer{1, 0, 0}}, linkClickKey}, keys)
+
+	missing, err := repository.Lookup(ctx, SchemaKey{Vendor: "com.acme", Name: "missing", Format: "jsonschema", Version: SchemaVer{1, 0, 0}})
+	assert.ErrorIs(err, ErrNotFound)
+	assert.Nil(missing)
+
+	// requests with the wrong API key fail, without being mistaken for missing schemas
+	unauthorized := NewServerRepository(RepositoryConfig{Name: "server"}, server.URL, "wrong", server.Client())
+	failed, err := unauthorized.Lookup(ctx, linkClickKey)
+	assert.NotNil(err)
+	assert.NotErrorIs(err, ErrNotFound)
+	assert.Nil(failed)
+}
@@ -0,0 +1,162 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package iglu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	defaultCacheSize int           = 500
+	defaultCacheTTL  time.Duration = 10 * time.Minute
+)
+
+// Resolver looks schemas up in an ordered list of repositories, caching the results.
+// A Resolver is safe for concurrent use.
+type Resolver struct {
+	repositories []Repository
+	cacheSize    int
+	cacheTTL     time.Duration
+	schemas      *cache[SchemaKey, lookupResult]
+	lists        *cache[listKey, []SchemaKey]
+}
+
+type lookupResult struct {
+	schema []byte
+	found  bool
+}
+
+type listKey struct {
+	vendor string
+	name   string
+	model  int
+}
+
+// ResolverOption configures a Resolver.
+type ResolverOption func(*Resolver)
+
+// WithCacheSize sets the number of lookups cached by the resolver, 500 by default. A size of 0 disables caching.
+func WithCacheSize(size int) ResolverOption {
+	return func(r *Resolver) {
+		r.cacheSize = size
+	}
+}
+
+// WithCacheTTL sets the time after which cached lookups expire, 10 minutes by default. A ttl of 0 keeps them until evicted.
+func WithCacheTTL(ttl time.Duration) ResolverOption {
+	return func(r *Resolver) {
+		r.cacheTTL = ttl
+	}
+}
+
+// NewResolver returns a resolver looking schemas up in the provided repositories.
+func NewResolver(repositories []Repository, opts ...ResolverOption) *Resolver {
+	r := &Resolver{
+		repositories: slices.Clone(repositories),
+		cacheSize:    defaultCacheSize,
+		cacheTTL:     defaultCacheTTL,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.schemas = newCache[SchemaKey, lookupResult](r.cacheSize, r.cacheTTL)
+	r.lists = newCache[listKey, []SchemaKey](r.cacheSize, r.cacheTTL)
+	return r
+}
+
+// repositoriesFor returns the repositories in lookup order for a vendor: repositories with a matching vendor prefix
+// first, then by priority, then in the order they were provided.
+func (r *Resolver) repositoriesFor(vendor string) []Repository {
+	ordered := slices.Clone(r.repositories)
+	slices.SortStableFunc(ordered, func(a, b Repository) int {
+		aConfig, bConfig := a.Config(), b.Config()
+		aMatches, bMatches := aConfig.matchesVendor(vendor), bConfig.matchesVendor(vendor)
+		if aMatches != bMatches {
+			if aMatches {
+				return -1
+			}
+			return 1
+		}
+		return aConfig.Priority - bConfig.Priority
+	})
+	return ordered
+}
+
+// Lookup returns the schema for a key from the first repository holding it. It returns an error wrapping ErrNotFound
+// if no repository holds the schema. Schemas found, and schemas found in no repository, are cached, and each call
+// returns its own copy of the schema, which callers may modify.
+func (r *Resolver) Lookup(ctx context.Context, key SchemaKey) ([]byte, error) {
+	if result, ok := r.schemas.get(key); ok {
+		if !result.found {
+			return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		return slices.Clone(result.schema), nil
+	}
+
+	var failures []error
+	for _, repository := range r.repositoriesFor(key.Vendor) {
+		schema, err := repository.Lookup(ctx, key)
+		if err == nil {
+			r.schemas.put(key, lookupResult{schema: schema, found: true})
+			return slices.Clone(schema), nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			failures = append(failures, err)
+		}
+	}
+	if len(failures) > 0 {
+		// a repository failing may hold the schema, so the failure is not cached
+		return nil, fmt.Errorf("cannot resolve %s: %w", key, errors.Join(failures...))
+	}
+	r.schemas.put(key, lookupResult{})
+	return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+}
+
+// ListSchemas returns the schemas of a model of a vendor's schema, from all repositories able to list them, ordered by version.
+func (r *Resolver) ListSchemas(ctx context.Context, vendor string, name string, model int) ([]SchemaKey, error) {
+	cacheKey := listKey{vendor: vendor, name: name, model: model}
+	if keys, ok := r.lists.get(cacheKey); ok {
+		return slices.Clone(keys), nil
+	}
+
+	var keys []SchemaKey
+	var failures []error
+	for _, repository := range r.repositoriesFor(vendor) {
+		listed, err := repository.List(ctx, vendor, name, model)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrListUnsupported) {
+				failures = append(failures, err)
+			}
+			continue
+		}
+		for _, key := range listed {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("cannot list iglu:%s/%s/jsonschema/%v-*-*: %w", vendor, name, model, errors.Join(failures...))
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("iglu:%s/%s/jsonschema/%v-*-*: %w", vendor, name, model, ErrNotFound)
+	}
+	slices.SortFunc(keys, SchemaKey.Compare)
+	r.lists.put(cacheKey, keys)
+	return slices.Clone(keys), nil
+}
@@ -0,0 +1,147 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package iglu
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolverLookup(t *testing.T) {
+	assert := assert.New(t)
+	var requests atomic.Int32
+	server := newTestServer("", &requests)
+	defer server.Close()
+	ctx := context.Background()
+
+	overriding := fstest.MapFS{"schemas/com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1": {Data: []byte(`{"overridden":true}`)}}
+	resolver := NewResolver([]Repository{
+		NewHTTPRepository(RepositoryConfig{Name: "static", Priority: 0}, server.URL, server.Client()),
+		NewFSRepository(RepositoryConfig{Name: "low priority", Priority: 10}, overriding),
+	})
+
+	// the repository with the lowest priority value is looked up first
+	schema, err := resolver.Lookup(ctx, linkClickKey)
+	assert.Nil(err)
+	assert.Equal(linkClickSchema, schema)
+	assert.Equal(int32(1), requests.Load())
+
+	// lookups are cached, and return copies of the cached schema
+	schema[0] = 'x'
+	schema, err = resolver.Lookup(ctx, linkClickKey)
+	assert.Nil(err)
+	assert.Equal(linkClickSchema, schema)
+	assert.Equal(int32(1), requests.Load())
+
+	// missing schemas are cached
+	missingKey := SchemaKey{Vendor: "com.acme", Name: "missing", Format: "jsonschema", Version: SchemaVer{1, 0, 0}}
+	for range 2 {
+		missing, err := resolver.Lookup(ctx, missingKey)
+		assert.ErrorIs(err, ErrNotFound)
+		assert.Nil(missing)
+	}
+	assert.Equal(int32(2), requests.Load())
+
+	// repositories with a matching vendor prefix are looked up first
+	prefixed := NewResolver([]Repository{
+		NewHTTPRepository(RepositoryConfig{Name: "static", Priority: 0}, server.URL, server.Client()),
+		NewFSRepository(RepositoryConfig{Name: "vendor", Priority: 10, VendorPrefixes: []string{"com.snowplowanalytics"}}, overriding),
+	})
+	schema, err = prefixed.Lookup(ctx, linkClickKey)
+	assert.Nil(err)
+	assert.Equal([]byte(`{"overridden":true}`), schema)
+	assert.Equal(int32(2), requests.Load())
+}
+
+func BenchmarkResolverLookup(b *testing.B) {
+	resolver := NewResolver([]Repository{NewFSRepository(RepositoryConfig{Name: "embedded"}, testRepository)})
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		resolver.Lookup(ctx, linkClickKey)
+	}
+}
+
+func TestResolverLookupFailures(t *testing.T) {
+	assert := assert.New(t)
+	var requests atomic.Int32
+	server := newTestServer("secret", &requests)
+	defer server.Close()
+	ctx := context.Background()
+
+	resolver := NewResolver([]Repository{
+		NewServerRepository(RepositoryConfig{Name: "unauthorized"}, server.URL, "wrong", server.Client()),
+		NewFSRepository(RepositoryConfig{Name: "empty", Priority: 1}, fstest.MapFS{}),
+	}, WithCacheTTL(time.Hour))
+
+	// failures are not cached, and are not reported as missing schemas
+	for range 2 {
+		schema, err := resolver.Lookup(ctx, linkClickKey)
+		assert.NotNil(err)
+		assert.NotErrorIs(err, ErrNotFound)
+		assert.Nil(schema)
+	}
+	assert.Equal(int32(2), requests.Load())
+
+	// a failing repository does not prevent other repositories from resolving the schema
+	fallback := NewResolver([]Repository{
+		NewServerRepository(RepositoryConfig{Name: "unauthorized"}, server.URL, "wrong", server.Client()),
+		NewFSRepository(RepositoryConfig{Name: "embedded", Priority: 1}, testRepository),
+	}, WithCacheSize(0))
+	schema, err := fallback.Lookup(ctx, linkClickKey)
+	assert.Nil(err)
+	assert.Equal(linkClickSchema, schema)
+}
+
+func TestResolverListSchemas(t *testing.T) {
+	assert := assert.New(t)
+	var requests atomic.Int32
+	server := newTestServer("secret", &requests)
+	defer server.Close()
+	ctx := context.Background()
+
+	resolver := NewResolver([]Repository{
+		NewHTTPRepository(RepositoryConfig{Name: "static"}, server.URL, server.Client()),
+		NewServerRepository(RepositoryConfig{Name: "server"}, server.URL, "secret", server.Client()),
+		NewFSRepository(RepositoryConfig{Name: "embedded"}, fstest.MapFS{
+			"schemas/com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-10": {Data: []byte(`{}`)},
+			"schemas/com.snowplowanalytics.snowplow/link_click/jsonschema/1-1-0":  {Data: []byte(`{}`)},
+		}),
+	})
+
+	expected := []SchemaKey{
+		{"com.snowplowanalytics.snowplow", "link_click", "jsonschema", SchemaVer{1, 0, 0}},
+		linkClickKey,
+		{"com.snowplowanalytics.snowplow", "link_click", "jsonschema", SchemaVer{1, 0, 10}},
+		{"com.snowplowanalytics.snowplow", "link_click", "jsonschema", SchemaVer{1, 1, 0}},
+	}
+	keys, err := resolver.ListSchemas(ctx, "com.snowplowanalytics.snowplow", "link_click", 1)
+	assert.Nil(err)
+	assert.Equal(expected, keys)
+	assert.Equal(int32(1), requests.Load())
+
+	// lists are cached
+	keys, err = resolver.ListSchemas(ctx, "com.snowplowanalytics.snowplow", "link_click", 1)
+	assert.Nil(err)
+	assert.Equal(expected, keys)
+	assert.Equal(int32(1), requests.Load())
+
+	missing, err := resolver.ListSchemas(ctx, "com.acme", "missing", 1)
+	assert.ErrorIs(err, ErrNotFound)
+	assert.Nil(missing)
+}
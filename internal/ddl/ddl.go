@@ -0,0 +1,211 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+// Package ddl converts the JSON Schemas of self-describing data to the column types used by columnar and binary
+// output formats, following the conventions of the Snowplow loaders.
+package ddl
+
+import (
+	"cmp"
+	stdjson "encoding/json"
+	"fmt"
+	"slices"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// json decodes numbers as encoding/json Numbers, to tell integers from other numbers in enums.
+var json = jsoniter.Config{UseNumber: true}.Froze()
+
+// maxDepth bounds the nesting of converted schemas. Deeper schemas are kept as JSON.
+const maxDepth int = 32
+
+// Kind is the kind of a Type.
+type Kind uint8
+
+const (
+	// JSON values have no single type, and are kept as JSON text.
+	JSON Kind = iota
+	String
+	Integer
+	Number
+	Boolean
+	Object
+	Array
+)
+
+var kindNames = [...]string{"json", "string", "integer", "number", "boolean", "object", "array"}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%v)", uint8(k))
+}
+
+// Type is the type of the values valid against a JSON Schema.
+type Type struct {
+	Kind Kind
+	// Fields holds the properties of Object types, sorted by name.
+	Fields []Field
+	// Items holds the type of the items of Array types.
+	Items *Type
+}
+
+// Field is a property of an Object type.
+type Field struct {
+	Name string
+	Type *Type
+	// Nullable is true for properties which are not required, or which accept null.
+	Nullable bool
+}
+
+// FromJSONSchema converts a JSON Schema document to the Type of its values.
+func FromJSONSchema(schema []byte) (*Type, error) {
+	var node map[string]any
+	if err := json.Unmarshal(schema, &node); err != nil {
+		return nil, fmt.Errorf("cannot parse JSON Schema: %w", err)
+	}
+	t, _ := convert(node, 0)
+	return t, nil
+}
+
+// convert returns the type of a schema node, and whether it accepts null.
+func convert(node map[string]any, depth int) (*Type, bool) {
+	types, nullable := schemaTypes(node)
+	if depth >= maxDepth || node["$ref"] != nil || node["oneOf"] != nil || node["anyOf"] != nil || node["allOf"] != nil {
+		return &Type{Kind: JSON}, true
+	}
+	if len(types) == 0 {
+		if enum, ok := node["enum"].([]any); ok {
+			return enumType(enum)
+		}
+		if _, ok := node["properties"].(map[string]any); ok {
+			types = []string{"object"}
+		} else {
+			return &Type{Kind: JSON}, true
+		}
+	}
+	if len(types) == 2 && slices.Contains(types, "integer") && slices.Contains(types, "number") {
+		types = []string{"number"}
+	}
+	if len(types) > 1 {
+		return &Type{Kind: JSON}, nullable
+	}
+
+	switch types[0] {
+	case "string":
+		return &Type{Kind: String}, nullable
+	case "integer":
+		return &Type{Kind: Integer}, nullable
+	case "number":
+		return &Type{Kind: Number}, nullable
+	case "boolean":
+		return &Type{Kind: Boolean}, nullable
+	case "array":
+		items, ok := node["items"].(map[string]any)
+		if !ok {
+			return &Type{Kind: JSON}, nullable
+		}
+		itemType, _ := convert(items, depth+1)
+		return &Type{Kind: Array, Items: itemType}, nullable
+	case "object":
+		properties, ok := node["properties"].(map[string]any)
+		if !ok || len(properties) == 0 {
+			return &Type{Kind: JSON}, nullable
+		}
+		required := make(map[string]bool)
+		if names, ok := node["required"].([]any); ok {
+			for _, name := range names {
+				if name, ok := name.(string); ok {
+					required[name] = true
+				}
+			}
+		}
+		fields := make([]Field, 0, len(properties))
+		for name, property := range properties {
+			propertyNode, ok := property.(map[string]any)
+			if !ok {
+				propertyNode = map[string]any{}
+			}
+			fieldType, fieldNullable := convert(propertyNode, depth+1)
+			fields = append(fields, Field{Name: name, Type: fieldType, Nullable: fieldNullable || !required[name]})
+		}
+		slices.SortFunc(fields, func(a, b Field) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
+		return &Type{Kind: Object, Fields: fields}, nullable
+	default:
+		return &Type{Kind: JSON}, true
+	}
+}
+
+// schemaTypes returns the types of a schema node other than null, and whether null is one of them.
+func schemaTypes(node map[string]any) ([]string, bool) {
+	switch t := node["type"].(type) {
+	case string:
+		if t == "null" {
+			return nil, true
+		}
+		return []string{t}, false
+	case []any:
+		var types []string
+		nullable := false
+		for _, value := range t {
+			name, _ := value.(string)
+			if name == "null" {
+				nullable = true
+			} else if !slices.Contains(types, name) {
+				types = append(types, name)
+			}
+		}
+		return types, nullable
+	default:
+		return nil, false
+	}
+}
+
+// enumType returns the type of an enum without a type, from the types of its values.
+func enumType(values []any) (*Type, bool) {
+	kind := JSON
+	nullable := false
+	for _, value := range values {
+		var valueKind Kind
+		switch v := value.(type) {
+		case nil:
+			nullable = true
+			continue
+		case string:
+			valueKind = String
+		case bool:
+			valueKind = Boolean
+		case stdjson.Number:
+			valueKind = Number
+			if _, err := v.Int64(); err == nil {
+				valueKind = Integer
+			}
+		default:
+			return &Type{Kind: JSON}, true
+		}
+		switch {
+		case kind == JSON:
+			kind = valueKind
+		case kind == valueKind:
+		case (kind == Integer && valueKind == Number) || (kind == Number && valueKind == Integer):
+			kind = Number
+		default:
+			return &Type{Kind: JSON}, true
+		}
+	}
+	return &Type{Kind: kind}, nullable || kind == JSON
+}
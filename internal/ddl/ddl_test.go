@@ -0,0 +1,128 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package ddl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSchema = []byte(`{
+	"$schema": "http://iglu.snowplowanalytics.com/schemas/com.snowplowanalytics.self-desc/schema/jsonschema/1-0-0#",
+	"self": {"vendor": "com.acme", "name": "test", "format": "jsonschema", "version": "1-0-0"},
+	"type": "object",
+	"properties": {
+		"name": {"type": "string"},
+		"count": {"type": "integer"},
+		"ratio": {"type": ["number", "integer", "null"]},
+		"enabled": {"type": "boolean"},
+		"tags": {"type": "array", "items": {"type": "string"}},
+		"anything": {},
+		"mixed": {"type": ["string", "integer"]},
+		"size": {"enum": ["small", "large", null]},
+		"nested": {"type": "object", "properties": {"id": {"type": "string"}}, "required": ["id"]},
+		"freeform": {"type": "object"},
+		"choice": {"oneOf": [{"type": "string"}, {"type": "integer"}]}
+	},
+	"required": ["name", "count", "enabled", "nested"],
+	"additionalProperties": false
+}`)
+
+func TestFromJSONSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	converted, err := FromJSONSchema(testSchema)
+	assert.Nil(err)
+	assert.Equal(&Type{Kind: Object, Fields: []Field{
+		{Name: "anything", Type: &Type{Kind: JSON}, Nullable: true},
+		{Name: "choice", Type: &Type{Kind: JSON}, Nullable: true},
+		{Name: "count", Type: &Type{Kind: Integer}},
+		{Name: "enabled", Type: &Type{Kind: Boolean}},
+		{Name: "freeform", Type: &Type{Kind: JSON}, Nullable: true},
+		{Name: "mixed", Type: &Type{Kind: JSON}, Nullable: true},
+		{Name: "name", Type: &Type{Kind: String}},
+		{Name: "nested", Type: &Type{Kind: Object, Fields: []Field{{Name: "id", Type: &Type{Kind: String}}}}},
+		{Name: "ratio", Type: &Type{Kind: Number}, Nullable: true},
+		{Name: "size", Type: &Type{Kind: String}, Nullable: true},
+		{Name: "tags", Type: &Type{Kind: Array, Items: &Type{Kind: String}}, Nullable: true},
+	}}, converted)
+
+	// enums of numbers
+	numbers, err := FromJSONSchema([]byte(`{"enum": [1, 2.5]}`))
+	assert.Nil(err)
+	assert.Equal(&Type{Kind: Number}, numbers)
+
+	// invalid JSON
+	failed, err := FromJSONSchema([]byte(`{"type":`))
+	assert.NotNil(err)
+	assert.Nil(failed)
+}
+
+func BenchmarkFromJSONSchema(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FromJSONSchema(testSchema)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	assert := assert.New(t)
+	converted, _ := FromJSONSchema(testSchema)
+
+	normalized, err := Normalize(converted, map[string]any{
+		"name":     "test",
+		"count":    2.0,
+		"ratio":    1.0,
+		"enabled":  true,
+		"tags":     []any{"a", "b"},
+		"anything": map[string]any{"b": 1.0, "a": []any{"x"}},
+		"mixed":    "text",
+		"nested":   map[string]any{"id": "id", "ignored": true},
+	})
+	assert.Nil(err)
+	assert.Equal(map[string]any{
+		"anything": `{"a":["x"],"b":1}`,
+		"choice":   nil,
+		"count":    int64(2),
+		"enabled":  true,
+		"freeform": nil,
+		"mixed":    `"text"`,
+		"name":     "test",
+		"nested":   map[string]any{"id": "id"},
+		"ratio":    1.0,
+		"size":     nil,
+		"tags":     []any{"a", "b"},
+	}, normalized)
+
+	for _, invalid := range []map[string]any{
+		// missing required field
+		{"name": "test", "count": 2.0, "enabled": true},
+		// wrong type
+		{"name": "test", "count": 2.5, "enabled": true, "nested": map[string]any{"id": "id"}},
+		{"name": "test", "count": 2.0, "enabled": true, "nested": map[string]any{"id": 1.0}},
+		{"name": "test", "count": 2.0, "enabled": true, "nested": map[string]any{"id": "id"}, "tags": []any{1.0}},
+	} {
+		failed, err := Normalize(converted, invalid)
+		assert.NotNil(err)
+		assert.Nil(failed)
+	}
+}
+
+func BenchmarkNormalize(b *testing.B) {
+	converted, _ := FromJSONSchema(testSchema)
+	value := map[string]any{"name": "test", "count": 2.0, "enabled": true, "nested": map[string]any{"id": "id"}, "tags": []any{"a", "b"}}
+	for i := 0; i < b.N; i++ {
+		Normalize(converted, value)
+	}
+}
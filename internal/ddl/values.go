@@ -0,0 +1,112 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package ddl
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// jsonText encodes JSON values kept as text, with sorted keys so that equal values produce equal text.
+var jsonText = jsoniter.Config{SortMapKeys: true}.Froze()
+
+// Normalize converts a decoded JSON value to the Go representation of its type: string for String and JSON,
+// int64 for Integer, float64 for Number, bool for Boolean, map[string]any holding every field for Object,
+// and []any for Array. Missing and null values are nil. It returns an error for values not of the type,
+// including null values of fields which are not nullable.
+func Normalize(t *Type, value any) (any, error) {
+	return normalize(t, value, "")
+}
+
+func normalize(t *Type, value any, path string) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch t.Kind {
+	case JSON:
+		text, err := jsonText.MarshalToString(value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode value at '%s' as JSON: %w", path, err)
+		}
+		return text, nil
+	case String:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case Integer:
+		switch v := value.(type) {
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) <= 1<<53 {
+				return int64(v), nil
+			}
+		case int:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		case stdjson.Number:
+			if i, err := v.Int64(); err == nil {
+				return i, nil
+			}
+		}
+	case Number:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case stdjson.Number:
+			if f, err := v.Float64(); err == nil {
+				return f, nil
+			}
+		}
+	case Boolean:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case Array:
+		if items, ok := value.([]any); ok {
+			normalized := make([]any, len(items))
+			for i, item := range items {
+				var err error
+				if normalized[i], err = normalize(t.Items, item, path+"/"+strconv.Itoa(i)); err != nil {
+					return nil, err
+				}
+			}
+			return normalized, nil
+		}
+	case Object:
+		if properties, ok := value.(map[string]any); ok {
+			normalized := make(map[string]any, len(t.Fields))
+			for _, field := range t.Fields {
+				fieldPath := path + "/" + field.Name
+				fieldValue, err := normalize(field.Type, properties[field.Name], fieldPath)
+				if err != nil {
+					return nil, err
+				}
+				if fieldValue == nil && !field.Nullable {
+					return nil, fmt.Errorf("missing value at '%s'", fieldPath)
+				}
+				normalized[field.Name] = fieldValue
+			}
+			return normalized, nil
+		}
+	}
+	return nil, fmt.Errorf("value at '%s' is not of type %s", path, t.Kind)
+}
@@ -0,0 +1,151 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+// Package parquet writes enriched events to Parquet files, with typed atomic columns and nested columns for the
+// shredded contexts and unstruct events of selected schemas.
+package parquet
+
+import (
+	"fmt"
+	"io"
+
+	pq "github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/analytics"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/arrow"
+)
+
+const defaultRowGroupSize int = 100000
+
+type config struct {
+	rowGroupSize int
+	builderOpts  []arrow.Option
+}
+
+// Option configures a ParquetWriter.
+type Option func(*config)
+
+// WithRowGroupSize sets the number of events written to each row group, 100000 by default.
+func WithRowGroupSize(rows int) Option {
+	return func(c *config) {
+		c.rowGroupSize = rows
+	}
+}
+
+// WithLayout sets the layout whose atomic fields are written as columns, analytics.LayoutCurrent by default.
+// Events of layouts lacking some of these fields are written with null values for them.
+func WithLayout(layout *analytics.Layout) Option {
+	return func(c *config) {
+		c.builderOpts = append(c.builderOpts, arrow.WithLayout(layout))
+	}
+}
+
+// WithContextSchema adds a column for the contexts and derived contexts of the schema's model, named as by
+// analytics.ContextsKey, such as contexts_com_acme_my_context_1. The column is a list of structs with the properties
+// of the JSON Schema, which should be the latest schema of the model. As in ToMap, a context found in both contexts
+// and derived_contexts takes its value from derived_contexts.
+func WithContextSchema(uri string, schema []byte) Option {
+	return func(c *config) {
+		c.builderOpts = append(c.builderOpts, arrow.WithContextSchema(uri, schema))
+	}
+}
+
+// WithUnstructEventSchema adds a column for the unstruct events of the schema's model, named as by
+// analytics.UnstructEventKey, such as unstruct_event_com_acme_my_event_1. The column is a struct with the properties
+// of the JSON Schema, which should be the latest schema of the model.
+func WithUnstructEventSchema(uri string, schema []byte) Option {
+	return func(c *config) {
+		c.builderOpts = append(c.builderOpts, arrow.WithUnstructEventSchema(uri, schema))
+	}
+}
+
+// ParquetWriter writes enriched events to a Parquet file. Contexts and unstruct events are only written for the
+// schemas provided with WithContextSchema and WithUnstructEventSchema. A ParquetWriter is not safe for concurrent use.
+type ParquetWriter struct {
+	builder *arrow.Builder
+	file    *pqarrow.FileWriter
+}
+
+// uncloseableWriter prevents the Parquet file writer from closing the output, which belongs to the caller.
+type uncloseableWriter struct {
+	io.Writer
+}
+
+// NewParquetWriter returns a ParquetWriter writing to w. Close must be called to write the file footer; it does not close w.
+func NewParquetWriter(w io.Writer, opts ...Option) (*ParquetWriter, error) {
+	c := config{rowGroupSize: defaultRowGroupSize}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.rowGroupSize <= 0 {
+		return nil, fmt.Errorf("cannot create parquet writer: row group size must be positive, got %v", c.rowGroupSize)
+	}
+
+	builder, err := arrow.NewBuilder(append(c.builderOpts, arrow.WithBatchSize(c.rowGroupSize))...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create parquet writer: %w", err)
+	}
+	props := pq.NewWriterProperties(
+		pq.WithMaxRowGroupLength(int64(c.rowGroupSize)),
+		pq.WithCompression(compress.Codecs.Snappy),
+	)
+	file, err := pqarrow.NewFileWriter(builder.Schema(), uncloseableWriter{w}, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		builder.Release()
+		return nil, fmt.Errorf("cannot create parquet writer: %w", err)
+	}
+	return &ParquetWriter{builder: builder, file: file}, nil
+}
+
+// Write adds an event to the current row group, which is written once it holds the configured number of events.
+// An event failing to convert is not written, and the writer remains usable.
+func (w *ParquetWriter) Write(event analytics.ParsedEvent) error {
+	record, err := w.builder.Append(event)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return nil
+	}
+	defer record.Release()
+	if err := w.file.Write(record); err != nil {
+		return fmt.Errorf("cannot write row group: %w", err)
+	}
+	return nil
+}
+
+// Flush writes the events added since the last row group as a new row group.
+func (w *ParquetWriter) Flush() error {
+	record := w.builder.Flush()
+	if record == nil {
+		return nil
+	}
+	defer record.Release()
+	if err := w.file.Write(record); err != nil {
+		return fmt.Errorf("cannot write row group: %w", err)
+	}
+	return nil
+}
+
+// Close flushes the remaining events and writes the file footer.
+func (w *ParquetWriter) Close() error {
+	defer w.builder.Release()
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("cannot close parquet writer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package parquet
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/analytics"
+	"github.com/stretchr/testify/assert"
+)
+
+var collectorTstamp = time.Date(2013, 11, 26, 0, 3, 57, 885000000, time.UTC)
+
+var linkClickSchema = []byte(`{"type":"object","properties":{"targetUrl":{"type":"string"},"elementClasses":{"type":["array","null"],"items":{"type":"string"}}},"required":["targetUrl"]}`)
+
+var webPageSchema = []byte(`{"type":"object","properties":{"id":{"type":"string"},"position":{"type":"integer"}},"required":["id"]}`)
+
+func newTestEvent(t testing.TB, fields map[string]any) analytics.ParsedEvent {
+	base := map[string]any{
+		"app_id":           "angry-birds",
+		"collector_tstamp": collectorTstamp,
+		"event_id":         "c6ef3124-b53a-4b13-a233-0088f79dcbcb",
+		"v_collector":      "clj-tomcat-0.1.0",
+		"v_etl":            "serde-0.5.2",
+		"txn_id":           41828,
+		"geo_latitude":     37.443604,
+		"br_cookies":       true,
+	}
+	for key, value := range fields {
+		base[key] = value
+	}
+	event, err := analytics.FromMap(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+var linkClickEvent = `{"schema":"iglu:com.snowplowanalytics.snowplow/unstruct_event/jsonschema/1-0-0","data":{"schema":"iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1","data":{"targetUrl":"http://www.example.com","elementClasses":["foreground"]}}}`
+
+var webPageContexts = `{"schema":"iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-0","data":[{"schema":"iglu:com.snowplowanalytics.snowplow/web_page/jsonschema/1-0-0","data":{"id":"first","position":1}},{"schema":"iglu:com.snowplowanalytics.snowplow/web_page/jsonschema/1-0-0","data":{"id":"second"}},{"schema":"iglu:com.acme/ignored/jsonschema/1-0-0","data":{}}]}`
+
+func readTable(t *testing.T, data []byte) arrow.Table {
+	reader, err := file.NewParquetReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	arrowReader, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := arrowReader.ReadTable(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func columnOf(table arrow.Table, name string) arrow.Array {
+	indices := table.Schema().FieldIndices(name)
+	return table.Column(indices[0]).Data().Chunk(0)
+}
+
+func TestParquetWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	var output bytes.Buffer
+	writer, err := NewParquetWriter(&output,
+		WithRowGroupSize(2),
+		WithContextSchema("iglu:com.snowplowanalytics.snowplow/web_page/jsonschema/1-0-0", webPageSchema),
+		WithUnstructEventSchema("iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1", linkClickSchema),
+	)
+	assert.Nil(err)
+
+	assert.Nil(writer.Write(newTestEvent(t, map[string]any{"unstruct_event": linkClickEvent, "contexts": webPageContexts})))
+	assert.Nil(writer.Write(newTestEvent(t, map[string]any{"app_id": ""})))
+	// events failing to convert are not written
+	invalid := newTestEvent(t, map[string]any{"contexts": `{"schema":"iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-0","data":[{"schema":"iglu:com.snowplowanalytics.snowplow/web_page/jsonschema/1-0-0","data":{"position":1}}]}`})
+	assert.NotNil(writer.Write(invalid))
+	assert.NotNil(writer.Write(analytics.ParsedEvent{"one", "two"}))
+	assert.Nil(writer.Write(newTestEvent(t, nil)))
+	assert.Nil(writer.Close())
+
+	// metadata
+	reader, err := file.NewParquetReader(bytes.NewReader(output.Bytes()))
+	assert.Nil(err)
+	assert.Equal(2, reader.NumRowGroups())
+	assert.Equal(int64(3), reader.NumRows())
+	tstampColumn := reader.MetaData().Schema.ColumnIndexByName("collector_tstamp")
+	assert.Equal(schema.NewTimestampLogicalType(true, schema.TimeUnitMicros), reader.MetaData().Schema.Column(tstampColumn).LogicalType())
+
+	// values
+	table := readTable(t, output.Bytes())
+	defer table.Release()
+	assert.Equal(int64(3), table.NumRows())
+	assert.False(table.Schema().HasField("contexts"))
+	assert.False(table.Schema().HasField("unstruct_event"))
+
+	appID := columnOf(table, "app_id").(*array.String)
+	assert.Equal("angry-birds", appID.Value(0))
+	assert.True(appID.IsNull(1))
+	assert.Equal(int64(41828), columnOf(table, "txn_id").(*array.Int64).Value(0))
+	assert.Equal(37.443604, columnOf(table, "geo_latitude").(*array.Float64).Value(0))
+	assert.True(columnOf(table, "br_cookies").(*array.Boolean).Value(0))
+	assert.True(columnOf(table, "true_tstamp").IsNull(0))
+	tstamps := columnOf(table, "collector_tstamp").(*array.Timestamp)
+	assert.Equal(collectorTstamp, tstamps.Value(0).ToTime(arrow.Microsecond))
+
+	unstruct := columnOf(table, "unstruct_event_com_snowplowanalytics_snowplow_link_click_1").(*array.Struct)
+	unstructJson, err := unstruct.MarshalJSON()
+	assert.Nil(err)
+	assert.JSONEq(`[{"elementClasses":["foreground"],"targetUrl":"http://www.example.com"},null,null]`, string(unstructJson))
+
+	contexts := columnOf(table, "contexts_com_snowplowanalytics_snowplow_web_page_1").(*array.List)
+	contextsJson, err := contexts.MarshalJSON()
+	assert.Nil(err)
+	assert.JSONEq(`[[{"id":"first","position":1},{"id":"second","position":null}],null,null]`, string(contextsJson))
+}
+
+func BenchmarkParquetWriterWrite(b *testing.B) {
+	var output bytes.Buffer
+	writer, _ := NewParquetWriter(&output,
+		WithContextSchema("iglu:com.snowplowanalytics.snowplow/web_page/jsonschema/1-0-0", webPageSchema),
+		WithUnstructEventSchema("iglu:com.snowplowanalytics.snowplow/link_click/jsonschema/1-0-1", linkClickSchema),
+	)
+	event := newTestEvent(b, map[string]any{"unstruct_event": linkClickEvent, "contexts": webPageContexts})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		writer.Write(event)
+	}
+	writer.Close()
+}
+
+func TestNewParquetWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	// older layouts
+	var output bytes.Buffer
+	writer, err := NewParquetWriter(&output, WithLayout(analytics.LayoutNoDerivedFields))
+	assert.Nil(err)
+	assert.Nil(writer.Write(newTestEvent(t, nil)))
+	assert.Nil(writer.Close())
+	table := readTable(t, output.Bytes())
+	defer table.Release()
+	assert.Equal(analytics.LayoutNoDerivedFields.Len()-2, int(table.NumCols()))
+
+	for _, opts := range [][]Option{
+		{WithRowGroupSize(0)},
+		{WithContextSchema("not a schema", webPageSchema)},
+		{WithContextSchema("iglu:com.acme/context/jsonschema/1-0-0", []byte(`{"type":`))},
+		{WithContextSchema("iglu:com.acme/context/jsonschema/1-0-0", webPageSchema), WithContextSchema("iglu:com.acme/context/jsonschema/1-0-1", webPageSchema)},
+	} {
+		failed, err := NewParquetWriter(&output, opts...)
+		assert.NotNil(err)
+		assert.Nil(failed)
+	}
+}
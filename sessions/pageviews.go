@@ -0,0 +1,216 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package sessions
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"slices"
+	"time"
+
+	"github.com/snowplow/snowplow-golang-analytics-sdk/analytics"
+)
+
+// pageViewFields are the atomic fields read from page views and page pings.
+var pageViewFields = []string{
+	"event", "event_id", "domain_userid", "domain_sessionid", "derived_tstamp", "collector_tstamp",
+	"page_url", "page_urlpath", "page_title", "refr_urlhost", "refr_urlpath", "refr_medium", "refr_source", "refr_term",
+	"pp_xoffset_max", "pp_yoffset_max", "br_viewwidth", "br_viewheight", "doc_width", "doc_height",
+}
+
+// PageView aggregates a page view and its page pings.
+type PageView struct {
+	// PageViewID is the id of the web page context of the page view and its page pings.
+	PageViewID string
+	EventID    string
+	UserID     string
+	SessionID  string
+	// Start is the derived_tstamp of the page view, and End that of its last page ping, or collector_tstamp for
+	// layouts without derived_tstamp.
+	Start       time.Time
+	End         time.Time
+	PageURL     string
+	PageURLPath string
+	PageTitle   string
+	Referrer    Referrer
+	PagePings   int
+	// EngagedTime is the time the user was engaged with the page, estimated from page pings.
+	EngagedTime time.Duration
+	// HorizontalPixelsScrolled and VerticalPixelsScrolled are the maximum offsets of the page pings.
+	HorizontalPixelsScrolled int
+	VerticalPixelsScrolled   int
+	// HorizontalPercentageScrolled and VerticalPercentageScrolled are the percentages of the document seen at the
+	// maximum offsets, given the size of the browser viewport. They are 0 for page views without a document size.
+	HorizontalPercentageScrolled int
+	VerticalPercentageScrolled   int
+	DocWidth                     int
+	DocHeight                    int
+	ViewWidth                    int
+	ViewHeight                   int
+}
+
+type pageViewState struct {
+	view  *PageView
+	pings []time.Time
+	xmax  int
+	ymax  int
+	end   time.Time
+}
+
+// PageViewAggregator joins page pings to their page views through the id of their web page context. Events may be
+// added in any order. A PageViewAggregator is not safe for concurrent use.
+type PageViewAggregator struct {
+	config config
+	views  map[string]*pageViewState
+}
+
+// NewPageViewAggregator returns a PageViewAggregator for the provided options.
+func NewPageViewAggregator(opts ...Option) (*PageViewAggregator, error) {
+	c, err := newConfig(opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create page view aggregator: %w", err)
+	}
+	return &PageViewAggregator{config: c, views: make(map[string]*pageViewState)}, nil
+}
+
+// Add adds a page view or a page ping. Other events, and events without a web page context, are ignored.
+func (a *PageViewAggregator) Add(event analytics.ParsedEvent) error {
+	kinds, err := event.GetSubsetMap("event")
+	if err != nil {
+		return fmt.Errorf("cannot aggregate page view: %w", err)
+	}
+	kind := stringOf(kinds, "event")
+	if kind != "page_view" && kind != "page_ping" {
+		return nil
+	}
+	id, err := pageViewIDOf(event)
+	if err != nil {
+		return fmt.Errorf("cannot aggregate page view: %w", err)
+	}
+	if id == "" {
+		return nil
+	}
+	fields, err := event.GetSubsetMap(pageViewFields...)
+	if err != nil {
+		return fmt.Errorf("cannot aggregate page view: %w", err)
+	}
+	tstamp, err := tstampOf(fields)
+	if err != nil {
+		return fmt.Errorf("cannot aggregate page view: %w", err)
+	}
+
+	state, ok := a.views[id]
+	if !ok {
+		state = &pageViewState{}
+		a.views[id] = state
+	}
+	if tstamp.After(state.end) {
+		state.end = tstamp
+	}
+	if kind == "page_view" {
+		state.view = &PageView{
+			PageViewID:  id,
+			EventID:     stringOf(fields, "event_id"),
+			UserID:      stringOf(fields, "domain_userid"),
+			SessionID:   stringOf(fields, "domain_sessionid"),
+			Start:       tstamp,
+			PageURL:     stringOf(fields, "page_url"),
+			PageURLPath: stringOf(fields, "page_urlpath"),
+			PageTitle:   stringOf(fields, "page_title"),
+			Referrer:    referrerOf(fields),
+			DocWidth:    intOf(fields, "doc_width"),
+			DocHeight:   intOf(fields, "doc_height"),
+			ViewWidth:   intOf(fields, "br_viewwidth"),
+			ViewHeight:  intOf(fields, "br_viewheight"),
+		}
+		return nil
+	}
+	state.pings = append(state.pings, tstamp)
+	state.xmax = max(state.xmax, intOf(fields, "pp_xoffset_max"))
+	state.ymax = max(state.ymax, intOf(fields, "pp_yoffset_max"))
+	return nil
+}
+
+// PageViews returns the page views added so far, with their page pings, ordered by start and page view id.
+// Page pings whose page view was not added are left out.
+func (a *PageViewAggregator) PageViews() []PageView {
+	var views []PageView
+	for _, state := range a.views {
+		if state.view == nil {
+			continue
+		}
+		view := *state.view
+		view.End = state.end
+		view.PagePings = len(state.pings)
+		view.EngagedTime = engagedTime(state.pings, a.config)
+		view.HorizontalPixelsScrolled = state.xmax
+		view.VerticalPixelsScrolled = state.ymax
+		view.HorizontalPercentageScrolled = percentageScrolled(state.xmax, view.ViewWidth, view.DocWidth)
+		view.VerticalPercentageScrolled = percentageScrolled(state.ymax, view.ViewHeight, view.DocHeight)
+		views = append(views, view)
+	}
+	slices.SortFunc(views, func(a, b PageView) int {
+		return cmp.Or(a.Start.Compare(b.Start), cmp.Compare(a.PageViewID, b.PageViewID))
+	})
+	return views
+}
+
+// percentageScrolled returns the percentage of a document seen, from the maximum offset of the viewport, at most 100.
+func percentageScrolled(offset int, viewport int, document int) int {
+	if document <= 0 {
+		return 0
+	}
+	return min(100, 100*(offset+viewport)/document)
+}
+
+// AggregatePageViews aggregates a stream of events, such as the events of an analytics.Reader, into page views, and
+// yields them once the stream is consumed. Iteration stops at the first error, either of the stream or of an event.
+func AggregatePageViews(events iter.Seq2[analytics.ParsedEvent, error], opts ...Option) iter.Seq2[PageView, error] {
+	return func(yield func(PageView, error) bool) {
+		aggregator, err := NewPageViewAggregator(opts...)
+		if err != nil {
+			yield(PageView{}, err)
+			return
+		}
+		for event, err := range events {
+			if err == nil {
+				err = aggregator.Add(event)
+			}
+			if err != nil {
+				yield(PageView{}, err)
+				return
+			}
+		}
+		for _, view := range aggregator.PageViews() {
+			if !yield(view, nil) {
+				return
+			}
+		}
+	}
+}
+
+// PageViewsFromEvents aggregates a slice of events into page views.
+func PageViewsFromEvents(events []analytics.ParsedEvent, opts ...Option) ([]PageView, error) {
+	aggregator, err := NewPageViewAggregator(opts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		if err := aggregator.Add(event); err != nil {
+			return nil, err
+		}
+	}
+	return aggregator.PageViews(), nil
+}
@@ -0,0 +1,130 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package sessions
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/snowplow/snowplow-golang-analytics-sdk/analytics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageViewAggregator(t *testing.T) {
+	assert := assert.New(t)
+
+	pageView := map[string]any{
+		"event":            "page_view",
+		"domain_sessionid": "s1",
+		"page_url":         "http://www.example.com/landing",
+		"page_urlpath":     "/landing",
+		"page_title":       "Landing",
+		"refr_urlhost":     "www.google.com",
+		"refr_medium":      "search",
+		"br_viewwidth":     1000,
+		"br_viewheight":    500,
+		"doc_width":        1000,
+		"doc_height":       2000,
+		"contexts":         webPage("pv1"),
+	}
+	ping := func(offset time.Duration, x int, y int) analytics.ParsedEvent {
+		return newTestEvent(t, offset, map[string]any{"event": "page_ping", "pp_xoffset_max": x, "pp_yoffset_max": y, "contexts": webPage("pv1")})
+	}
+	events := []analytics.ParsedEvent{
+		// page pings added before their page view
+		ping(15*time.Second, 0, 300),
+		newTestEvent(t, 0, pageView),
+		ping(5*time.Second, 0, 100),
+		ping(25*time.Second, 20, 200),
+		// page view without page pings
+		newTestEvent(t, time.Minute, map[string]any{"event": "page_view", "domain_sessionid": "s1", "page_urlpath": "/second", "contexts": webPage("pv2")}),
+		// ignored events
+		newTestEvent(t, time.Minute, map[string]any{"event": "struct", "contexts": webPage("pv2")}),
+		newTestEvent(t, time.Minute, map[string]any{"event": "page_view"}),
+		newTestEvent(t, time.Minute, map[string]any{"event": "page_ping", "contexts": webPage("orphan")}),
+	}
+
+	views, err := PageViewsFromEvents(events)
+	assert.Nil(err)
+	assert.Equal([]PageView{
+		{
+			PageViewID:                   "pv1",
+			EventID:                      "c6ef3124-b53a-4b13-a233-0088f79dcbcb",
+			UserID:                       "user",
+			SessionID:                    "s1",
+			Start:                        start,
+			End:                          start.Add(25 * time.Second),
+			PageURL:                      "http://www.example.com/landing",
+			PageURLPath:                  "/landing",
+			PageTitle:                    "Landing",
+			Referrer:                     Referrer{Urlhost: "www.google.com", Medium: "search"},
+			PagePings:                    3,
+			EngagedTime:                  5*time.Second + 2*10*time.Second,
+			HorizontalPixelsScrolled:     20,
+			VerticalPixelsScrolled:       300,
+			HorizontalPercentageScrolled: 100,
+			VerticalPercentageScrolled:   40,
+			DocWidth:                     1000,
+			DocHeight:                    2000,
+			ViewWidth:                    1000,
+			ViewHeight:                   500,
+		},
+		{
+			PageViewID:  "pv2",
+			EventID:     "c6ef3124-b53a-4b13-a233-0088f79dcbcb",
+			UserID:      "user",
+			SessionID:   "s1",
+			Start:       start.Add(time.Minute),
+			End:         start.Add(time.Minute),
+			PageURLPath: "/second",
+		},
+	}, views)
+
+	// errors
+	_, err = NewPageViewAggregator(WithHeartbeat(0))
+	assert.NotNil(err)
+	aggregator, err := NewPageViewAggregator()
+	assert.Nil(err)
+	assert.NotNil(aggregator.Add(analytics.ParsedEvent{"one", "two"}))
+	invalid := newTestEvent(t, 0, map[string]any{"event": "page_view"})
+	invalid[slices.Index(analytics.LayoutCurrent.Fields(), "contexts")] = `{"data":[{"schema":"iglu:com.snowplowanalytics.snowplow/web_page/jsonschema/1-0-0","data":{"id":}}]}`
+	assert.NotNil(aggregator.Add(invalid))
+	assert.Empty(aggregator.PageViews())
+
+	// streams
+	var streamed []PageView
+	for view, err := range AggregatePageViews(func(yield func(analytics.ParsedEvent, error) bool) {
+		for _, event := range events {
+			if !yield(event, nil) {
+				return
+			}
+		}
+	}) {
+		assert.Nil(err)
+		streamed = append(streamed, view)
+	}
+	assert.Equal(views, streamed)
+}
+
+func BenchmarkPageViewAggregator(b *testing.B) {
+	events := []analytics.ParsedEvent{newTestEvent(b, 0, map[string]any{"event": "page_view", "contexts": webPage("pv1")})}
+	for i := 1; i < 100; i++ {
+		events = append(events, newTestEvent(b, time.Duration(i)*time.Second, map[string]any{"event": "page_ping", "pp_yoffset_max": i, "contexts": webPage("pv1")}))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		PageViewsFromEvents(events)
+	}
+}
@@ -0,0 +1,460 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+// Package sessions groups enriched events into sessions of their users, and summarizes each session: its start and
+// end, its number of events, its landing page, its referrer and the time its user was engaged with its pages.
+// It also aggregates the page pings of page views, into page view records similar to those of the Snowplow web data model.
+package sessions
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"slices"
+	"time"
+
+	"github.com/snowplow/snowplow-golang-analytics-sdk/analytics"
+	"github.com/snowplow/snowplow-golang-analytics-sdk/iglu"
+)
+
+const (
+	defaultTimeout            time.Duration = 30 * time.Minute
+	defaultHeartbeat          time.Duration = 10 * time.Second
+	defaultMinimumVisitLength time.Duration = 5 * time.Second
+)
+
+var (
+	snowplowCriterion      = mustCriterion("iglu:com.snowplowanalytics.snowplow/*/jsonschema/1-*-*")
+	clientSessionCriterion = mustCriterion("iglu:com.snowplowanalytics.snowplow/client_session/jsonschema/1-*-*")
+	webPageCriterion       = mustCriterion("iglu:com.snowplowanalytics.snowplow/web_page/jsonschema/1-*-*")
+)
+
+func mustCriterion(criterion string) iglu.SchemaCriterion {
+	parsed, err := iglu.ParseSchemaCriterion(criterion)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// eventFields are the atomic fields read from each event.
+var eventFields = []string{
+	"event", "domain_userid", "domain_sessionid", "domain_sessionidx", "derived_tstamp", "collector_tstamp",
+	"page_urlpath", "refr_urlhost", "refr_urlpath", "refr_medium", "refr_source", "refr_term",
+}
+
+type config struct {
+	timeout            time.Duration
+	heartbeat          time.Duration
+	minimumVisitLength time.Duration
+}
+
+// Option configures a Sessionizer or a PageViewAggregator.
+type Option func(*config)
+
+// WithTimeout sets the inactivity after which events without a session identifier start a new session, 30 minutes by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// WithHeartbeat sets the interval between the page pings of the tracker, 10 seconds by default.
+func WithHeartbeat(heartbeat time.Duration) Option {
+	return func(c *config) {
+		c.heartbeat = heartbeat
+	}
+}
+
+// WithMinimumVisitLength sets the time after which the tracker sends the first page ping of a page view, 5 seconds by default.
+func WithMinimumVisitLength(length time.Duration) Option {
+	return func(c *config) {
+		c.minimumVisitLength = length
+	}
+}
+
+func newConfig(opts []Option) (config, error) {
+	c := config{timeout: defaultTimeout, heartbeat: defaultHeartbeat, minimumVisitLength: defaultMinimumVisitLength}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.timeout <= 0 {
+		return c, fmt.Errorf("timeout must be positive, got %v", c.timeout)
+	}
+	if c.heartbeat <= 0 {
+		return c, fmt.Errorf("heartbeat must be positive, got %v", c.heartbeat)
+	}
+	if c.minimumVisitLength < 0 {
+		return c, fmt.Errorf("minimum visit length must not be negative, got %v", c.minimumVisitLength)
+	}
+	return c, nil
+}
+
+// Referrer holds the referrer fields of the landing page of a session.
+type Referrer struct {
+	Urlhost string
+	Urlpath string
+	Medium  string
+	Source  string
+	Term    string
+}
+
+// Session summarizes the events of a session.
+type Session struct {
+	// UserID is the domain_userid of the events, or the userId of their client session context if they have none.
+	UserID string
+	// SessionID is the sessionId of the client session context of the events, or their domain_sessionid if they have
+	// none. It is empty for sessions delimited by the inactivity timeout.
+	SessionID string
+	// SessionIndex is the sessionIndex of the client session context of the events, or their domain_sessionidx.
+	SessionIndex int
+	// Start and End are the derived_tstamp of the first and last events of the session, or their collector_tstamp
+	// for layouts without derived_tstamp.
+	Start time.Time
+	End   time.Time
+	// Events is the number of events of the session, of which PageViews are page views and PagePings page pings.
+	Events    int
+	PageViews int
+	PagePings int
+	// LandingPage is the page_urlpath of the first page view of the session, or of its first event with a page_urlpath.
+	LandingPage string
+	Referrer    Referrer
+	// EngagedTime is the time the user was engaged with the pages of the session, estimated from page pings.
+	EngagedTime time.Duration
+}
+
+// Duration returns the time between the first and last events of the session.
+func (s Session) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// record holds the fields of an event used to summarize its session.
+type record struct {
+	tstamp     time.Time
+	event      string
+	pagePath   string
+	pageViewID string
+	referrer   Referrer
+	index      int
+}
+
+type sessionKey struct {
+	user string
+	id   string
+}
+
+// Sessionizer groups events into sessions. Events may be added in any order, as sessions are ordered by timestamp
+// once summarized. A Sessionizer is not safe for concurrent use.
+type Sessionizer struct {
+	config config
+	// sessions holds the records of events with a session identifier, and anonymous the records of events without
+	// one, by user, to be split by the inactivity timeout.
+	sessions  map[sessionKey][]record
+	anonymous map[string][]record
+	// latest is the latest timestamp of the events added so far
+	latest time.Time
+}
+
+// NewSessionizer returns a Sessionizer for the provided options.
+func NewSessionizer(opts ...Option) (*Sessionizer, error) {
+	c, err := newConfig(opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create sessionizer: %w", err)
+	}
+	return &Sessionizer{
+		config:    c,
+		sessions:  make(map[sessionKey][]record),
+		anonymous: make(map[string][]record),
+	}, nil
+}
+
+// Add adds an event to its session. Events without a derived_tstamp or collector_tstamp cannot be added.
+func (s *Sessionizer) Add(event analytics.ParsedEvent) error {
+	fields, err := event.GetSubsetMap(eventFields...)
+	if err != nil {
+		return fmt.Errorf("cannot sessionize event: %w", err)
+	}
+	tstamp, err := tstampOf(fields)
+	if err != nil {
+		return fmt.Errorf("cannot sessionize event: %w", err)
+	}
+	r := record{
+		tstamp:   tstamp,
+		event:    stringOf(fields, "event"),
+		pagePath: stringOf(fields, "page_urlpath"),
+		referrer: referrerOf(fields),
+		index:    intOf(fields, "domain_sessionidx"),
+	}
+	key := sessionKey{user: stringOf(fields, "domain_userid"), id: stringOf(fields, "domain_sessionid")}
+
+	// the client session and web page contexts are both read from a single decoding of the contexts
+	contexts, err := event.GetContexts(snowplowCriterion)
+	if err != nil {
+		return fmt.Errorf("cannot sessionize event: %w", err)
+	}
+	if i := slices.IndexFunc(contexts, func(context analytics.SelfDescribingData) bool {
+		return clientSessionCriterion.MatchesURI(context.Schema)
+	}); i >= 0 {
+		data := contexts[i].Data
+		if id, ok := data["sessionId"].(string); ok && id != "" {
+			key.id = id
+		}
+		if user, ok := data["userId"].(string); ok && key.user == "" {
+			key.user = user
+		}
+		if index, ok := data["sessionIndex"].(float64); ok {
+			r.index = int(index)
+		}
+	}
+	if r.event == "page_ping" {
+		r.pageViewID = pageViewID(contexts)
+	}
+
+	if tstamp.After(s.latest) {
+		s.latest = tstamp
+	}
+	if key.id == "" {
+		s.anonymous[key.user] = append(s.anonymous[key.user], r)
+	} else {
+		s.sessions[key] = append(s.sessions[key], r)
+	}
+	return nil
+}
+
+func stringOf(fields map[string]any, key string) string {
+	value, _ := fields[key].(string)
+	return value
+}
+
+func intOf(fields map[string]any, key string) int {
+	value, _ := fields[key].(int)
+	return value
+}
+
+// tstampOf returns the derived_tstamp of an event, or its collector_tstamp for layouts without derived_tstamp.
+func tstampOf(fields map[string]any) (time.Time, error) {
+	if tstamp, ok := fields["derived_tstamp"].(time.Time); ok {
+		return tstamp, nil
+	}
+	if tstamp, ok := fields["collector_tstamp"].(time.Time); ok {
+		return tstamp, nil
+	}
+	return time.Time{}, fmt.Errorf("no derived_tstamp or collector_tstamp")
+}
+
+func referrerOf(fields map[string]any) Referrer {
+	return Referrer{
+		Urlhost: stringOf(fields, "refr_urlhost"),
+		Urlpath: stringOf(fields, "refr_urlpath"),
+		Medium:  stringOf(fields, "refr_medium"),
+		Source:  stringOf(fields, "refr_source"),
+		Term:    stringOf(fields, "refr_term"),
+	}
+}
+
+// pageViewIDOf returns the id of the web page context of an event, identifying its page view, or an empty string.
+func pageViewIDOf(event analytics.ParsedEvent) (string, error) {
+	pages, err := event.GetContexts(webPageCriterion)
+	if err != nil {
+		return "", err
+	}
+	return pageViewID(pages), nil
+}
+
+// pageViewID returns the id of the first web page context of contexts, or an empty string.
+func pageViewID(contexts []analytics.SelfDescribingData) string {
+	for _, context := range contexts {
+		if webPageCriterion.MatchesURI(context.Schema) {
+			id, _ := context.Data["id"].(string)
+			return id
+		}
+	}
+	return ""
+}
+
+// Sessions returns the summaries of the sessions of the events added so far, ordered by start, user and session identifier.
+func (s *Sessionizer) Sessions() []Session {
+	var sessions []Session
+	for key, records := range s.sessions {
+		sortRecords(records)
+		sessions = append(sessions, s.summarize(key, records))
+	}
+	for user, records := range s.anonymous {
+		sortRecords(records)
+		start := 0
+		for i := 1; i <= len(records); i++ {
+			if i == len(records) || records[i].tstamp.Sub(records[i-1].tstamp) > s.config.timeout {
+				sessions = append(sessions, s.summarize(sessionKey{user: user}, records[start:i]))
+				start = i
+			}
+		}
+	}
+	slices.SortFunc(sessions, func(a, b Session) int {
+		return cmp.Or(a.Start.Compare(b.Start), cmp.Compare(a.UserID, b.UserID), cmp.Compare(a.SessionID, b.SessionID))
+	})
+	return sessions
+}
+
+// expire removes the sessions whose last event is before the provided time, and returns their summaries ordered as
+// Sessions orders them. Events without a session identifier are only expired once all events of their user are.
+func (s *Sessionizer) expire(before time.Time) []Session {
+	expired := &Sessionizer{config: s.config, sessions: make(map[sessionKey][]record), anonymous: make(map[string][]record)}
+	for key, records := range s.sessions {
+		if latestOf(records).Before(before) {
+			expired.sessions[key] = records
+			delete(s.sessions, key)
+		}
+	}
+	for user, records := range s.anonymous {
+		if latestOf(records).Before(before) {
+			expired.anonymous[user] = records
+			delete(s.anonymous, user)
+		}
+	}
+	return expired.Sessions()
+}
+
+// latestOf returns the latest timestamp of records, which may not be ordered yet.
+func latestOf(records []record) time.Time {
+	var latest time.Time
+	for _, r := range records {
+		if r.tstamp.After(latest) {
+			latest = r.tstamp
+		}
+	}
+	return latest
+}
+
+func sortRecords(records []record) {
+	slices.SortStableFunc(records, func(a, b record) int {
+		return a.tstamp.Compare(b.tstamp)
+	})
+}
+
+// summarize summarizes the records of a session, ordered by timestamp.
+func (s *Sessionizer) summarize(key sessionKey, records []record) Session {
+	session := Session{
+		UserID:    key.user,
+		SessionID: key.id,
+		Start:     records[0].tstamp,
+		End:       records[len(records)-1].tstamp,
+		Events:    len(records),
+	}
+	landing := -1
+	pings := make(map[string][]time.Time)
+	var pages []string
+	for i, r := range records {
+		if r.index != 0 && session.SessionIndex == 0 {
+			session.SessionIndex = r.index
+		}
+		switch r.event {
+		case "page_view":
+			session.PageViews++
+			if landing < 0 || records[landing].event != "page_view" {
+				landing = i
+			}
+		case "page_ping":
+			session.PagePings++
+			page := r.pageViewID
+			if page == "" {
+				page = r.pagePath
+			}
+			if _, ok := pings[page]; !ok {
+				pages = append(pages, page)
+			}
+			pings[page] = append(pings[page], r.tstamp)
+		}
+		if landing < 0 && r.pagePath != "" {
+			landing = i
+		}
+	}
+	if landing < 0 {
+		landing = 0
+	}
+	session.LandingPage = records[landing].pagePath
+	session.Referrer = records[landing].referrer
+	for _, page := range pages {
+		session.EngagedTime += engagedTime(pings[page], s.config)
+	}
+	return session
+}
+
+// engagedTime estimates the time a user was engaged with a page view from its page pings: the minimum visit length
+// for the first heartbeat with a page ping, and a heartbeat for each other heartbeat with one.
+func engagedTime(pings []time.Time, c config) time.Duration {
+	if len(pings) == 0 {
+		return 0
+	}
+	heartbeats := make(map[int64]struct{}, len(pings))
+	for _, ping := range pings {
+		heartbeats[ping.UnixNano()/int64(c.heartbeat)] = struct{}{}
+	}
+	return c.minimumVisitLength + time.Duration(len(heartbeats)-1)*c.heartbeat
+}
+
+// Sessionize groups a stream of events, such as the events of an analytics.Reader, into sessions, and yields their
+// summaries once the timeout has passed since their last event, measured against the latest event of the stream, so
+// that unbounded streams can be sessionized in bounded memory. The remaining sessions are yielded once the stream is
+// consumed. Sessions are ordered by start among those yielded together, and an event arriving after its session was
+// yielded, in streams out of order by more than the timeout, starts a new session. Iteration stops at the first error,
+// either of the stream or of an event.
+func Sessionize(events iter.Seq2[analytics.ParsedEvent, error], opts ...Option) iter.Seq2[Session, error] {
+	return func(yield func(Session, error) bool) {
+		sessionizer, err := NewSessionizer(opts...)
+		if err != nil {
+			yield(Session{}, err)
+			return
+		}
+		timeout := sessionizer.config.timeout
+		var swept time.Time
+		for event, err := range events {
+			if err == nil {
+				err = sessionizer.Add(event)
+			}
+			if err != nil {
+				yield(Session{}, err)
+				return
+			}
+			// look for expired sessions once per timeout of event time, to keep the cost of a sweep over all
+			// sessions independent of the number of events
+			if sessionizer.latest.Sub(swept) < timeout {
+				continue
+			}
+			swept = sessionizer.latest
+			for _, session := range sessionizer.expire(swept.Add(-timeout)) {
+				if !yield(session, nil) {
+					return
+				}
+			}
+		}
+		for _, session := range sessionizer.Sessions() {
+			if !yield(session, nil) {
+				return
+			}
+		}
+	}
+}
+
+// FromEvents groups a slice of events into sessions, and returns their summaries.
+func FromEvents(events []analytics.ParsedEvent, opts ...Option) ([]Session, error) {
+	sessionizer, err := NewSessionizer(opts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		if err := sessionizer.Add(event); err != nil {
+			return nil, err
+		}
+	}
+	return sessionizer.Sessions(), nil
+}
@@ -0,0 +1,208 @@
+//
+// Copyright (c) 2021 Snowplow Analytics Ltd. All rights reserved.
+//
+// This program is licensed to you under the Apache License Version 2.0,
+// and you may not use this file except in compliance with the Apache License Version 2.0.
+// You may obtain a copy of the Apache License Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0.
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the Apache License Version 2.0 is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the Apache License Version 2.0 for the specific language governing permissions and limitations there under.
+//
+
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/snowplow/snowplow-golang-analytics-sdk/analytics"
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Date(2021, 4, 7, 12, 0, 0, 0, time.UTC)
+
+func clientSession(user string, session string, index int) string {
+	return fmt.Sprintf(`{"schema":"iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-0","data":[{"schema":"iglu:com.snowplowanalytics.snowplow/client_session/jsonschema/1-0-2","data":{"userId":"%s","sessionId":"%s","sessionIndex":%d}}]}`, user, session, index)
+}
+
+func webPage(id string) string {
+	return fmt.Sprintf(`{"schema":"iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-0","data":[{"schema":"iglu:com.snowplowanalytics.snowplow/web_page/jsonschema/1-0-0","data":{"id":"%s"}}]}`, id)
+}
+
+func newTestEvent(t testing.TB, offset time.Duration, fields map[string]any) analytics.ParsedEvent {
+	base := map[string]any{
+		"app_id":           "angry-birds",
+		"collector_tstamp": start.Add(offset),
+		"derived_tstamp":   start.Add(offset),
+		"event_id":         "c6ef3124-b53a-4b13-a233-0088f79dcbcb",
+		"domain_userid":    "user",
+	}
+	for key, value := range fields {
+		base[key] = value
+	}
+	event, err := analytics.FromMap(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestSessionizer(t *testing.T) {
+	assert := assert.New(t)
+
+	events := []analytics.ParsedEvent{
+		// added out of order
+		newTestEvent(t, 2*time.Minute, map[string]any{"event": "page_view", "domain_sessionid": "s1", "domain_sessionidx": 1, "page_urlpath": "/second"}),
+		newTestEvent(t, time.Minute, map[string]any{"event": "page_view", "domain_sessionid": "s1", "domain_sessionidx": 1, "page_urlpath": "/landing", "refr_urlhost": "www.google.com", "refr_medium": "search", "refr_source": "Google", "refr_term": "birds"}),
+		newTestEvent(t, 0, map[string]any{"event": "struct", "domain_sessionid": "s1", "domain_sessionidx": 1, "page_urlpath": "/before"}),
+		// page pings of two page views, the first spanning three heartbeats
+		newTestEvent(t, time.Minute+5*time.Second, map[string]any{"event": "page_ping", "domain_sessionid": "s1", "page_urlpath": "/landing", "contexts": webPage("pv1")}),
+		newTestEvent(t, time.Minute+15*time.Second, map[string]any{"event": "page_ping", "domain_sessionid": "s1", "page_urlpath": "/landing", "contexts": webPage("pv1")}),
+		newTestEvent(t, time.Minute+16*time.Second, map[string]any{"event": "page_ping", "domain_sessionid": "s1", "page_urlpath": "/landing", "contexts": webPage("pv1")}),
+		newTestEvent(t, time.Minute+25*time.Second, map[string]any{"event": "page_ping", "domain_sessionid": "s1", "page_urlpath": "/landing", "contexts": webPage("pv1")}),
+		newTestEvent(t, 2*time.Minute+5*time.Second, map[string]any{"event": "page_ping", "domain_sessionid": "s1", "page_urlpath": "/second"}),
+		// client session context takes precedence over domain_sessionid
+		newTestEvent(t, time.Hour, map[string]any{"event": "page_view", "domain_userid": "", "domain_sessionid": "ignored", "contexts": clientSession("client", "s2", 3)}),
+		// events without a session identifier are split by the inactivity timeout
+		newTestEvent(t, 0, map[string]any{"event": "page_view", "domain_userid": "anonymous"}),
+		newTestEvent(t, 29*time.Minute, map[string]any{"event": "struct", "domain_userid": "anonymous"}),
+		newTestEvent(t, 60*time.Minute, map[string]any{"event": "struct", "domain_userid": "anonymous"}),
+	}
+
+	sessions, err := FromEvents(events)
+	assert.Nil(err)
+	assert.Equal([]Session{
+		{
+			UserID:    "anonymous",
+			Start:     start,
+			End:       start.Add(29 * time.Minute),
+			Events:    2,
+			PageViews: 1,
+		},
+		{
+			UserID:       "user",
+			SessionID:    "s1",
+			SessionIndex: 1,
+			Start:        start,
+			End:          start.Add(2*time.Minute + 5*time.Second),
+			Events:       8,
+			PageViews:    2,
+			PagePings:    5,
+			LandingPage:  "/landing",
+			Referrer:     Referrer{Urlhost: "www.google.com", Medium: "search", Source: "Google", Term: "birds"},
+			EngagedTime:  5*time.Second + 2*10*time.Second + 5*time.Second,
+		},
+		{
+			UserID: "anonymous",
+			Start:  start.Add(60 * time.Minute),
+			End:    start.Add(60 * time.Minute),
+			Events: 1,
+		},
+		{
+			UserID:       "client",
+			SessionID:    "s2",
+			SessionIndex: 3,
+			Start:        start.Add(time.Hour),
+			End:          start.Add(time.Hour),
+			Events:       1,
+			PageViews:    1,
+		},
+	}, sessions)
+	assert.Equal(2*time.Minute+5*time.Second, sessions[1].Duration())
+
+	// options
+	sessions, err = FromEvents(events, WithTimeout(time.Hour), WithHeartbeat(5*time.Second), WithMinimumVisitLength(0))
+	assert.Nil(err)
+	assert.Len(sessions, 3)
+	assert.Equal(3, sessions[0].Events)
+	assert.Equal(2*5*time.Second, sessions[1].EngagedTime)
+
+	for _, opt := range []Option{WithTimeout(0), WithHeartbeat(-time.Second), WithMinimumVisitLength(-time.Second)} {
+		_, err = NewSessionizer(opt)
+		assert.NotNil(err)
+	}
+
+	// events without a timestamp
+	sessionizer, err := NewSessionizer()
+	assert.Nil(err)
+	event, err := analytics.FromMap(map[string]any{"event_id": "c6ef3124-b53a-4b13-a233-0088f79dcbcb"})
+	assert.Nil(err)
+	assert.NotNil(sessionizer.Add(event))
+	assert.NotNil(sessionizer.Add(analytics.ParsedEvent{"one", "two"}))
+	assert.Empty(sessionizer.Sessions())
+}
+
+func BenchmarkSessionizer(b *testing.B) {
+	events := make([]analytics.ParsedEvent, 100)
+	for i := range events {
+		events[i] = newTestEvent(b, time.Duration(i)*time.Second, map[string]any{"event": "page_ping", "domain_sessionid": "s1", "contexts": webPage("pv1")})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		FromEvents(events)
+	}
+}
+
+func TestSessionize(t *testing.T) {
+	assert := assert.New(t)
+
+	events := func(yield func(analytics.ParsedEvent, error) bool) {
+		if !yield(newTestEvent(t, 0, map[string]any{"event": "page_view", "domain_sessionid": "s1"}), nil) {
+			return
+		}
+		yield(newTestEvent(t, time.Minute, map[string]any{"event": "page_view", "domain_sessionid": "s2"}), nil)
+	}
+	var sessions []Session
+	for session, err := range Sessionize(events) {
+		assert.Nil(err)
+		sessions = append(sessions, session)
+	}
+	assert.Len(sessions, 2)
+	assert.Equal("s1", sessions[0].SessionID)
+	assert.Equal("s2", sessions[1].SessionID)
+
+	// early break
+	for range Sessionize(events) {
+		break
+	}
+
+	// sessions are yielded once the timeout has passed since their last event
+	consumed := 0
+	stream := func(yield func(analytics.ParsedEvent, error) bool) {
+		for _, event := range []analytics.ParsedEvent{
+			newTestEvent(t, 0, map[string]any{"event": "page_view", "domain_sessionid": "s1"}),
+			newTestEvent(t, time.Minute, map[string]any{"event": "page_view", "domain_userid": "anonymous"}),
+			newTestEvent(t, 40*time.Minute, map[string]any{"event": "page_view", "domain_sessionid": "s2"}),
+			newTestEvent(t, 2*time.Hour, map[string]any{"event": "page_view", "domain_sessionid": "s3"}),
+		} {
+			consumed++
+			if !yield(event, nil) {
+				return
+			}
+		}
+	}
+	var yielded []string
+	var consumedBefore []int
+	for session, err := range Sessionize(stream) {
+		assert.Nil(err)
+		yielded = append(yielded, session.UserID+"/"+session.SessionID)
+		consumedBefore = append(consumedBefore, consumed)
+	}
+	assert.Equal([]string{"user/s1", "anonymous/", "user/s2", "user/s3"}, yielded)
+	assert.Equal([]int{3, 3, 4, 4}, consumedBefore)
+
+	// stream errors stop iteration
+	failing := func(yield func(analytics.ParsedEvent, error) bool) {
+		yield(nil, errors.New("broken stream"))
+	}
+	count := 0
+	for _, err := range Sessionize(failing) {
+		assert.EqualError(err, "broken stream")
+		count++
+	}
+	assert.Equal(1, count)
+}